@@ -0,0 +1,244 @@
+// Package adapter は Lambda のイベントと net/http を相互に変換する。
+// API Gateway (REST API / HTTP API)、ALB、Lambda Function URL のイベントを *http.Request に変換して http.Handler を呼び出し、
+// 書き込まれたレスポンスをそれぞれのイベントのレスポンスの型に変換する。
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aki80204/go-gateway/utils"
+)
+
+// request はイベントの種類によらない共通のリクエスト
+type request struct {
+	method string
+	// rawPath はエスケープされたパス
+	rawPath         string
+	rawQuery        string
+	header          http.Header
+	body            string
+	isBase64Encoded bool
+	host            string
+	sourceIP        string
+	// clientCertPEM は API Gateway の mTLS で検証済みのクライアント証明書
+	clientCertPEM string
+}
+
+// httpRequest は ctx を引き継いだ *http.Request に変換する。
+// API Gateway などはクライアントと HTTPS で通信するため、URL のスキームは https にする
+func (e *request) httpRequest(ctx context.Context) (*http.Request, error) {
+	body := []byte(e.body)
+	if e.isBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(e.body)
+		if err != nil {
+			return nil, fmt.Errorf("リクエストボディの Base64 のデコードに失敗しました: %w", err)
+		}
+		body = decoded
+	}
+
+	rawPath := e.rawPath
+	if rawPath == "" {
+		rawPath = "/"
+	}
+	u, err := url.ParseRequestURI(rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("リクエストのパスが不正です: %w", err)
+	}
+	u.Scheme = "https"
+	u.Host = e.host
+	u.RawQuery = e.rawQuery
+
+	method := e.method
+	if method == "" {
+		method = http.MethodGet
+	}
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("リクエストの変換に失敗しました: %w", err)
+	}
+	r.RequestURI = u.RequestURI()
+	if e.header != nil {
+		r.Header = e.header
+	}
+	if e.sourceIP != "" {
+		r.RemoteAddr = net.JoinHostPort(e.sourceIP, "0")
+	}
+
+	if e.clientCertPEM != "" {
+		cert, err := parseCertificatePEM(e.clientCertPEM)
+		if err != nil {
+			return nil, err
+		}
+		r.TLS = &tls.ConnectionState{HandshakeComplete: true, PeerCertificates: []*x509.Certificate{cert}}
+	}
+	return r, nil
+}
+
+// serve は req を h で処理し、書き込まれたレスポンスを返す。変換できないリクエストには 400 を返す
+func serve(ctx context.Context, h http.Handler, req *request) *responseWriter {
+	w := newResponseWriter()
+	r, err := req.httpRequest(ctx)
+	if err != nil {
+		log.Printf("リクエストの変換に失敗しました: %v", err)
+		utils.WriteError(w, http.StatusBadRequest, "Bad Request")
+		return w
+	}
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func parseCertificatePEM(s string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("クライアント証明書の PEM の形式が不正です")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("クライアント証明書の解析に失敗しました: %w", err)
+	}
+	return cert, nil
+}
+
+// 単一値のヘッダーを http.Header に変換する。API Gateway v2 などは同名のヘッダーをカンマで連結して渡す
+func singleValueHeader(headers map[string]string) http.Header {
+	h := make(http.Header, len(headers))
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	return h
+}
+
+// 複数値のヘッダーを http.Header に変換する。multiValueHeaders がない場合は headers を使う
+func multiValueHeader(headers map[string]string, multiValueHeaders map[string][]string) http.Header {
+	if len(multiValueHeaders) == 0 {
+		return singleValueHeader(headers)
+	}
+	h := make(http.Header, len(multiValueHeaders))
+	for k, values := range multiValueHeaders {
+		for _, v := range values {
+			h.Add(k, v)
+		}
+	}
+	return h
+}
+
+// デコード済みのクエリパラメータからクエリ文字列を組み立てる
+func encodeQuery(query map[string]string, multiValueQuery map[string][]string) string {
+	values := url.Values{}
+	if len(multiValueQuery) > 0 {
+		for k, vs := range multiValueQuery {
+			values[k] = append([]string(nil), vs...)
+		}
+	} else {
+		for k, v := range query {
+			values.Set(k, v)
+		}
+	}
+	return values.Encode()
+}
+
+// エンコードされたままのクエリパラメータ (ALB) からクエリ文字列を組み立てる
+func joinRawQuery(query map[string]string, multiValueQuery map[string][]string) string {
+	if len(multiValueQuery) == 0 {
+		multiValueQuery = make(map[string][]string, len(query))
+		for k, v := range query {
+			multiValueQuery[k] = []string{v}
+		}
+	}
+	keys := make([]string, 0, len(multiValueQuery))
+	for k := range multiValueQuery {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range multiValueQuery[k] {
+			parts = append(parts, k+"="+v)
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// responseWriter は http.Handler が書き込んだレスポンスをメモリに保持する
+type responseWriter struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func newResponseWriter() *responseWriter {
+	return &responseWriter{header: make(http.Header)}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// encodedBody はボディを返す。UTF-8 として扱えないボディは Base64 でエンコードする
+func (w *responseWriter) encodedBody() (string, bool) {
+	if utf8.Valid(w.body.Bytes()) {
+		return w.body.String(), false
+	}
+	return base64.StdEncoding.EncodeToString(w.body.Bytes()), true
+}
+
+func (w *responseWriter) joinedHeaders(except ...string) map[string]string {
+	return joinHeaders(w.header, except...)
+}
+
+// joinHeaders は同名のヘッダーをカンマで連結した単一値のヘッダーを返す。except のヘッダーは含めない
+func joinHeaders(h http.Header, except ...string) map[string]string {
+	headers := make(map[string]string, len(h))
+	for k, values := range h {
+		if containsFold(except, k) {
+			continue
+		}
+		headers[k] = strings.Join(values, ",")
+	}
+	return headers
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
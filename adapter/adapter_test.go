@@ -0,0 +1,267 @@
+package adapter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// 受け取ったリクエストを記録し、固定のレスポンスを書き込むハンドラ
+type recordingHandler struct {
+	request *http.Request
+	body    []byte
+	write   func(w http.ResponseWriter)
+}
+
+func (h *recordingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.request = r
+	h.body, _ = io.ReadAll(r.Body)
+	if h.write != nil {
+		h.write(w)
+	}
+}
+
+func newCertPEM(t *testing.T, commonName string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("鍵の生成に失敗しました: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("証明書の生成に失敗しました: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestAPIGatewayV2(t *testing.T) {
+	h := &recordingHandler{write: func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}}
+	event := events.APIGatewayV2HTTPRequest{
+		RawPath:         "/api/customers/a%2Fb",
+		RawQueryString:  "x=1&x=2",
+		Cookies:         []string{"session=abc", "theme=dark"},
+		Headers:         map[string]string{"authorization": "Bearer token", "host": "api.example.com"},
+		Body:            base64.StdEncoding.EncodeToString([]byte{0x00, 0xff}),
+		IsBase64Encoded: true,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: http.MethodPost, SourceIP: "192.0.2.1"},
+			Authentication: events.APIGatewayV2HTTPRequestContextAuthentication{
+				ClientCert: events.APIGatewayV2HTTPRequestContextAuthenticationClientCert{ClientCertPem: newCertPEM(t, "payments-batch")},
+			},
+		},
+	}
+
+	resp, err := APIGatewayV2(h)(context.Background(), event)
+	if err != nil {
+		t.Fatalf("APIGatewayV2() error = %v", err)
+	}
+
+	r := h.request
+	if r.Method != http.MethodPost || r.URL.EscapedPath() != "/api/customers/a%2Fb" || r.URL.RawQuery != "x=1&x=2" {
+		t.Errorf("request = %s %s?%s", r.Method, r.URL.EscapedPath(), r.URL.RawQuery)
+	}
+	if r.Host != "api.example.com" || r.RemoteAddr != "192.0.2.1:0" {
+		t.Errorf("Host = %q, RemoteAddr = %q", r.Host, r.RemoteAddr)
+	}
+	if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Cookie") != "session=abc; theme=dark" {
+		t.Errorf("Header = %v", r.Header)
+	}
+	if string(h.body) != string([]byte{0x00, 0xff}) {
+		t.Errorf("Body = %v, want デコード済みの値", h.body)
+	}
+	if r.TLS == nil || r.TLS.PeerCertificates[0].Subject.CommonName != "payments-batch" {
+		t.Errorf("クライアント証明書が設定されていません: %+v", r.TLS)
+	}
+
+	if resp.StatusCode != http.StatusCreated || resp.Body != `{"ok":true}` || resp.IsBase64Encoded {
+		t.Errorf("response = %d %q base64=%v", resp.StatusCode, resp.Body, resp.IsBase64Encoded)
+	}
+	if resp.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Headers = %v", resp.Headers)
+	}
+	if _, ok := resp.Headers["Set-Cookie"]; ok || len(resp.Cookies) != 2 {
+		t.Errorf("Set-Cookie は Cookies に分ける: Headers = %v, Cookies = %v", resp.Headers, resp.Cookies)
+	}
+}
+
+func TestAPIGatewayV2_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		event events.APIGatewayV2HTTPRequest
+	}{
+		{name: "Base64 が壊れている", event: events.APIGatewayV2HTTPRequest{RawPath: "/", Body: "%%%", IsBase64Encoded: true}},
+		{
+			name: "クライアント証明書の PEM が壊れている",
+			event: events.APIGatewayV2HTTPRequest{RawPath: "/", RequestContext: events.APIGatewayV2HTTPRequestContext{
+				Authentication: events.APIGatewayV2HTTPRequestContextAuthentication{
+					ClientCert: events.APIGatewayV2HTTPRequestContextAuthenticationClientCert{ClientCertPem: "not a pem"},
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &recordingHandler{}
+			resp, err := APIGatewayV2(h)(context.Background(), tt.event)
+			if err != nil {
+				t.Fatalf("APIGatewayV2() error = %v", err)
+			}
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("StatusCode = %d, want 400", resp.StatusCode)
+			}
+			if h.request != nil {
+				t.Error("変換できないリクエストで handler を呼び出さない")
+			}
+		})
+	}
+}
+
+func TestAPIGatewayV1(t *testing.T) {
+	h := &recordingHandler{write: func(w http.ResponseWriter) {
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		_, _ = w.Write([]byte{0xff, 0x00})
+	}}
+	event := events.APIGatewayProxyRequest{
+		HTTPMethod:                      http.MethodGet,
+		Path:                            "/api/customers/a b",
+		MultiValueHeaders:               map[string][]string{"X-Multi": {"a", "b"}, "Host": {"api.example.com"}},
+		MultiValueQueryStringParameters: map[string][]string{"q": {"a&b"}},
+		RequestContext:                  events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{SourceIP: "192.0.2.1"}},
+	}
+
+	resp, err := APIGatewayV1(h)(context.Background(), APIGatewayV1Request{APIGatewayProxyRequest: event})
+	if err != nil {
+		t.Fatalf("APIGatewayV1() error = %v", err)
+	}
+
+	r := h.request
+	if r.URL.EscapedPath() != "/api/customers/a%20b" || r.URL.Query().Get("q") != "a&b" {
+		t.Errorf("URL = %s", r.URL)
+	}
+	if got := r.Header.Values("X-Multi"); len(got) != 2 {
+		t.Errorf("X-Multi = %v, want 2 values", got)
+	}
+	if resp.StatusCode != http.StatusOK || !resp.IsBase64Encoded || resp.Body != base64.StdEncoding.EncodeToString([]byte{0xff, 0x00}) {
+		t.Errorf("response = %d %q base64=%v", resp.StatusCode, resp.Body, resp.IsBase64Encoded)
+	}
+	if len(resp.MultiValueHeaders["Set-Cookie"]) != 2 {
+		t.Errorf("MultiValueHeaders = %v", resp.MultiValueHeaders)
+	}
+}
+
+// REST API の mTLS のクライアント証明書は events.APIGatewayProxyRequest にないため、イベントの JSON から読み取る
+func TestAPIGatewayV1_ClientCert(t *testing.T) {
+	certPEM, _ := json.Marshal(newCertPEM(t, "payments-batch"))
+	payload := `{
+		"resource": "/{proxy+}", "path": "/api/transfers", "httpMethod": "POST",
+		"requestContext": {"identity": {"sourceIp": "192.0.2.1", "clientCert": {"clientCertPem": ` + string(certPEM) + `}}}
+	}`
+
+	var event APIGatewayV1Request
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if event.HTTPMethod != http.MethodPost || event.RequestContext.Identity.SourceIP != "192.0.2.1" {
+		t.Errorf("event = %+v", event.APIGatewayProxyRequest)
+	}
+
+	h := &recordingHandler{}
+	if _, err := APIGatewayV1(h)(context.Background(), event); err != nil {
+		t.Fatalf("APIGatewayV1() error = %v", err)
+	}
+	if r := h.request; r.TLS == nil || r.TLS.PeerCertificates[0].Subject.CommonName != "payments-batch" {
+		t.Errorf("クライアント証明書が設定されていません: %+v", r.TLS)
+	}
+
+	// イベントの種類を判別する場合も同じように読み取る
+	h = &recordingHandler{}
+	if _, err := Handler(h)(context.Background(), []byte(payload)); err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+	if r := h.request; r.TLS == nil || r.TLS.PeerCertificates[0].Subject.CommonName != "payments-batch" {
+		t.Errorf("Handler() でクライアント証明書が設定されていません: %+v", r.TLS)
+	}
+}
+
+func TestALB(t *testing.T) {
+	h := &recordingHandler{write: func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusNotFound)
+	}}
+	event := events.ALBTargetGroupRequest{
+		HTTPMethod:            http.MethodGet,
+		Path:                  "/api/customers/a%2Fb",
+		QueryStringParameters: map[string]string{"q": "a%26b"},
+		Headers:               map[string]string{"host": "alb.example.com", "x-forwarded-for": "198.51.100.1, 192.0.2.1"},
+	}
+
+	resp, err := ALB(h)(context.Background(), event)
+	if err != nil {
+		t.Fatalf("ALB() error = %v", err)
+	}
+
+	r := h.request
+	if r.URL.EscapedPath() != "/api/customers/a%2Fb" || r.URL.Query().Get("q") != "a&b" {
+		t.Errorf("URL = %s", r.URL)
+	}
+	if r.Host != "alb.example.com" || r.RemoteAddr != "192.0.2.1:0" {
+		t.Errorf("Host = %q, RemoteAddr = %q", r.Host, r.RemoteAddr)
+	}
+	if resp.StatusCode != http.StatusNotFound || resp.StatusDescription != "404 Not Found" {
+		t.Errorf("response = %d %q", resp.StatusCode, resp.StatusDescription)
+	}
+}
+
+func TestFunctionURL(t *testing.T) {
+	h := &recordingHandler{write: func(w http.ResponseWriter) {
+		w.Header().Add("Set-Cookie", "a=1")
+		_, _ = w.Write([]byte("ok"))
+	}}
+	event := events.LambdaFunctionURLRequest{
+		RawPath:        "/api/customers/account",
+		RawQueryString: "x=1",
+		Cookies:        []string{"session=abc"},
+		RequestContext: events.LambdaFunctionURLRequestContext{
+			DomainName: "abc.lambda-url.ap-northeast-1.on.aws",
+			HTTP:       events.LambdaFunctionURLRequestContextHTTPDescription{Method: http.MethodGet, SourceIP: "192.0.2.1"},
+		},
+	}
+
+	resp, err := FunctionURL(h)(context.Background(), event)
+	if err != nil {
+		t.Fatalf("FunctionURL() error = %v", err)
+	}
+
+	r := h.request
+	if r.Host != "abc.lambda-url.ap-northeast-1.on.aws" || r.URL.RawQuery != "x=1" || r.Header.Get("Cookie") != "session=abc" {
+		t.Errorf("request = %s %v", r.URL, r.Header)
+	}
+	if resp.Body != "ok" || len(resp.Cookies) != 1 {
+		t.Errorf("response = %q, Cookies = %v", resp.Body, resp.Cookies)
+	}
+}
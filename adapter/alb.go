@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ALB は Application Load Balancer のターゲットグループのイベントで h を呼び出す Lambda ハンドラを返す。
+// ターゲットグループで複数値ヘッダーが有効な場合（リクエストに multiValueHeaders がある場合）は、レスポンスも multiValueHeaders で返す
+func ALB(h http.Handler) func(context.Context, events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	return func(ctx context.Context, event events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+		w := serve(ctx, h, newALBRequest(event))
+		body, isBase64Encoded := w.encodedBody()
+		status := w.statusCode()
+		resp := events.ALBTargetGroupResponse{
+			StatusCode:        status,
+			StatusDescription: statusDescription(status),
+			Body:              body,
+			IsBase64Encoded:   isBase64Encoded,
+		}
+		if len(event.MultiValueHeaders) > 0 {
+			resp.MultiValueHeaders = w.header
+		} else {
+			resp.Headers = w.joinedHeaders()
+		}
+		return resp, nil
+	}
+}
+
+// ALB の path とクエリパラメータはクライアントが送信したままエンコードされている
+func newALBRequest(event events.ALBTargetGroupRequest) *request {
+	header := multiValueHeader(event.Headers, event.MultiValueHeaders)
+	return &request{
+		method:          event.HTTPMethod,
+		rawPath:         event.Path,
+		rawQuery:        joinRawQuery(event.QueryStringParameters, event.MultiValueQueryStringParameters),
+		header:          header,
+		body:            event.Body,
+		isBase64Encoded: event.IsBase64Encoded,
+		host:            header.Get("Host"),
+		sourceIP:        clientIP(header.Get("X-Forwarded-For")),
+	}
+}
+
+// ALB が X-Forwarded-For の末尾に付与するクライアントの IP アドレスを返す
+func clientIP(forwardedFor string) string {
+	if forwardedFor == "" {
+		return ""
+	}
+	parts := strings.Split(forwardedFor, ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
+
+// ALB のレスポンスに必要な "200 OK" 形式のステータス
+func statusDescription(status int) string {
+	return strconv.Itoa(status) + " " + http.StatusText(status)
+}
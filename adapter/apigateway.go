@@ -0,0 +1,114 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// APIGatewayV2 は API Gateway HTTP API (ペイロード形式 2.0) のイベントで h を呼び出す Lambda ハンドラを返す
+func APIGatewayV2(h http.Handler) func(context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	return func(ctx context.Context, event events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+		w := serve(ctx, h, newAPIGatewayV2Request(event))
+		body, isBase64Encoded := w.encodedBody()
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode:      w.statusCode(),
+			Headers:         w.joinedHeaders("Set-Cookie"),
+			Cookies:         w.header.Values("Set-Cookie"),
+			Body:            body,
+			IsBase64Encoded: isBase64Encoded,
+		}, nil
+	}
+}
+
+// APIGatewayV1Request は API Gateway REST API (ペイロード形式 1.0) のイベント。
+// events.APIGatewayProxyRequest は mTLS のクライアント証明書 (requestContext.identity.clientCert) を持たないため、合わせて読み取る
+type APIGatewayV1Request struct {
+	events.APIGatewayProxyRequest
+	// ClientCertPEM は API Gateway の mTLS で検証済みのクライアント証明書
+	ClientCertPEM string `json:"-"`
+}
+
+// REST API のイベントのうち、events.APIGatewayProxyRequest が読み取らないクライアント証明書
+type apiGatewayV1ClientCert struct {
+	RequestContext struct {
+		Identity struct {
+			ClientCert struct {
+				ClientCertPem string `json:"clientCertPem"`
+			} `json:"clientCert"`
+		} `json:"identity"`
+	} `json:"requestContext"`
+}
+
+// UnmarshalJSON はイベントとクライアント証明書を読み取る
+func (e *APIGatewayV1Request) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.APIGatewayProxyRequest); err != nil {
+		return err
+	}
+	var cert apiGatewayV1ClientCert
+	if err := json.Unmarshal(data, &cert); err != nil {
+		return err
+	}
+	e.ClientCertPEM = cert.RequestContext.Identity.ClientCert.ClientCertPem
+	return nil
+}
+
+// APIGatewayV1 は API Gateway REST API (ペイロード形式 1.0) のイベントで h を呼び出す Lambda ハンドラを返す
+func APIGatewayV1(h http.Handler) func(context.Context, APIGatewayV1Request) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, event APIGatewayV1Request) (events.APIGatewayProxyResponse, error) {
+		w := serve(ctx, h, newAPIGatewayV1Request(event))
+		body, isBase64Encoded := w.encodedBody()
+		return events.APIGatewayProxyResponse{
+			StatusCode:        w.statusCode(),
+			MultiValueHeaders: w.header,
+			Body:              body,
+			IsBase64Encoded:   isBase64Encoded,
+		}, nil
+	}
+}
+
+func newAPIGatewayV2Request(event events.APIGatewayV2HTTPRequest) *request {
+	header := singleValueHeader(event.Headers)
+	if len(event.Cookies) > 0 {
+		header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+	host := header.Get("Host")
+	if host == "" {
+		host = event.RequestContext.DomainName
+	}
+	return &request{
+		method:          event.RequestContext.HTTP.Method,
+		rawPath:         event.RawPath,
+		rawQuery:        event.RawQueryString,
+		header:          header,
+		body:            event.Body,
+		isBase64Encoded: event.IsBase64Encoded,
+		host:            host,
+		sourceIP:        event.RequestContext.HTTP.SourceIP,
+		clientCertPEM:   event.RequestContext.Authentication.ClientCert.ClientCertPem,
+	}
+}
+
+// REST API の path はデコード済みのため、エスケープし直す
+func newAPIGatewayV1Request(event APIGatewayV1Request) *request {
+	header := multiValueHeader(event.Headers, event.MultiValueHeaders)
+	host := header.Get("Host")
+	if host == "" {
+		host = event.RequestContext.DomainName
+	}
+	return &request{
+		method:          event.HTTPMethod,
+		rawPath:         (&url.URL{Path: event.Path}).EscapedPath(),
+		rawQuery:        encodeQuery(event.QueryStringParameters, event.MultiValueQueryStringParameters),
+		header:          header,
+		body:            event.Body,
+		isBase64Encoded: event.IsBase64Encoded,
+		host:            host,
+		sourceIP:        event.RequestContext.Identity.SourceIP,
+		clientCertPEM:   event.ClientCertPEM,
+	}
+}
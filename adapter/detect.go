@@ -0,0 +1,97 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// EventSource は Lambda を呼び出したイベントの種類
+type EventSource string
+
+const (
+	SourceAPIGatewayV1 EventSource = "apigw_v1"
+	SourceAPIGatewayV2 EventSource = "apigw_v2"
+	SourceALB          EventSource = "alb"
+	SourceFunctionURL  EventSource = "function_url"
+)
+
+// ErrUnknownEvent は判別できない形式のイベントを受け取った場合のエラー
+var ErrUnknownEvent = errors.New("未対応のイベント形式です")
+
+// イベントの種類の判別に使う項目
+type eventProbe struct {
+	HTTPMethod     string `json:"httpMethod"`
+	RequestContext struct {
+		ELB        json.RawMessage `json:"elb"`
+		HTTP       json.RawMessage `json:"http"`
+		DomainName string          `json:"domainName"`
+	} `json:"requestContext"`
+}
+
+// DetectEventSource はペイロードからイベントの種類を判別する。
+//   - requestContext.elb があれば ALB
+//   - requestContext.http があればペイロード形式 2.0 で、ドメインが *.lambda-url.* なら Function URL、それ以外は HTTP API
+//   - httpMethod があればペイロード形式 1.0 (REST API)
+func DetectEventSource(payload []byte) (EventSource, error) {
+	var probe eventProbe
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return "", fmt.Errorf("イベントの解析に失敗しました: %w", err)
+	}
+	switch {
+	case len(probe.RequestContext.ELB) > 0:
+		return SourceALB, nil
+	case len(probe.RequestContext.HTTP) > 0:
+		if strings.Contains(probe.RequestContext.DomainName, ".lambda-url.") {
+			return SourceFunctionURL, nil
+		}
+		return SourceAPIGatewayV2, nil
+	case probe.HTTPMethod != "":
+		return SourceAPIGatewayV1, nil
+	default:
+		return "", ErrUnknownEvent
+	}
+}
+
+// Handler は h を呼び出す Lambda ハンドラを返す。
+// イベントの種類をペイロードから判別して共通のリクエストに変換し、レスポンスは受け取ったイベントに対応する型で返す
+func Handler(h http.Handler) func(context.Context, json.RawMessage) (interface{}, error) {
+	v1, v2, alb, functionURL := APIGatewayV1(h), APIGatewayV2(h), ALB(h), FunctionURL(h)
+	return func(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+		source, err := DetectEventSource(payload)
+		if err != nil {
+			return nil, err
+		}
+		switch source {
+		case SourceAPIGatewayV1:
+			var event APIGatewayV1Request
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, fmt.Errorf("イベントの解析に失敗しました: %w", err)
+			}
+			return v1(ctx, event)
+		case SourceALB:
+			var event events.ALBTargetGroupRequest
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, fmt.Errorf("イベントの解析に失敗しました: %w", err)
+			}
+			return alb(ctx, event)
+		case SourceFunctionURL:
+			var event events.LambdaFunctionURLRequest
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, fmt.Errorf("イベントの解析に失敗しました: %w", err)
+			}
+			return functionURL(ctx, event)
+		default:
+			var event events.APIGatewayV2HTTPRequest
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, fmt.Errorf("イベントの解析に失敗しました: %w", err)
+			}
+			return v2(ctx, event)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	v1Event = `{
+		"resource": "/{proxy+}", "path": "/api/customers/account", "httpMethod": "GET",
+		"multiValueHeaders": {"Host": ["api.example.com"], "X-Multi": ["a", "b"]},
+		"requestContext": {"resourceId": "abc", "stage": "prod", "identity": {"sourceIp": "192.0.2.1"}},
+		"body": null, "isBase64Encoded": false
+	}`
+	v2Event = `{
+		"version": "2.0", "routeKey": "$default", "rawPath": "/api/customers/account", "rawQueryString": "x=1",
+		"headers": {"host": "api.example.com"},
+		"requestContext": {"domainName": "api.example.com", "http": {"method": "GET", "path": "/api/customers/account", "sourceIp": "192.0.2.1"}},
+		"isBase64Encoded": false
+	}`
+	albEvent = `{
+		"requestContext": {"elb": {"targetGroupArn": "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/gw/abc"}},
+		"httpMethod": "GET", "path": "/api/customers/account",
+		"multiValueQueryStringParameters": {"x": ["1", "2"]},
+		"multiValueHeaders": {"host": ["alb.example.com"], "x-forwarded-for": ["192.0.2.1"]},
+		"body": "", "isBase64Encoded": false
+	}`
+	functionURLEvent = `{
+		"version": "2.0", "routeKey": "$default", "rawPath": "/api/customers/account", "rawQueryString": "",
+		"headers": {"host": "abc.lambda-url.ap-northeast-1.on.aws"},
+		"requestContext": {"domainName": "abc.lambda-url.ap-northeast-1.on.aws", "http": {"method": "GET", "path": "/api/customers/account", "sourceIp": "192.0.2.1"}},
+		"isBase64Encoded": false
+	}`
+)
+
+func TestDetectEventSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    EventSource
+		wantErr error
+	}{
+		{name: "REST API", payload: v1Event, want: SourceAPIGatewayV1},
+		{name: "HTTP API", payload: v2Event, want: SourceAPIGatewayV2},
+		{name: "ALB", payload: albEvent, want: SourceALB},
+		{name: "Function URL", payload: functionURLEvent, want: SourceFunctionURL},
+		{name: "未対応のイベント", payload: `{"Records": []}`, wantErr: ErrUnknownEvent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectEventSource([]byte(tt.payload))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("DetectEventSource() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("DetectEventSource() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_RespondsWithMatchingType(t *testing.T) {
+	h := &recordingHandler{write: func(w http.ResponseWriter) {
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		_, _ = w.Write([]byte("ok"))
+	}}
+	handler := Handler(h)
+
+	resp, err := handler(context.Background(), []byte(v1Event))
+	if _, ok := resp.(events.APIGatewayProxyResponse); !ok || err != nil {
+		t.Errorf("REST API のレスポンス = %T, %v", resp, err)
+	}
+	if got := h.request.Header.Values("X-Multi"); len(got) != 2 {
+		t.Errorf("X-Multi = %v", got)
+	}
+
+	resp, err = handler(context.Background(), []byte(v2Event))
+	if v2, ok := resp.(events.APIGatewayV2HTTPResponse); !ok || err != nil || len(v2.Cookies) != 2 {
+		t.Errorf("HTTP API のレスポンス = %+v, %v", resp, err)
+	}
+
+	resp, err = handler(context.Background(), []byte(functionURLEvent))
+	if furl, ok := resp.(events.LambdaFunctionURLResponse); !ok || err != nil || len(furl.Cookies) != 2 {
+		t.Errorf("Function URL のレスポンス = %+v, %v", resp, err)
+	}
+
+	resp, err = handler(context.Background(), []byte(albEvent))
+	alb, ok := resp.(events.ALBTargetGroupResponse)
+	if !ok || err != nil {
+		t.Fatalf("ALB のレスポンス = %T, %v", resp, err)
+	}
+	if len(alb.MultiValueHeaders["Set-Cookie"]) != 2 || alb.Headers != nil {
+		t.Errorf("複数値ヘッダーのモードでは multiValueHeaders で返す: %+v", alb)
+	}
+	if got := h.request.URL.Query()["x"]; len(got) != 2 {
+		t.Errorf("query x = %v", got)
+	}
+
+	if _, err := handler(context.Background(), []byte(`{"Records": []}`)); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("未対応のイベントの error = %v, want ErrUnknownEvent", err)
+	}
+}
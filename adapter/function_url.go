@@ -0,0 +1,45 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FunctionURL は Lambda Function URL のイベントで h を呼び出す Lambda ハンドラを返す
+func FunctionURL(h http.Handler) func(context.Context, events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+	return func(ctx context.Context, event events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+		w := serve(ctx, h, newFunctionURLRequest(event))
+		body, isBase64Encoded := w.encodedBody()
+		return events.LambdaFunctionURLResponse{
+			StatusCode:      w.statusCode(),
+			Headers:         w.joinedHeaders("Set-Cookie"),
+			Cookies:         w.header.Values("Set-Cookie"),
+			Body:            body,
+			IsBase64Encoded: isBase64Encoded,
+		}, nil
+	}
+}
+
+func newFunctionURLRequest(event events.LambdaFunctionURLRequest) *request {
+	header := singleValueHeader(event.Headers)
+	if len(event.Cookies) > 0 {
+		header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+	host := header.Get("Host")
+	if host == "" {
+		host = event.RequestContext.DomainName
+	}
+	return &request{
+		method:          event.RequestContext.HTTP.Method,
+		rawPath:         event.RawPath,
+		rawQuery:        event.RawQueryString,
+		header:          header,
+		body:            event.Body,
+		isBase64Encoded: event.IsBase64Encoded,
+		host:            host,
+		sourceIP:        event.RequestContext.HTTP.SourceIP,
+	}
+}
@@ -0,0 +1,83 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FunctionURLStreaming はレスポンスストリーミング (InvokeMode: RESPONSE_STREAM) の Lambda Function URL で h を呼び出す Lambda ハンドラを返す。
+// h が書き込んだステータスとヘッダーを先に返し、ボディは書き込まれた順に Lambda へ渡すため、
+// 大きなファイルのダウンロードでもボディ全体をメモリに保持せず、6MB のペイロード上限も受けない。
+// provided.al2 / provided.al2023 ランタイム、または -tags lambda.norpc でビルドした場合のみ使える
+func FunctionURLStreaming(h http.Handler) func(context.Context, *events.LambdaFunctionURLRequest) (*events.LambdaFunctionURLStreamingResponse, error) {
+	return func(ctx context.Context, event *events.LambdaFunctionURLRequest) (*events.LambdaFunctionURLStreamingResponse, error) {
+		r, err := newFunctionURLRequest(*event).httpRequest(ctx)
+		if err != nil {
+			log.Printf("リクエストの変換に失敗しました: %v", err)
+			return &events.LambdaFunctionURLStreamingResponse{
+				StatusCode: http.StatusBadRequest,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Body:       strings.NewReader(`{"error":"Bad Request"}`),
+			}, nil
+		}
+
+		pr, pw := io.Pipe()
+		w := &streamingResponseWriter{header: make(http.Header), body: pw, ready: make(chan streamedHeader, 1)}
+		go func() {
+			defer pw.Close()
+			// ボディを書き込まずに終了した場合もステータスを返す
+			defer w.WriteHeader(http.StatusOK)
+			h.ServeHTTP(w, r)
+		}()
+
+		head := <-w.ready
+		resp := &events.LambdaFunctionURLStreamingResponse{
+			StatusCode: head.status,
+			Body:       pr,
+		}
+		if len(head.header) > 0 {
+			resp.Headers = joinHeaders(head.header, "Set-Cookie")
+			resp.Cookies = head.header.Values("Set-Cookie")
+		}
+		return resp, nil
+	}
+}
+
+type streamedHeader struct {
+	status int
+	header http.Header
+}
+
+// streamingResponseWriter は最初の書き込みでステータスとヘッダーを確定し、ボディをパイプで逐次渡す
+type streamingResponseWriter struct {
+	header http.Header
+	body   *io.PipeWriter
+	once   sync.Once
+	ready  chan streamedHeader
+}
+
+func (w *streamingResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *streamingResponseWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.ready <- streamedHeader{status: status, header: w.header.Clone()}
+	})
+}
+
+func (w *streamingResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Flush は http.Flusher を満たす。パイプはバッファしないため、書き込んだ時点で Lambda に渡っている
+func (w *streamingResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,91 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/aki80204/go-gateway/proxy"
+)
+
+func TestFunctionURLStreaming_StreamsBody(t *testing.T) {
+	release := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Add("Set-Cookie", "a=1")
+		_, _ = w.Write([]byte("id,amount\n"))
+		<-release
+		_, _ = w.Write([]byte("1,100\n"))
+	})
+	event := &events.LambdaFunctionURLRequest{
+		RawPath:        "/api/customers/account/statements/export",
+		RequestContext: events.LambdaFunctionURLRequestContext{HTTP: events.LambdaFunctionURLRequestContextHTTPDescription{Method: http.MethodGet}},
+	}
+
+	// ボディをすべて書き込む前にステータスとヘッダーを返す
+	resp, err := FunctionURLStreaming(h)(context.Background(), event)
+	if err != nil {
+		t.Fatalf("FunctionURLStreaming() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Headers["Content-Type"] != "text/csv" || len(resp.Cookies) != 1 {
+		t.Errorf("response = %d %v %v", resp.StatusCode, resp.Headers, resp.Cookies)
+	}
+
+	first := make([]byte, len("id,amount\n"))
+	if _, err := io.ReadFull(resp.Body, first); err != nil || string(first) != "id,amount\n" {
+		t.Fatalf("最初のチャンク = %q, %v", first, err)
+	}
+	close(release)
+	rest, err := io.ReadAll(resp.Body)
+	if err != nil || string(rest) != "1,100\n" {
+		t.Errorf("残りのボディ = %q, %v", rest, err)
+	}
+}
+
+func TestFunctionURLStreaming_NoBody(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	resp, err := FunctionURLStreaming(h)(context.Background(), &events.LambdaFunctionURLRequest{RawPath: "/"})
+	if err != nil {
+		t.Fatalf("FunctionURLStreaming() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want 200", resp.StatusCode)
+	}
+	if body, _ := io.ReadAll(resp.Body); len(body) != 0 {
+		t.Errorf("Body = %q, want empty", body)
+	}
+}
+
+// バックエンドのヘッダーとボディをそのまま中継する
+func TestFunctionURLStreaming_ProxiesUpstream(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="statement.csv"`)
+		_, _ = w.Write([]byte("id,amount\n1,100\n"))
+	}))
+	defer backend.Close()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy.ProxyRequest(w, r, backend.URL, nil)
+	})
+	event := &events.LambdaFunctionURLRequest{
+		RawPath:        "/api/customers/account/statements/export",
+		RawQueryString: "format=csv",
+		RequestContext: events.LambdaFunctionURLRequestContext{HTTP: events.LambdaFunctionURLRequestContextHTTPDescription{Method: http.MethodGet}},
+	}
+
+	resp, err := FunctionURLStreaming(h)(context.Background(), event)
+	if err != nil {
+		t.Fatalf("FunctionURLStreaming() error = %v", err)
+	}
+	if resp.Headers["Content-Disposition"] != `attachment; filename="statement.csv"` {
+		t.Errorf("Headers = %v", resp.Headers)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "id,amount\n1,100\n" {
+		t.Errorf("Body = %q", body)
+	}
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// API キーを読み取るヘッダーの既定値
+const defaultAPIKeyHeader = "X-API-Key"
+
+// ErrAPIKeyNotFound は API キーがストアに登録されていないことを表す
+var ErrAPIKeyNotFound = errors.New("API キーが見つかりません")
+
+// APIKey は API キーに紐づく呼び出し元の情報
+type APIKey struct {
+	Identity      string   `json:"identity"`
+	Scopes        []string `json:"scopes"`
+	RateLimitTier string   `json:"rate_limit_tier"`
+}
+
+// APIKeyRecord はストアに登録する API キー。キーそのものではなく HashAPIKey のハッシュを保存する
+type APIKeyRecord struct {
+	KeyHash string `json:"key_hash"`
+	APIKey
+}
+
+// APIKeyStore は API キーのハッシュから APIKey を引く。登録されていない場合は ErrAPIKeyNotFound を返す
+type APIKeyStore interface {
+	LookupAPIKey(ctx context.Context, keyHash string) (*APIKey, error)
+}
+
+var (
+	_ APIKeyStore = (*MemoryAPIKeyStore)(nil)
+	_ APIKeyStore = (*DynamoDBAPIKeyStore)(nil)
+)
+
+// HashAPIKey は API キーを保存・照合用の SHA-256 の 16 進文字列に変換する。
+// API キーは十分な長さのランダム値であることを前提とし、パスワード用の低速なハッシュは使わない。
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryAPIKeyStore はメモリ上に API キーを保持するストア
+type MemoryAPIKeyStore struct {
+	keys map[string]APIKey
+}
+
+// NewMemoryAPIKeyStore は records から MemoryAPIKeyStore を生成する
+func NewMemoryAPIKeyStore(records []APIKeyRecord) (*MemoryAPIKeyStore, error) {
+	keys := make(map[string]APIKey, len(records))
+	for _, r := range records {
+		hash := strings.ToLower(r.KeyHash)
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("key_hash は SHA-256 の 16 進文字列で指定してください: identity=%s", r.Identity)
+		}
+		if r.Identity == "" {
+			return nil, errors.New("API キーの identity は必須です")
+		}
+		if _, dup := keys[hash]; dup {
+			return nil, fmt.Errorf("key_hash が重複しています: identity=%s", r.Identity)
+		}
+		keys[hash] = r.APIKey
+	}
+	return &MemoryAPIKeyStore{keys: keys}, nil
+}
+
+// NewAPIKeyStoreFromJSON は APIKeyRecord の JSON 配列から MemoryAPIKeyStore を生成する
+func NewAPIKeyStoreFromJSON(data []byte) (*MemoryAPIKeyStore, error) {
+	var records []APIKeyRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("API キーの JSON の形式が不正です: %w", err)
+	}
+	return NewMemoryAPIKeyStore(records)
+}
+
+// NewFileAPIKeyStore は APIKeyRecord の JSON 配列を記載したファイルから MemoryAPIKeyStore を生成する
+func NewFileAPIKeyStore(path string) (*MemoryAPIKeyStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("API キーファイルの読み込みに失敗しました: %w", err)
+	}
+	return NewAPIKeyStoreFromJSON(data)
+}
+
+func (s *MemoryAPIKeyStore) LookupAPIKey(_ context.Context, keyHash string) (*APIKey, error) {
+	key, ok := s.keys[keyHash]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+// DynamoDBItemGetter は DynamoDB の GetItem に相当する最小限のインターフェース。
+// aws-sdk-go-v2 の dynamodb.Client をラップし、属性値を Go の値 (string, []string など) に変換して返す実装を想定している。
+// アイテムが存在しない場合は nil, nil を返す。
+type DynamoDBItemGetter interface {
+	GetItem(ctx context.Context, table string, key map[string]string) (map[string]interface{}, error)
+}
+
+// DynamoDBAPIKeyStore は key_hash をパーティションキーとする DynamoDB テーブルから API キーを引くストア
+type DynamoDBAPIKeyStore struct {
+	client DynamoDBItemGetter
+	table  string
+}
+
+// NewDynamoDBAPIKeyStore は DynamoDBAPIKeyStore を生成する
+func NewDynamoDBAPIKeyStore(client DynamoDBItemGetter, table string) *DynamoDBAPIKeyStore {
+	return &DynamoDBAPIKeyStore{client: client, table: table}
+}
+
+func (s *DynamoDBAPIKeyStore) LookupAPIKey(ctx context.Context, keyHash string) (*APIKey, error) {
+	item, err := s.client.GetItem(ctx, s.table, map[string]string{"key_hash": keyHash})
+	if err != nil {
+		return nil, fmt.Errorf("API キーの取得に失敗しました: %w", err)
+	}
+	if item == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	identity, _ := item["identity"].(string)
+	if identity == "" {
+		return nil, fmt.Errorf("API キーの identity が登録されていません: table=%s", s.table)
+	}
+	tier, _ := item["rate_limit_tier"].(string)
+	return &APIKey{
+		Identity:      identity,
+		Scopes:        stringList(item["scopes"]),
+		RateLimitTier: tier,
+	}, nil
+}
+
+// APIKeyAuthenticator はヘッダーまたはクエリパラメータの API キーで認証する
+type APIKeyAuthenticator struct {
+	Store APIKeyStore
+	// Header は API キーを読み取るヘッダー名。空の場合は X-API-Key
+	Header string
+	// QueryParam を指定した場合、ヘッダーがないリクエストはこのクエリパラメータから API キーを読み取る
+	QueryParam string
+}
+
+// 環境変数から APIKeyAuthenticator を初期化する
+//
+//   - API_KEYS             APIKeyRecord の JSON 配列
+//   - API_KEYS_FILE        APIKeyRecord の JSON 配列を記載したファイルのパス (API_KEYS より優先)
+//   - API_KEY_HEADER       API キーを読み取るヘッダー名 (既定: X-API-Key)
+//   - API_KEY_QUERY_PARAM  API キーを読み取るクエリパラメータ名 (既定: 参照しない)
+func NewAPIKeyAuthenticatorFromEnv() (*APIKeyAuthenticator, error) {
+	var store *MemoryAPIKeyStore
+	var err error
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		store, err = NewFileAPIKeyStore(path)
+	} else {
+		store, err = NewAPIKeyStoreFromJSON([]byte(os.Getenv("API_KEYS")))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIKeyAuthenticator{
+		Store:      store,
+		Header:     os.Getenv("API_KEY_HEADER"),
+		QueryParam: os.Getenv("API_KEY_QUERY_PARAM"),
+	}, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := a.Header
+	if header == "" {
+		header = defaultAPIKeyHeader
+	}
+
+	key := strings.TrimSpace(r.Header.Get(header))
+	fromQuery := false
+	if key == "" && a.QueryParam != "" {
+		key = strings.TrimSpace(r.URL.Query().Get(a.QueryParam))
+		fromQuery = true
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	apiKey, err := a.Store.LookupAPIKey(r.Context(), HashAPIKey(key))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, errors.New("API キーが無効です")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// API キーをバックエンドやそのアクセスログに渡さないよう、転送するリクエストから取り除く
+	if fromQuery {
+		r.URL.RawQuery = removeQueryParam(r.URL.RawQuery, a.QueryParam)
+	} else {
+		r.Header.Del(header)
+	}
+
+	// JWT と同じ形の claim にしておき、claim ヘッダーの転送設定をそのまま使えるようにする
+	claims := jwt.MapClaims{
+		"sub":             apiKey.Identity,
+		"scope":           strings.Join(apiKey.Scopes, " "),
+		"rate_limit_tier": apiKey.RateLimitTier,
+	}
+	return &Principal{
+		Subject: apiKey.Identity,
+		Scopes:  apiKey.Scopes,
+		Claims:  claims,
+	}, nil
+}
+
+// rawQuery から name のパラメータをすべて取り除く。ほかのパラメータは順序とエンコードを変えない
+func removeQueryParam(rawQuery, name string) string {
+	var kept []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		k, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(k); err == nil && unescaped == name {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	return strings.Join(kept, "&")
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// DynamoDBItemGetter のローカル実装。items はパーティションキーの値ごとのアイテム
+type fakeDynamoDB struct {
+	items map[string]map[string]interface{}
+	err   error
+}
+
+func (f *fakeDynamoDB) GetItem(_ context.Context, table string, key map[string]string) (map[string]interface{}, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if table != "api-keys" {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+	return f.items[key["key_hash"]], nil
+}
+
+func TestAPIKeyAuthenticator_Authenticate(t *testing.T) {
+	store, err := NewMemoryAPIKeyStore([]APIKeyRecord{
+		{KeyHash: HashAPIKey("batch-key"), APIKey: APIKey{Identity: "batch-job", Scopes: []string{"read:balance"}, RateLimitTier: "bulk"}},
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryAPIKeyStore() エラー = %v", err)
+	}
+	a := &APIKeyAuthenticator{Store: store, QueryParam: "api_key"}
+
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		query     map[string]string
+		wantSub   string
+		wantError error
+		errorMsg  string
+	}{
+		{name: "正常系: ヘッダー (小文字)", headers: map[string]string{"x-api-key": "batch-key"}, wantSub: "batch-job"},
+		{name: "正常系: ヘッダー", headers: map[string]string{"X-API-Key": "batch-key"}, wantSub: "batch-job"},
+		{name: "正常系: クエリパラメータ", query: map[string]string{"api_key": "batch-key"}, wantSub: "batch-job"},
+		{name: "エラー: 未登録のキー", headers: map[string]string{"x-api-key": "unknown"}, errorMsg: "API キーが無効です"},
+		{name: "エラー: API キーなし", headers: map[string]string{"authorization": "Bearer token"}, wantError: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{}
+			for k, v := range tt.query {
+				query.Set(k, v)
+			}
+			p, err := a.Authenticate(newTestRequest(http.MethodGet, "/api/customers/account?"+query.Encode(), tt.headers))
+			if tt.wantError != nil || tt.errorMsg != "" {
+				if tt.wantError != nil && !errors.Is(err, tt.wantError) {
+					t.Errorf("Authenticate() エラー = %v, 期待値 = %v", err, tt.wantError)
+				}
+				if tt.errorMsg != "" && (err == nil || !contains(err.Error(), tt.errorMsg)) {
+					t.Errorf("Authenticate() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.errorMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() エラー = %v, 期待値 = nil", err)
+			}
+			if p.Subject != tt.wantSub || !p.HasScope("read:balance") {
+				t.Errorf("Authenticate() principal = %+v", p)
+			}
+			if p.Claims["rate_limit_tier"] != "bulk" || p.Claims["sub"] != "batch-job" {
+				t.Errorf("Authenticate() claims = %v", p.Claims)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticator_RemovesCredential(t *testing.T) {
+	store, _ := NewMemoryAPIKeyStore([]APIKeyRecord{{KeyHash: HashAPIKey("batch-key"), APIKey: APIKey{Identity: "batch-job"}}})
+	a := &APIKeyAuthenticator{Store: store, QueryParam: "api_key"}
+
+	tests := []struct {
+		name      string
+		target    string
+		headers   map[string]string
+		wantQuery string
+	}{
+		{name: "クエリパラメータの API キーを取り除く", target: "/?from=2024-01-01&api_key=batch-key&to=2024%2F12", wantQuery: "from=2024-01-01&to=2024%2F12"},
+		{name: "エンコードされたパラメータ名も取り除く", target: "/?api%5Fkey=batch-key&from=2024-01-01", wantQuery: "from=2024-01-01"},
+		{name: "API キーだけのクエリは空にする", target: "/?api_key=batch-key", wantQuery: ""},
+		{name: "ヘッダーの API キーで認証した場合はクエリを変えない", target: "/?api_key=other&from=2024-01-01", headers: map[string]string{"x-api-key": "batch-key"}, wantQuery: "api_key=other&from=2024-01-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRequest(http.MethodGet, tt.target, tt.headers)
+			if _, err := a.Authenticate(r); err != nil {
+				t.Fatalf("Authenticate() エラー = %v, 期待値 = nil", err)
+			}
+			if r.URL.RawQuery != tt.wantQuery {
+				t.Errorf("RawQuery = %q, 期待値 = %q", r.URL.RawQuery, tt.wantQuery)
+			}
+			if v := r.Header.Get("X-API-Key"); v != "" {
+				t.Errorf("X-API-Key = %q, 期待値 = 取り除かれている", v)
+			}
+		})
+	}
+
+	// 認証に失敗した場合はクエリを変えない
+	r := newTestRequest(http.MethodGet, "/?api_key=unknown", nil)
+	if _, err := a.Authenticate(r); err == nil {
+		t.Fatal("Authenticate() エラー = nil, 期待値 = エラー")
+	}
+	if r.URL.RawQuery != "api_key=unknown" {
+		t.Errorf("RawQuery = %q, 期待値 = %q", r.URL.RawQuery, "api_key=unknown")
+	}
+}
+
+func TestAPIKeyAuthenticator_CustomHeader(t *testing.T) {
+	store, _ := NewMemoryAPIKeyStore([]APIKeyRecord{{KeyHash: HashAPIKey("k"), APIKey: APIKey{Identity: "partner"}}})
+	a := &APIKeyAuthenticator{Store: store, Header: "X-Partner-Key"}
+
+	if _, err := a.Authenticate(newTestRequest(http.MethodGet, "/", map[string]string{"x-api-key": "k"})); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Authenticate() エラー = %v, 期待値 = ErrNoCredentials", err)
+	}
+	// QueryParam を指定していない場合はクエリパラメータを参照しない
+	if _, err := a.Authenticate(newTestRequest(http.MethodGet, "/?api_key=k", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Authenticate() エラー = %v, 期待値 = ErrNoCredentials", err)
+	}
+	p, err := a.Authenticate(newTestRequest(http.MethodGet, "/", map[string]string{"x-partner-key": "k"}))
+	if err != nil || p.Subject != "partner" {
+		t.Errorf("Authenticate() = %+v, %v", p, err)
+	}
+}
+
+func TestNewMemoryAPIKeyStore_InvalidRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []APIKeyRecord
+	}{
+		{"エラー: ハッシュではない", []APIKeyRecord{{KeyHash: "plain-key", APIKey: APIKey{Identity: "a"}}}},
+		{"エラー: identity がない", []APIKeyRecord{{KeyHash: HashAPIKey("k")}}},
+		{"エラー: ハッシュが重複", []APIKeyRecord{
+			{KeyHash: HashAPIKey("k"), APIKey: APIKey{Identity: "a"}},
+			{KeyHash: HashAPIKey("k"), APIKey: APIKey{Identity: "b"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMemoryAPIKeyStore(tt.records); err == nil {
+				t.Errorf("NewMemoryAPIKeyStore() エラーが期待されましたが、nil が返されました")
+			}
+		})
+	}
+}
+
+func TestNewAPIKeyAuthenticatorFromEnv(t *testing.T) {
+	records := `[{"key_hash":"` + HashAPIKey("env-key") + `","identity":"env-client","scopes":["a"],"rate_limit_tier":"gold"}]`
+
+	t.Run("正常系: API_KEYS", func(t *testing.T) {
+		t.Setenv("API_KEYS", records)
+		t.Setenv("API_KEYS_FILE", "")
+		t.Setenv("API_KEY_HEADER", "X-Client-Key")
+		a, err := NewAPIKeyAuthenticatorFromEnv()
+		if err != nil {
+			t.Fatalf("NewAPIKeyAuthenticatorFromEnv() エラー = %v", err)
+		}
+		p, err := a.Authenticate(newTestRequest(http.MethodGet, "/", map[string]string{"x-client-key": "env-key"}))
+		if err != nil || p.Subject != "env-client" {
+			t.Errorf("Authenticate() = %+v, %v", p, err)
+		}
+	})
+
+	t.Run("正常系: API_KEYS_FILE", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "api-keys.json")
+		if err := os.WriteFile(path, []byte(records), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("API_KEYS", "")
+		t.Setenv("API_KEYS_FILE", path)
+		t.Setenv("API_KEY_HEADER", "")
+		a, err := NewAPIKeyAuthenticatorFromEnv()
+		if err != nil {
+			t.Fatalf("NewAPIKeyAuthenticatorFromEnv() エラー = %v", err)
+		}
+		key, err := a.Store.LookupAPIKey(context.Background(), HashAPIKey("env-key"))
+		if err != nil || !reflect.DeepEqual(*key, APIKey{Identity: "env-client", Scopes: []string{"a"}, RateLimitTier: "gold"}) {
+			t.Errorf("LookupAPIKey() = %+v, %v", key, err)
+		}
+	})
+
+	t.Run("エラー: ファイルが存在しない", func(t *testing.T) {
+		t.Setenv("API_KEYS_FILE", filepath.Join(t.TempDir(), "missing.json"))
+		if _, err := NewAPIKeyAuthenticatorFromEnv(); err == nil {
+			t.Errorf("NewAPIKeyAuthenticatorFromEnv() エラーが期待されましたが、nil が返されました")
+		}
+	})
+}
+
+func TestDynamoDBAPIKeyStore(t *testing.T) {
+	db := &fakeDynamoDB{items: map[string]map[string]interface{}{
+		HashAPIKey("partner-key"): {
+			"key_hash":        HashAPIKey("partner-key"),
+			"identity":        "partner-a",
+			"scopes":          []string{"read:balance", "write:balance"},
+			"rate_limit_tier": "standard",
+		},
+		HashAPIKey("broken-key"): {"key_hash": HashAPIKey("broken-key")},
+	}}
+	store := NewDynamoDBAPIKeyStore(db, "api-keys")
+
+	key, err := store.LookupAPIKey(context.Background(), HashAPIKey("partner-key"))
+	want := APIKey{Identity: "partner-a", Scopes: []string{"read:balance", "write:balance"}, RateLimitTier: "standard"}
+	if err != nil || !reflect.DeepEqual(*key, want) {
+		t.Errorf("LookupAPIKey() = %+v, %v, 期待値 = %+v", key, err, want)
+	}
+
+	if _, err := store.LookupAPIKey(context.Background(), HashAPIKey("unknown")); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("LookupAPIKey(unknown) エラー = %v, 期待値 = ErrAPIKeyNotFound", err)
+	}
+	if _, err := store.LookupAPIKey(context.Background(), HashAPIKey("broken-key")); err == nil {
+		t.Errorf("LookupAPIKey(broken-key) エラーが期待されましたが、nil が返されました")
+	}
+
+	db.err = errors.New("throttled")
+	if _, err := store.LookupAPIKey(context.Background(), HashAPIKey("partner-key")); err == nil || !contains(err.Error(), "throttled") {
+		t.Errorf("LookupAPIKey() エラー = %v, 期待値に含まれるべき文字列 = throttled", err)
+	}
+}
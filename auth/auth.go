@@ -1,19 +1,66 @@
 package auth
 
-import "github.com/aws/aws-lambda-go/events"
+import (
+	"errors"
+	"net/http"
 
-func CheckAuth(v Validator, request events.APIGatewayV2HTTPRequest) (string, error) {
-	authHeader := request.Headers["Authorization"]
-	if authHeader == "" {
-		authHeader = request.Headers["authorization"]
-	}
-	tokenString, err := ExtractBearerToken(authHeader)
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenValidator はアクセストークンを検証し、有効な場合は claim を返す
+type TokenValidator interface {
+	ValidateToken(tokenString string) (jwt.MapClaims, error)
+}
+
+// RouteRestrictor は issuer ごとに受け付けるルートを制限する TokenValidator が実装する
+type RouteRestrictor interface {
+	AllowsRoute(issuer, path string) bool
+}
+
+var (
+	_ TokenValidator  = (*Validator)(nil)
+	_ RouteRestrictor = (*Validator)(nil)
+	_ TokenValidator  = (*IntrospectionValidator)(nil)
+	_ TokenValidator  = (*ChainValidator)(nil)
+	_ RouteRestrictor = (*ChainValidator)(nil)
+	_ TokenValidator  = (*RevocationValidator)(nil)
+	_ RouteRestrictor = (*RevocationValidator)(nil)
+	_ TokenValidator  = (*LazyValidator)(nil)
+	_ RouteRestrictor = (*LazyValidator)(nil)
+)
+
+// CheckAuth は Authorization ヘッダーのトークンを検証し、認証済みの Principal を返す。
+// クライアント証明書に紐付いたトークン (RFC 8705) は、提示された証明書と一致する場合のみ受け付ける。
+// DPoP に紐付いたトークン (RFC 9449) は Bearer では受け付けない。
+func CheckAuth(v TokenValidator, r *http.Request) (*Principal, error) {
+	tokenString, err := ExtractBearerToken(r.Header.Get("Authorization"))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	claims, err := v.ValidateToken(tokenString)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if _, bound, err := confirmationClaim(claims, dpopThumbprintConfirmation); err != nil {
+		return nil, err
+	} else if bound {
+		return nil, errors.New("DPoP に紐付いたトークンは DPoP スキームで送信してください")
+	}
+	return newTokenPrincipal(v, claims, r)
+}
+
+// 検証済みの claim から Principal を生成し、証明書の紐付けと issuer ごとのルート制限を確認する
+func newTokenPrincipal(v TokenValidator, claims jwt.MapClaims, r *http.Request) (*Principal, error) {
+	if err := verifyCertificateBinding(claims, r); err != nil {
+		return nil, err
+	}
+	principal, err := NewPrincipal(claims)
+	if err != nil {
+		return nil, err
+	}
+	// issuer ごとに許可されたルート以外では受け付けない
+	if rr, ok := v.(RouteRestrictor); ok && !rr.AllowsRoute(principal.Issuer, r.URL.EscapedPath()) {
+		return nil, errors.New("この issuer のトークンはこのルートでは利用できません")
 	}
-	return claims["sub"].(string), nil
+	return principal, nil
 }
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ルーティング設定で指定する認証方式の名前
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+	MethodHMAC   = "hmac"
+	MethodMTLS   = "mtls"
+	MethodDPoP   = "dpop"
+)
+
+// MethodSeparator はすべて満たす必要がある認証方式を連結する区切り文字 (例: "jwt+mtls")
+const MethodSeparator = "+"
+
+// ErrNoCredentials は認証方式が扱う認証情報がリクエストに含まれていないことを表す
+var ErrNoCredentials = errors.New("認証情報が存在しません")
+
+// Authenticator はリクエストの認証情報を検証し、認証済みの Principal を返す。
+// 扱う認証情報がリクエストに含まれていない場合は ErrNoCredentials を返す。
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+var (
+	_ Authenticator = (*BearerAuthenticator)(nil)
+	_ Authenticator = (*APIKeyAuthenticator)(nil)
+	_ Authenticator = (*HMACAuthenticator)(nil)
+	_ Authenticator = (*MTLSAuthenticator)(nil)
+	_ Authenticator = (*DPoPAuthenticator)(nil)
+	_ Authenticator = (*ChainAuthenticator)(nil)
+	_ Authenticator = (*AllAuthenticator)(nil)
+)
+
+// BearerAuthenticator は Authorization: Bearer のトークンを TokenValidator で検証する。
+// DPoP スキームのトークンは DPoPAuthenticator に任せる
+type BearerAuthenticator struct {
+	Validator TokenValidator
+}
+
+func (a *BearerAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" || hasAuthorizationScheme(authorization, schemeDPoP) {
+		return nil, ErrNoCredentials
+	}
+	return CheckAuth(a.Validator, r)
+}
+
+// ChainAuthenticator はリクエストに認証情報が含まれている最初の認証方式で認証する
+type ChainAuthenticator struct {
+	authenticators []Authenticator
+}
+
+// NewChainAuthenticator は authenticators を指定した順に試す ChainAuthenticator を生成する。nil は無視する
+func NewChainAuthenticator(authenticators ...Authenticator) *ChainAuthenticator {
+	c := &ChainAuthenticator{}
+	for _, a := range authenticators {
+		if a != nil {
+			c.authenticators = append(c.authenticators, a)
+		}
+	}
+	return c
+}
+
+func (c *ChainAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c.authenticators {
+		principal, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// AllAuthenticator はすべての認証方式での認証を要求する。
+// Principal は先頭の認証方式のものを使い、後続の認証方式で得たクライアント証明書を付与する。
+type AllAuthenticator struct {
+	authenticators []Authenticator
+}
+
+// NewAllAuthenticator は authenticators をすべて要求する AllAuthenticator を生成する。nil は無視する
+func NewAllAuthenticator(authenticators ...Authenticator) *AllAuthenticator {
+	a := &AllAuthenticator{}
+	for _, v := range authenticators {
+		if v != nil {
+			a.authenticators = append(a.authenticators, v)
+		}
+	}
+	return a
+}
+
+// 認証情報がひとつも含まれていない場合は ErrNoCredentials を、一部だけ含まれている場合は認証エラーを返す
+func (a *AllAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	var principal *Principal
+	missing := 0
+	for _, v := range a.authenticators {
+		p, err := v.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			missing++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if principal == nil {
+			principal = p
+		} else if p.ClientCert != nil {
+			principal.ClientCert = p.ClientCert
+		}
+	}
+	if missing == len(a.authenticators) {
+		return nil, ErrNoCredentials
+	}
+	if missing > 0 {
+		return nil, errors.New("必要な認証情報が不足しています")
+	}
+	return principal, nil
+}
+
+// リクエストボディを読み取り、後続の認証方式やプロキシでも読めるように戻す
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// headers を設定したテスト用のリクエストを生成する
+func newTestRequest(method, target string, headers map[string]string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	return r
+}
+
+func TestChainAuthenticator_Authenticate(t *testing.T) {
+	bearer := &BearerAuthenticator{Validator: &fakeValidator{
+		tokens: map[string]jwt.MapClaims{"jwt-token": {"sub": "jwt-user"}},
+		err:    errors.New("トークンが無効です"),
+	}}
+	store, _ := NewMemoryAPIKeyStore([]APIKeyRecord{{KeyHash: HashAPIKey("api-key"), APIKey: APIKey{Identity: "batch"}}})
+	chain := NewChainAuthenticator(bearer, nil, &APIKeyAuthenticator{Store: store})
+
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		wantSub   string
+		wantError string
+	}{
+		{name: "正常系: Bearer トークン", headers: map[string]string{"authorization": "Bearer jwt-token"}, wantSub: "jwt-user"},
+		{name: "正常系: API キー", headers: map[string]string{"x-api-key": "api-key"}, wantSub: "batch"},
+		{name: "エラー: 無効な Bearer トークンは API キーに回さない", headers: map[string]string{"authorization": "Bearer bad", "x-api-key": "api-key"}, wantError: "トークンが無効です"},
+		{name: "エラー: 認証情報なし", headers: map[string]string{}, wantError: ErrNoCredentials.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := chain.Authenticate(newTestRequest(http.MethodGet, "/api/customers/account", tt.headers))
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("Authenticate() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil || p.Subject != tt.wantSub {
+				t.Errorf("Authenticate() = %+v, %v, 期待値 sub = %v", p, err, tt.wantSub)
+			}
+		})
+	}
+}
+
+func TestAllAuthenticator_Authenticate(t *testing.T) {
+	bearer := &BearerAuthenticator{Validator: &fakeValidator{
+		tokens: map[string]jwt.MapClaims{"jwt-token": {"sub": "jwt-user"}},
+		err:    errors.New("トークンが無効です"),
+	}}
+	mtls, _ := NewMTLSAuthenticator(MTLSConfig{})
+	all := NewAllAuthenticator(bearer, mtls)
+
+	cert := generateClientCertPEM(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "batch"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	})
+	request := func(authorization, certPEM string) *http.Request {
+		r := makeMTLSRequest(t, certPEM)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name      string
+		request   *http.Request
+		wantError string
+	}{
+		{name: "正常系: Bearer トークンとクライアント証明書", request: request("Bearer jwt-token", cert)},
+		{name: "エラー: クライアント証明書がない", request: request("Bearer jwt-token", ""), wantError: "不足"},
+		{name: "エラー: 無効な Bearer トークン", request: request("Bearer bad", cert), wantError: "トークンが無効です"},
+		{name: "エラー: 認証情報なし", request: request("", ""), wantError: ErrNoCredentials.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := all.Authenticate(tt.request)
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("Authenticate() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() エラー = %v", err)
+			}
+			if p.Subject != "jwt-user" || p.ClientCert == nil || p.ClientCert.Identity != "batch" {
+				t.Errorf("Authenticate() = %+v, 期待値 sub = jwt-user, 証明書 = batch", p)
+			}
+		})
+	}
+}
@@ -2,24 +2,46 @@ package auth
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 )
 
+// Authorization ヘッダーの認証スキーム
+const (
+	schemeBearer = "Bearer"
+	schemeDPoP   = "DPoP"
+)
+
 // "Bearer <token>"形式のAuthorizationヘッダーからトークンを取り出す
 func ExtractBearerToken(header string) (string, error) {
+	return extractAuthorizationToken(header, schemeBearer)
+}
+
+// ExtractDPoPToken は "DPoP <token>" 形式 (RFC 9449) の Authorization ヘッダーからトークンを取り出す
+func ExtractDPoPToken(header string) (string, error) {
+	return extractAuthorizationToken(header, schemeDPoP)
+}
+
+func extractAuthorizationToken(header, scheme string) (string, error) {
 	if strings.TrimSpace(header) == "" {
 		return "", errors.New("authorization ヘッダーが存在しません")
 	}
 
 	parts := strings.Fields(header)
-	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-		return "", errors.New("authorization ヘッダーが Bearer <token> 形式ではありません")
+	if len(parts) != 2 || !strings.EqualFold(parts[0], scheme) {
+		return "", fmt.Errorf("authorization ヘッダーが %s <token> 形式ではありません", scheme)
 	}
 
 	token := strings.TrimSpace(parts[1])
 	if token == "" {
-		return "", errors.New("authorization ヘッダーが Bearer <token> 形式ではありません")
+		return "", fmt.Errorf("authorization ヘッダーが %s <token> 形式ではありません", scheme)
 	}
 
 	return token, nil
 }
+
+// Authorization ヘッダーの認証スキームが scheme かを返す
+func hasAuthorizationScheme(header, scheme string) bool {
+	fields := strings.Fields(header)
+	return len(fields) > 0 && strings.EqualFold(fields[0], scheme)
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// lruCache は件数上限と有効期限を持つ LRU キャッシュ。Lambda のウォームコンテナ内で共有される
+type lruCache[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+	now      func() time.Time
+}
+
+type lruEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+func newLRUCache[V any](capacity int) *lruCache[V] {
+	return &lruCache[V]{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Get は有効期限内の値を返す。期限切れの値は削除する
+func (c *lruCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*lruEntry[V])
+	if !c.now().Before(entry.expiresAt) {
+		c.removeElement(el)
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+// Add は expiresAt まで有効な値を追加する。上限を超えた場合は最も使われていない値を捨てる
+func (c *lruCache[V]) Add(key string, value V, expiresAt time.Time) {
+	if c.capacity <= 0 || !c.now().Before(expiresAt) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry[V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	for c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// AddIfAbsent で追加できなかった理由
+var (
+	errCacheKeyExists = errors.New("有効期限内の値が既に存在します")
+	errCacheFull      = errors.New("有効期限内の値で上限に達しています")
+)
+
+// AddIfAbsent は key が有効期限内の値として存在しない場合にだけ追加する。既に存在する場合は errCacheKeyExists を返す。
+// リプレイ検知に使うため、上限に達している場合は期限切れの値だけを削除し、
+// 有効期限内の値で埋まっている場合は追い出さずに errCacheFull を返す
+func (c *lruCache[V]) AddIfAbsent(key string, value V, expiresAt time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		if c.now().Before(el.Value.(*lruEntry[V]).expiresAt) {
+			return errCacheKeyExists
+		}
+		c.removeElement(el)
+	}
+	if c.capacity <= 0 || !c.now().Before(expiresAt) {
+		return nil
+	}
+
+	if c.ll.Len() >= c.capacity {
+		c.removeExpired()
+		if c.ll.Len() >= c.capacity {
+			return errCacheFull
+		}
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	return nil
+}
+
+// Remove は値を削除する
+func (c *lruCache[V]) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Len は保持している件数を返す (期限切れで未削除の値を含む)
+func (c *lruCache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// 期限切れの値をすべて削除する
+func (c *lruCache[V]) removeExpired() {
+	now := c.now()
+	for el := c.ll.Back(); el != nil; {
+		prev := el.Prev()
+		if !now.Before(el.Value.(*lruEntry[V]).expiresAt) {
+			c.removeElement(el)
+		}
+		el = prev
+	}
+}
+
+func (c *lruCache[V]) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry[V]).key)
+}
+
+// トークンそのものをメモリに残さないよう、キャッシュのキーにはハッシュを使う
+func tokenCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// キャッシュした claim を呼び出し元が変更しても影響しないよう浅いコピーを返す
+func copyClaims[M ~map[string]interface{}](claims M) M {
+	copied := make(M, len(claims))
+	for k, v := range claims {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	now := time.Now()
+	c := newLRUCache[string](2)
+	c.now = func() time.Time { return now }
+
+	c.Add("a", "A", now.Add(time.Minute))
+	c.Add("b", "B", now.Add(time.Minute))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("Get(a) = false, 期待値 = true")
+	}
+
+	// b が最も使われていないため、c の追加で追い出される
+	c.Add("c", "C", now.Add(time.Minute))
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Get(b) = true, 期待値 = false (追い出し済み)")
+	}
+	if v, ok := c.Get("a"); !ok || v != "A" {
+		t.Errorf("Get(a) = %q, %v, 期待値 = A, true", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, 期待値 = 2", c.Len())
+	}
+
+	// 有効期限を過ぎた値は返さない
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Get(a) = true, 期待値 = false (期限切れ)")
+	}
+
+	// 追加時点で期限切れの値は保持しない
+	c.Add("d", "D", now.Add(-time.Second))
+	if _, ok := c.Get("d"); ok {
+		t.Errorf("Get(d) = true, 期待値 = false")
+	}
+
+	c.Remove("c")
+	if _, ok := c.Get("c"); ok {
+		t.Errorf("Get(c) = true, 期待値 = false (削除済み)")
+	}
+}
+
+func TestLRUCache_ZeroCapacity(t *testing.T) {
+	c := newLRUCache[string](0)
+	c.Add("a", "A", time.Now().Add(time.Minute))
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Get(a) = true, 期待値 = false (キャッシュ無効)")
+	}
+}
+
+func TestLRUCache_AddIfAbsent(t *testing.T) {
+	now := time.Now()
+	c := newLRUCache[struct{}](2)
+	c.now = func() time.Time { return now }
+
+	if err := c.AddIfAbsent("a", struct{}{}, now.Add(time.Minute)); err != nil {
+		t.Fatalf("AddIfAbsent(a) エラー = %v, 期待値 = nil", err)
+	}
+	if err := c.AddIfAbsent("a", struct{}{}, now.Add(time.Minute)); !errors.Is(err, errCacheKeyExists) {
+		t.Errorf("AddIfAbsent(a) エラー = %v, 期待値 = errCacheKeyExists", err)
+	}
+	if err := c.AddIfAbsent("b", struct{}{}, now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("AddIfAbsent(b) エラー = %v, 期待値 = nil", err)
+	}
+
+	// 有効期限内の値で埋まっている場合は追い出さずに拒否する
+	if err := c.AddIfAbsent("c", struct{}{}, now.Add(time.Minute)); !errors.Is(err, errCacheFull) {
+		t.Errorf("AddIfAbsent(c) エラー = %v, 期待値 = errCacheFull", err)
+	}
+	if err := c.AddIfAbsent("a", struct{}{}, now.Add(time.Minute)); !errors.Is(err, errCacheKeyExists) {
+		t.Errorf("AddIfAbsent(a) エラー = %v, 期待値 = errCacheKeyExists (追い出されていない)", err)
+	}
+
+	// 期限切れの値を削除して空きを作る
+	now = now.Add(90 * time.Second)
+	if err := c.AddIfAbsent("c", struct{}{}, now.Add(time.Minute)); err != nil {
+		t.Errorf("AddIfAbsent(c) エラー = %v, 期待値 = nil", err)
+	}
+	if err := c.AddIfAbsent("b", struct{}{}, now.Add(time.Minute)); !errors.Is(err, errCacheKeyExists) {
+		t.Errorf("AddIfAbsent(b) エラー = %v, 期待値 = errCacheKeyExists", err)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, 期待値 = 2", c.Len())
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RFC 8705 の証明書に紐付いたトークンで、証明書の拇印を格納する cnf のメンバー名
+const certThumbprintConfirmation = "x5t#S256"
+
+// CertificateThumbprint は RFC 8705 の x5t#S256 (証明書の DER の SHA-256 を base64url でエンコードした値) を返す
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// confirmationClaim は cnf claim のメンバーを文字列で返す。メンバーがない場合は ok = false
+func confirmationClaim(claims jwt.MapClaims, member string) (value string, ok bool, err error) {
+	raw, exists := claims["cnf"]
+	if !exists {
+		return "", false, nil
+	}
+	cnf, isMap := raw.(map[string]interface{})
+	if !isMap {
+		return "", false, errors.New("cnf claimの形式が不正です")
+	}
+	v, exists := cnf[member]
+	if !exists {
+		return "", false, nil
+	}
+	s, isString := v.(string)
+	if !isString || s == "" {
+		return "", false, errors.New("cnf claimの " + member + " が文字列ではありません")
+	}
+	return s, true, nil
+}
+
+// verifyCertificateBinding は cnf.x5t#S256 を含むトークンについて、mTLS で提示されたクライアント証明書の拇印と一致するかを検証する。
+// cnf.x5t#S256 を含まないトークンは検証しない。
+func verifyCertificateBinding(claims jwt.MapClaims, r *http.Request) error {
+	want, ok, err := confirmationClaim(claims, certThumbprintConfirmation)
+	if err != nil || !ok {
+		return err
+	}
+
+	cert := peerCertificate(r)
+	if cert == nil {
+		return errors.New("クライアント証明書に紐付いたトークンですが、クライアント証明書が提示されていません")
+	}
+	got := CertificateThumbprint(cert)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return errors.New("トークンに紐付いたクライアント証明書と提示された証明書が一致しません")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func certThumbprintFromPEM(t *testing.T, certPEM string) string {
+	t.Helper()
+	block, _ := pem.Decode([]byte(certPEM))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("証明書の解析に失敗しました: %v", err)
+	}
+	return CertificateThumbprint(cert)
+}
+
+func TestCheckAuth_CertificateBoundToken(t *testing.T) {
+	newCert := func(cn string) string {
+		return generateClientCertPEM(t, &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		})
+	}
+	clientPEM := newCert("client")
+	otherPEM := newCert("other")
+	thumbprint := certThumbprintFromPEM(t, clientPEM)
+
+	validator := &fakeValidator{
+		tokens: map[string]jwt.MapClaims{
+			"bound":     {"sub": "user-1", "cnf": map[string]interface{}{"x5t#S256": thumbprint}},
+			"unbound":   {"sub": "user-1"},
+			"dpop":      {"sub": "user-1", "cnf": map[string]interface{}{"jkt": "0ZcOCORZNYy-DWpqq30jZyJGHTN0d2HglBV3uiguA4I"}},
+			"malformed": {"sub": "user-1", "cnf": "x5t#S256"},
+		},
+		err: errors.New("トークンが無効です"),
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		certPEM   string
+		wantError string
+	}{
+		{name: "正常系: 証明書の拇印が一致する", token: "bound", certPEM: clientPEM},
+		{name: "正常系: 紐付けのないトークンは証明書なしで受け付ける", token: "unbound"},
+		{name: "エラー: 別の証明書", token: "bound", certPEM: otherPEM, wantError: "一致しません"},
+		{name: "エラー: 証明書が提示されていない", token: "bound", wantError: "提示されていません"},
+		{name: "エラー: DPoP に紐付いたトークンを Bearer で送信", token: "dpop", wantError: "DPoP スキーム"},
+		{name: "エラー: cnf の形式が不正", token: "malformed", certPEM: clientPEM, wantError: "cnf claim"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := makeMTLSRequest(t, tt.certPEM)
+			request.Header.Set("Authorization", "Bearer "+tt.token)
+
+			p, err := CheckAuth(validator, request)
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("CheckAuth() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil || p.Subject != "user-1" {
+				t.Errorf("CheckAuth() = %+v, %v, 期待値 sub = user-1", p, err)
+			}
+		})
+	}
+}
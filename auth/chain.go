@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ChainValidator は複数の検証方式を順に試し、最初に成功した結果を返す
+// (例: JWT として検証できないトークンをイントロスペクションに回す)
+type ChainValidator struct {
+	validators []TokenValidator
+}
+
+// NewChainValidator は validators を指定した順に試す ChainValidator を生成する。nil は無視する
+func NewChainValidator(validators ...TokenValidator) *ChainValidator {
+	c := &ChainValidator{}
+	for _, v := range validators {
+		if v != nil {
+			c.validators = append(c.validators, v)
+		}
+	}
+	return c
+}
+
+// ValidateToken はいずれかの検証方式で有効と判定された claim を返す。すべて失敗した場合は各方式のエラーをまとめて返す
+func (c *ChainValidator) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	if len(c.validators) == 0 {
+		return nil, errors.New("トークンの検証方式が設定されていません")
+	}
+
+	errs := make([]error, 0, len(c.validators))
+	for _, v := range c.validators {
+		claims, err := v.ValidateToken(tokenString)
+		if err == nil {
+			return claims, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// AllowsRoute はルート制限を持つすべての検証方式が許可する場合に true を返す
+func (c *ChainValidator) AllowsRoute(issuer, path string) bool {
+	for _, v := range c.validators {
+		if rr, ok := v.(RouteRestrictor); ok && !rr.AllowsRoute(issuer, path) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// テスト用の TokenValidator。tokens に登録されたトークンだけを有効とする
+type fakeValidator struct {
+	tokens map[string]jwt.MapClaims
+	err    error
+	calls  int
+}
+
+func (f *fakeValidator) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	f.calls++
+	if claims, ok := f.tokens[tokenString]; ok {
+		return claims, nil
+	}
+	return nil, f.err
+}
+
+// テスト用の RouteRestrictor を実装した TokenValidator
+type restrictedValidator struct {
+	fakeValidator
+	allowed map[string]bool
+}
+
+func (r *restrictedValidator) AllowsRoute(issuer, path string) bool {
+	return r.allowed[issuer+" "+path]
+}
+
+func TestChainValidator_ValidateToken(t *testing.T) {
+	jwtValidator := &fakeValidator{
+		tokens: map[string]jwt.MapClaims{"jwt-token": {"sub": "jwt-user"}},
+		err:    errors.New("jwt error"),
+	}
+	introspection := &fakeValidator{
+		tokens: map[string]jwt.MapClaims{"opaque-token": {"sub": "partner"}},
+		err:    errors.New("introspection error"),
+	}
+	chain := NewChainValidator(jwtValidator, nil, introspection)
+
+	claims, err := chain.ValidateToken("jwt-token")
+	if err != nil || claims["sub"] != "jwt-user" {
+		t.Errorf("ValidateToken(jwt-token) = %v, %v", claims, err)
+	}
+	if introspection.calls != 0 {
+		t.Errorf("最初の方式で成功した場合に後続の方式が呼ばれました: %d", introspection.calls)
+	}
+
+	claims, err = chain.ValidateToken("opaque-token")
+	if err != nil || claims["sub"] != "partner" {
+		t.Errorf("ValidateToken(opaque-token) = %v, %v", claims, err)
+	}
+
+	_, err = chain.ValidateToken("unknown")
+	if err == nil || !contains(err.Error(), "jwt error") || !contains(err.Error(), "introspection error") {
+		t.Errorf("ValidateToken(unknown) エラー = %v, 期待値 = 全方式のエラー", err)
+	}
+
+	if _, err := NewChainValidator().ValidateToken("jwt-token"); err == nil {
+		t.Errorf("検証方式のない ChainValidator がエラーを返しませんでした")
+	}
+}
+
+func TestChainValidator_AllowsRoute(t *testing.T) {
+	restricted := &restrictedValidator{allowed: map[string]bool{"iss-a /api/a": true}}
+	chain := NewChainValidator(restricted, &fakeValidator{})
+
+	if !chain.AllowsRoute("iss-a", "/api/a") {
+		t.Errorf("AllowsRoute(iss-a, /api/a) = false, 期待値 = true")
+	}
+	if chain.AllowsRoute("iss-a", "/api/b") {
+		t.Errorf("AllowsRoute(iss-a, /api/b) = true, 期待値 = false")
+	}
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ClaimFormatJoin = "join"
+	ClaimFormatJSON = "json"
+
+	ClaimsEncodingBase64 = "base64"
+	ClaimsEncodingSigned = "signed"
+)
+
+// ClaimHeaderMapping は claim をバックエンドへ転送するヘッダーの対応付け
+type ClaimHeaderMapping struct {
+	// Claim は claim 名。Auth0 の名前空間付きカスタム claim (例: "https://example.com/tenant_id") も指定できる
+	Claim string `json:"claim"`
+	// Header は転送先のヘッダー名
+	Header string `json:"header"`
+	// Format は配列の変換方法。"join" (既定) は Separator で連結し、"json" は JSON 文字列にする
+	Format string `json:"format"`
+	// Separator は "join" で使用する区切り文字 (既定: ",")
+	Separator string `json:"separator"`
+}
+
+// ClaimForwarderConfig は ClaimForwarder の設定
+type ClaimForwarderConfig struct {
+	Mappings []ClaimHeaderMapping
+	// ClaimsHeader を指定した場合、検証済みの claim 全体をこのヘッダーで転送する
+	ClaimsHeader string
+	// ClaimsEncoding は "base64" (Base64 エンコードした JSON) または "signed" (HS256 で署名した JWT)
+	ClaimsEncoding string
+	// SigningSecret は "signed" の署名に使う共有鍵
+	SigningSecret []byte
+}
+
+// ClaimForwarder は検証済みの claim をバックエンド向けのリクエストヘッダーに変換する
+type ClaimForwarder struct {
+	cfg     ClaimForwarderConfig
+	managed map[string]bool
+}
+
+// NewClaimForwarder は設定値を検証して ClaimForwarder を生成する
+func NewClaimForwarder(cfg ClaimForwarderConfig) (*ClaimForwarder, error) {
+	managed := make(map[string]bool)
+	for i, m := range cfg.Mappings {
+		if m.Claim == "" || m.Header == "" {
+			return nil, fmt.Errorf("claim と header は必須です: %+v", m)
+		}
+		switch m.Format {
+		case "":
+			cfg.Mappings[i].Format = ClaimFormatJoin
+		case ClaimFormatJoin, ClaimFormatJSON:
+		default:
+			return nil, fmt.Errorf("claim ヘッダーの format が不正です: %q", m.Format)
+		}
+		if cfg.Mappings[i].Separator == "" {
+			cfg.Mappings[i].Separator = ","
+		}
+		managed[http.CanonicalHeaderKey(m.Header)] = true
+	}
+
+	if cfg.ClaimsHeader != "" {
+		switch cfg.ClaimsEncoding {
+		case "":
+			cfg.ClaimsEncoding = ClaimsEncodingBase64
+		case ClaimsEncodingBase64:
+		case ClaimsEncodingSigned:
+			if len(cfg.SigningSecret) == 0 {
+				return nil, fmt.Errorf("claim の署名に使う共有鍵が設定されていません")
+			}
+		default:
+			return nil, fmt.Errorf("claim ヘッダーのエンコード方式が不正です: %q", cfg.ClaimsEncoding)
+		}
+		managed[http.CanonicalHeaderKey(cfg.ClaimsHeader)] = true
+	}
+
+	return &ClaimForwarder{cfg: cfg, managed: managed}, nil
+}
+
+// 環境変数から ClaimForwarder を初期化する
+//
+//   - CLAIM_HEADER_MAPPINGS    ClaimHeaderMapping の JSON 配列
+//     例: '[{"claim":"email","header":"X-Auth-Email"},{"claim":"permissions","header":"X-Auth-Roles"}]'
+//   - FORWARD_CLAIMS_HEADER    claim 全体を転送するヘッダー名 例: "X-Auth-Claims"
+//   - FORWARD_CLAIMS_ENCODING  "base64" (既定) または "signed"
+//   - FORWARD_CLAIMS_SECRET    "signed" の場合の共有鍵
+func NewClaimForwarderFromEnv() (*ClaimForwarder, error) {
+	cfg := ClaimForwarderConfig{
+		ClaimsHeader:   strings.TrimSpace(os.Getenv("FORWARD_CLAIMS_HEADER")),
+		ClaimsEncoding: strings.TrimSpace(os.Getenv("FORWARD_CLAIMS_ENCODING")),
+		SigningSecret:  []byte(os.Getenv("FORWARD_CLAIMS_SECRET")),
+	}
+	if raw := os.Getenv("CLAIM_HEADER_MAPPINGS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.Mappings); err != nil {
+			return nil, fmt.Errorf("CLAIM_HEADER_MAPPINGS の形式が不正です: %w", err)
+		}
+	}
+	return NewClaimForwarder(cfg)
+}
+
+// Apply はクライアントが送ってきた転送対象と同名のヘッダーを取り除き、claim から生成したヘッダーを加えた新しいヘッダーを返す。
+// claim が存在しない場合、そのヘッダーは付与しない。
+func (f *ClaimForwarder) Apply(headers http.Header, claims jwt.MapClaims) (http.Header, error) {
+	if f == nil {
+		return headers, nil
+	}
+
+	result := headers.Clone()
+	if result == nil {
+		result = make(http.Header, len(f.managed))
+	}
+	// なりすまし防止のため、クライアント由来の値は破棄する
+	for k := range f.managed {
+		result.Del(k)
+	}
+
+	for _, m := range f.cfg.Mappings {
+		value, ok, err := formatClaim(claims[m.Claim], m)
+		if err != nil {
+			return nil, fmt.Errorf("claim %q の変換に失敗しました: %w", m.Claim, err)
+		}
+		if ok {
+			result.Set(m.Header, value)
+		}
+	}
+
+	if f.cfg.ClaimsHeader != "" {
+		value, err := f.encodeClaims(claims)
+		if err != nil {
+			return nil, err
+		}
+		result.Set(f.cfg.ClaimsHeader, value)
+	}
+
+	return result, nil
+}
+
+func (f *ClaimForwarder) encodeClaims(claims jwt.MapClaims) (string, error) {
+	if f.cfg.ClaimsEncoding == ClaimsEncodingSigned {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(f.cfg.SigningSecret)
+		if err != nil {
+			return "", fmt.Errorf("claim の署名に失敗しました: %w", err)
+		}
+		return signed, nil
+	}
+
+	raw, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("claim の JSON 変換に失敗しました: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// claim の値をヘッダー値に変換する。値が存在しない場合は ok = false を返す
+func formatClaim(value interface{}, m ClaimHeaderMapping) (string, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", false, nil
+	case []interface{}:
+		if m.Format == ClaimFormatJSON {
+			raw, err := json.Marshal(v)
+			return string(raw), true, err
+		}
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := formatScalar(item)
+			if err != nil {
+				return "", false, err
+			}
+			parts = append(parts, s)
+		}
+		return sanitizeHeaderValue(strings.Join(parts, m.Separator)), true, nil
+	default:
+		s, err := formatScalar(v)
+		return sanitizeHeaderValue(s), true, err
+	}
+}
+
+func formatScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		raw, err := json.Marshal(v)
+		return string(raw), err
+	}
+}
+
+// ヘッダーインジェクションを防ぐため制御文字を取り除く
+func sanitizeHeaderValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
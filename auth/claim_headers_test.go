@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":                          "auth0|user-1",
+		"email":                        "user@example.com",
+		"azp":                          "client-abc",
+		"https://example.com/tenant":   "tenant-9",
+		"permissions":                  []interface{}{"read:balance", "write:balance"},
+		"https://example.com/org_size": float64(120),
+		"https://example.com/profile":  map[string]interface{}{"plan": "gold"},
+		"name":                         "Evil\r\nX-Injected: 1",
+	}
+}
+
+func TestClaimForwarder_Apply(t *testing.T) {
+	f, err := NewClaimForwarder(ClaimForwarderConfig{
+		Mappings: []ClaimHeaderMapping{
+			{Claim: "email", Header: "X-Auth-Email"},
+			{Claim: "https://example.com/tenant", Header: "X-Auth-Tenant-ID"},
+			{Claim: "permissions", Header: "X-Auth-Roles"},
+			{Claim: "permissions", Header: "X-Auth-Roles-JSON", Format: ClaimFormatJSON},
+			{Claim: "permissions", Header: "X-Auth-Roles-Space", Separator: " "},
+			{Claim: "azp", Header: "X-Auth-Client-ID"},
+			{Claim: "https://example.com/org_size", Header: "X-Auth-Org-Size"},
+			{Claim: "https://example.com/profile", Header: "X-Auth-Profile"},
+			{Claim: "name", Header: "X-Auth-Name"},
+			{Claim: "org_id", Header: "X-Auth-Org-ID"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewClaimForwarder() エラー = %v", err)
+	}
+
+	incoming := http.Header{
+		"Content-Type":  {"application/json"},
+		"X-Auth-Email":  {"spoofed@example.com"},
+		"X-Auth-Org-Id": {"spoofed-org"},
+	}
+	headers, err := f.Apply(incoming, testClaims())
+	if err != nil {
+		t.Fatalf("Apply() エラー = %v", err)
+	}
+
+	want := map[string]string{
+		"Content-Type":       "application/json",
+		"X-Auth-Email":       "user@example.com",
+		"X-Auth-Tenant-ID":   "tenant-9",
+		"X-Auth-Roles":       "read:balance,write:balance",
+		"X-Auth-Roles-JSON":  `["read:balance","write:balance"]`,
+		"X-Auth-Roles-Space": "read:balance write:balance",
+		"X-Auth-Client-ID":   "client-abc",
+		"X-Auth-Org-Size":    "120",
+		"X-Auth-Profile":     `{"plan":"gold"}`,
+		"X-Auth-Name":        "EvilX-Injected: 1",
+	}
+	if len(headers) != len(want) {
+		t.Errorf("Apply() ヘッダー数 = %d, 期待値 = %d: %v", len(headers), len(want), headers)
+	}
+	for k, v := range want {
+		if got := headers.Values(k); len(got) != 1 || got[0] != v {
+			t.Errorf("Apply() %s = %q, 期待値 = %q", k, got, v)
+		}
+	}
+	if incoming.Get("X-Auth-Email") != "spoofed@example.com" {
+		t.Errorf("Apply() が元のヘッダーを変更しました")
+	}
+}
+
+func TestClaimForwarder_ClaimsHeader(t *testing.T) {
+	claims := testClaims()
+
+	t.Run("正常系: base64", func(t *testing.T) {
+		f, err := NewClaimForwarder(ClaimForwarderConfig{ClaimsHeader: "X-Auth-Claims"})
+		if err != nil {
+			t.Fatalf("NewClaimForwarder() エラー = %v", err)
+		}
+		headers, err := f.Apply(http.Header{"X-Auth-Claims": {"spoofed"}}, claims)
+		if err != nil {
+			t.Fatalf("Apply() エラー = %v", err)
+		}
+		if got := headers.Values("X-Auth-Claims"); len(got) != 1 || got[0] == "spoofed" {
+			t.Errorf("Apply() クライアント由来の X-Auth-Claims が残っています: %q", got)
+		}
+		raw, err := base64.StdEncoding.DecodeString(headers.Get("X-Auth-Claims"))
+		if err != nil {
+			t.Fatalf("X-Auth-Claims の base64 デコードに失敗しました: %v", err)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatalf("X-Auth-Claims の JSON デコードに失敗しました: %v", err)
+		}
+		if decoded["sub"] != "auth0|user-1" {
+			t.Errorf("X-Auth-Claims sub = %v, 期待値 = auth0|user-1", decoded["sub"])
+		}
+	})
+
+	t.Run("正常系: signed", func(t *testing.T) {
+		secret := []byte("forward-secret")
+		f, err := NewClaimForwarder(ClaimForwarderConfig{
+			ClaimsHeader:   "X-Auth-Claims",
+			ClaimsEncoding: ClaimsEncodingSigned,
+			SigningSecret:  secret,
+		})
+		if err != nil {
+			t.Fatalf("NewClaimForwarder() エラー = %v", err)
+		}
+		headers, err := f.Apply(nil, claims)
+		if err != nil {
+			t.Fatalf("Apply() エラー = %v", err)
+		}
+		parsed, err := jwt.Parse(headers.Get("X-Auth-Claims"), func(*jwt.Token) (interface{}, error) {
+			return secret, nil
+		}, jwt.WithValidMethods([]string{"HS256"}))
+		if err != nil {
+			t.Fatalf("X-Auth-Claims の署名検証に失敗しました: %v", err)
+		}
+		if sub, _ := parsed.Claims.GetSubject(); sub != "auth0|user-1" {
+			t.Errorf("X-Auth-Claims sub = %v, 期待値 = auth0|user-1", sub)
+		}
+	})
+}
+
+func TestNewClaimForwarder_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ClaimForwarderConfig
+	}{
+		{"エラー: header がない", ClaimForwarderConfig{Mappings: []ClaimHeaderMapping{{Claim: "email"}}}},
+		{"エラー: 不明な format", ClaimForwarderConfig{Mappings: []ClaimHeaderMapping{{Claim: "email", Header: "X-Auth-Email", Format: "csv"}}}},
+		{"エラー: signed で共有鍵がない", ClaimForwarderConfig{ClaimsHeader: "X-Auth-Claims", ClaimsEncoding: ClaimsEncodingSigned}},
+		{"エラー: 不明なエンコード方式", ClaimForwarderConfig{ClaimsHeader: "X-Auth-Claims", ClaimsEncoding: "hex"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClaimForwarder(tt.cfg); err == nil {
+				t.Errorf("NewClaimForwarder() エラーが期待されましたが、nil が返されました")
+			}
+		})
+	}
+}
+
+func TestNewClaimForwarderFromEnv(t *testing.T) {
+	t.Setenv("CLAIM_HEADER_MAPPINGS", `[{"claim":"email","header":"X-Auth-Email"}]`)
+	t.Setenv("FORWARD_CLAIMS_HEADER", "")
+
+	f, err := NewClaimForwarderFromEnv()
+	if err != nil {
+		t.Fatalf("NewClaimForwarderFromEnv() エラー = %v", err)
+	}
+	headers, _ := f.Apply(nil, testClaims())
+	if headers.Get("X-Auth-Email") != "user@example.com" {
+		t.Errorf("X-Auth-Email = %q, 期待値 = user@example.com", headers.Get("X-Auth-Email"))
+	}
+
+	t.Setenv("CLAIM_HEADER_MAPPINGS", `{`)
+	if _, err := NewClaimForwarderFromEnv(); err == nil {
+		t.Errorf("NewClaimForwarderFromEnv() エラーが期待されましたが、nil が返されました")
+	}
+}
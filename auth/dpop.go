@@ -0,0 +1,369 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aki80204/go-gateway/metrics"
+)
+
+const (
+	// RFC 9449 の DPoP 証明の typ ヘッダー
+	dpopProofType = "dpop+jwt"
+	// DPoP に紐付いたトークンで、証明に使う鍵の拇印を格納する cnf のメンバー名
+	dpopThumbprintConfirmation = "jkt"
+
+	defaultDPoPMaxAge       = 5 * time.Minute
+	defaultDPoPLeeway       = 5 * time.Second
+	defaultDPoPJTICacheSize = 10000
+	minDPoPRSAKeyBits       = 2048
+)
+
+// DPoP 証明で受け付ける署名アルゴリズム。共通鍵の HS256 などは RFC 9449 で禁止されている
+var defaultDPoPAlgorithms = []string{"ES256", "ES384", "ES512", "RS256", "PS256", "EdDSA"}
+
+// DPoPConfig は DPoP 証明の検証設定
+type DPoPConfig struct {
+	// Algorithms は受け付ける署名アルゴリズム (既定: ES256, ES384, ES512, RS256, PS256, EdDSA)
+	Algorithms []string
+	// MaxAge は証明の iat からの最大経過時間 (既定: 5 分)
+	MaxAge time.Duration
+	// Leeway は時刻のずれの許容値 (既定: 5 秒)
+	Leeway time.Duration
+	// JTICacheSize はリプレイ検知のために保持する jti の件数 (既定: 10000)。
+	// MaxAge の間にこれを超える証明を受けた場合、期限切れの jti ができるまで新しい証明を拒否する
+	JTICacheSize int
+}
+
+// DPoPVerifier は DPoP ヘッダーの証明 JWT を検証する。
+// jti はコンテナごとのメモリに保持するため、複数のコンテナにまたがるリプレイは MaxAge でのみ防ぐ。
+type DPoPVerifier struct {
+	parser *jwt.Parser
+	maxAge time.Duration
+	leeway time.Duration
+	jtis   *lruCache[struct{}]
+	now    func() time.Time
+}
+
+// NewDPoPVerifier は設定値を補完して DPoPVerifier を生成する
+func NewDPoPVerifier(cfg DPoPConfig) (*DPoPVerifier, error) {
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = defaultDPoPAlgorithms
+	}
+	for _, alg := range cfg.Algorithms {
+		if strings.HasPrefix(alg, "HS") || alg == "none" {
+			return nil, fmt.Errorf("DPoP 証明に共通鍵のアルゴリズムは使えません: %q", alg)
+		}
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = defaultDPoPMaxAge
+	}
+	if cfg.Leeway == 0 {
+		cfg.Leeway = defaultDPoPLeeway
+	}
+	if cfg.JTICacheSize == 0 {
+		cfg.JTICacheSize = defaultDPoPJTICacheSize
+	}
+
+	v := &DPoPVerifier{
+		// iat などの時刻は verifyClaims で検証する
+		parser: jwt.NewParser(jwt.WithValidMethods(cfg.Algorithms), jwt.WithoutClaimsValidation()),
+		maxAge: cfg.MaxAge,
+		leeway: cfg.Leeway,
+		now:    time.Now,
+	}
+	v.jtis = newLRUCache[struct{}](cfg.JTICacheSize)
+	v.jtis.now = func() time.Time { return v.now() }
+	return v, nil
+}
+
+// NewDPoPVerifierFromEnv は環境変数から DPoPVerifier を生成する。
+//   - DPOP_ALGORITHMS      受け付ける署名アルゴリズム (カンマ区切り)
+//   - DPOP_MAX_AGE         証明の iat からの最大経過時間 (例: "1m")
+//   - DPOP_JTI_CACHE_SIZE  リプレイ検知のために保持する jti の件数
+func NewDPoPVerifierFromEnv() (*DPoPVerifier, error) {
+	cfg := DPoPConfig{Algorithms: splitList(os.Getenv("DPOP_ALGORITHMS"))}
+
+	maxAge, err := durationFromEnv("DPOP_MAX_AGE")
+	if err != nil {
+		return nil, err
+	}
+	cfg.MaxAge = maxAge
+
+	if raw := os.Getenv("DPOP_JTI_CACHE_SIZE"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("DPOP_JTI_CACHE_SIZE の形式が不正です: %q", raw)
+		}
+		cfg.JTICacheSize = size
+	}
+	return NewDPoPVerifier(cfg)
+}
+
+// Verify は DPoP 証明を検証し、証明に使われた公開鍵の JWK 拇印 (RFC 7638) を返す。
+// method と requestURL は実際のリクエスト、accessToken は同時に提示されたアクセストークン。
+func (v *DPoPVerifier) Verify(proof, method, requestURL, accessToken string) (string, error) {
+	var thumbprint string
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(proof, claims, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); !strings.EqualFold(typ, dpopProofType) {
+			return nil, errors.New("DPoP 証明の typ ヘッダーが不正です")
+		}
+		jwk, ok := token.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("DPoP 証明に jwk ヘッダーが存在しません")
+		}
+		key, tp, err := dpopPublicKey(jwk)
+		if err != nil {
+			return nil, err
+		}
+		thumbprint = tp
+		return key, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("DPoP 証明の検証に失敗しました: %w", err)
+	}
+
+	if err := v.verifyClaims(claims, method, requestURL, accessToken); err != nil {
+		return "", err
+	}
+	return thumbprint, nil
+}
+
+func (v *DPoPVerifier) verifyClaims(claims jwt.MapClaims, method, requestURL, accessToken string) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return errors.New("DPoP 証明に jti が存在しません")
+	}
+	if htm, _ := claims["htm"].(string); htm != method {
+		return errors.New("DPoP 証明の htm がリクエストのメソッドと一致しません")
+	}
+	htu, _ := claims["htu"].(string)
+	if normalizeDPoPURL(htu) == "" || normalizeDPoPURL(htu) != normalizeDPoPURL(requestURL) {
+		return errors.New("DPoP 証明の htu がリクエストの URL と一致しません")
+	}
+
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return errors.New("DPoP 証明に iat が存在しません")
+	}
+	now := v.now()
+	if iat.After(now.Add(v.leeway)) || iat.Before(now.Add(-v.maxAge-v.leeway)) {
+		return errors.New("DPoP 証明の iat が有効期間外です")
+	}
+
+	ath, _ := claims["ath"].(string)
+	sum := sha256.Sum256([]byte(accessToken))
+	want := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(ath), []byte(want)) != 1 {
+		return errors.New("DPoP 証明の ath がアクセストークンと一致しません")
+	}
+
+	// 署名と claim の検証がすべて済んだ証明のみ記録し、不正な証明で jti を消費させない
+	switch err := v.jtis.AddIfAbsent(jti, struct{}{}, iat.Add(v.maxAge+v.leeway)); {
+	case errors.Is(err, errCacheFull):
+		// 期限内の jti を追い出すと再送を受け付けてしまうため、空きができるまで新しい証明を拒否する
+		metrics.Emit("ReplayCacheFull", 1, metrics.UnitCount, map[string]string{"Method": MethodDPoP})
+		return errors.New("リプレイ検知の jti が上限に達しているため DPoP 証明を受け付けられません")
+	case err != nil:
+		return errors.New("同じ DPoP 証明が再送されました")
+	}
+	return nil
+}
+
+// htu の比較用にクエリとフラグメントを除き、scheme と host を小文字にして既定のポートを取り除く (RFC 9449 4.3)
+func normalizeDPoPURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(scheme == "https" && port == "443") && !(scheme == "http" && port == "80") {
+		host += ":" + port
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path
+}
+
+// dpopPublicKey は jwk ヘッダーの公開鍵と、その JWK 拇印 (RFC 7638) を返す。秘密鍵を含む JWK は受け付けない
+func dpopPublicKey(jwk map[string]interface{}) (crypto.PublicKey, string, error) {
+	if _, ok := jwk["d"]; ok {
+		return nil, "", errors.New("DPoP 証明の jwk に秘密鍵が含まれています")
+	}
+	member := func(name string) string {
+		s, _ := jwk[name].(string)
+		return s
+	}
+
+	// 拇印は必須メンバーのみを辞書順に並べた JSON から計算する
+	var key crypto.PublicKey
+	var required map[string]string
+	switch kty := member("kty"); kty {
+	case "EC":
+		k, err := ecPublicKey(member("crv"), member("x"), member("y"))
+		if err != nil {
+			return nil, "", err
+		}
+		key = k
+		required = map[string]string{"crv": member("crv"), "kty": kty, "x": member("x"), "y": member("y")}
+	case "RSA":
+		k, err := rsaPublicKey(member("n"), member("e"))
+		if err != nil {
+			return nil, "", err
+		}
+		key = k
+		required = map[string]string{"e": member("e"), "kty": kty, "n": member("n")}
+	case "OKP":
+		if member("crv") != "Ed25519" {
+			return nil, "", fmt.Errorf("DPoP 証明の jwk の crv に対応していません: %q", member("crv"))
+		}
+		x, err := base64.RawURLEncoding.DecodeString(member("x"))
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, "", errors.New("DPoP 証明の jwk の x が不正です")
+		}
+		key = ed25519.PublicKey(x)
+		required = map[string]string{"crv": member("crv"), "kty": kty, "x": member("x")}
+	default:
+		return nil, "", fmt.Errorf("DPoP 証明の jwk の kty に対応していません: %q", kty)
+	}
+
+	// encoding/json はマップのキーを辞書順に出力する
+	data, err := json.Marshal(required)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return key, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func ecPublicKey(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var ecdhCurve ecdh.Curve
+	switch crv {
+	case "P-256":
+		curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("DPoP 証明の jwk の crv に対応していません: %q", crv)
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	xb, errX := base64.RawURLEncoding.DecodeString(x)
+	yb, errY := base64.RawURLEncoding.DecodeString(y)
+	if errX != nil || errY != nil || len(xb) != size || len(yb) != size {
+		return nil, errors.New("DPoP 証明の jwk の座標が不正です")
+	}
+	// 曲線上の点であることを crypto/ecdh で確認する
+	point := append(append([]byte{4}, xb...), yb...)
+	if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+		return nil, errors.New("DPoP 証明の jwk の座標が曲線上にありません")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(xb), Y: new(big.Int).SetBytes(yb)}, nil
+}
+
+func rsaPublicKey(n, e string) (*rsa.PublicKey, error) {
+	nb, errN := base64.RawURLEncoding.DecodeString(n)
+	eb, errE := base64.RawURLEncoding.DecodeString(e)
+	if errN != nil || errE != nil || len(eb) == 0 || len(eb) > 4 {
+		return nil, errors.New("DPoP 証明の jwk の n または e が不正です")
+	}
+	modulus := new(big.Int).SetBytes(nb)
+	if modulus.BitLen() < minDPoPRSAKeyBits {
+		return nil, fmt.Errorf("DPoP 証明の RSA 鍵は %d ビット以上が必要です", minDPoPRSAKeyBits)
+	}
+	exponent := int(new(big.Int).SetBytes(eb).Int64())
+	if exponent < 3 || exponent%2 == 0 {
+		return nil, errors.New("DPoP 証明の jwk の e が不正です")
+	}
+	return &rsa.PublicKey{N: modulus, E: exponent}, nil
+}
+
+// DPoPAuthenticator は Authorization: DPoP のトークンと DPoP ヘッダーの証明 (RFC 9449) で認証する。
+// トークンは cnf.jkt で証明の鍵に紐付いている必要がある。
+type DPoPAuthenticator struct {
+	Validator TokenValidator
+	Verifier  *DPoPVerifier
+}
+
+func (a *DPoPAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	authorization := r.Header.Get("Authorization")
+	if !hasAuthorizationScheme(authorization, schemeDPoP) {
+		return nil, ErrNoCredentials
+	}
+	tokenString, err := ExtractDPoPToken(authorization)
+	if err != nil {
+		return nil, err
+	}
+	// API Gateway v2 は同名のヘッダーをカンマで連結するため、連結された値も複数の証明として拒否する
+	proofs := r.Header.Values("DPoP")
+	if len(proofs) == 0 || strings.TrimSpace(proofs[0]) == "" {
+		return nil, errors.New("DPoP ヘッダーが存在しません")
+	}
+	proof := strings.TrimSpace(proofs[0])
+	if len(proofs) > 1 || strings.Contains(proof, ",") {
+		return nil, errors.New("DPoP ヘッダーが複数指定されています")
+	}
+
+	claims, err := a.Validator.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	jkt, bound, err := confirmationClaim(claims, dpopThumbprintConfirmation)
+	if err != nil {
+		return nil, err
+	}
+	if !bound {
+		return nil, errors.New("DPoP に紐付いていないトークンです")
+	}
+
+	thumbprint, err := a.Verifier.Verify(proof, r.Method, requestURL(r), tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(jkt)) != 1 {
+		return nil, errors.New("DPoP 証明の鍵がトークンに紐付いた鍵と一致しません")
+	}
+	return newTokenPrincipal(a.Validator, claims, r)
+}
+
+// クライアントが送信した URL (クエリを除く) を組み立てる。
+// スキームはロードバランサーが付与する X-Forwarded-Proto、Lambda のアダプターが設定した URL、TLS の有無の順に決める。
+func requestURL(r *http.Request) string {
+	scheme := r.Header.Get("X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = r.URL.Scheme
+	}
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	return scheme + "://" + r.Host + r.URL.EscapedPath()
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DPoP 証明の署名鍵と、jwk ヘッダーに載せる公開鍵
+func generateDPoPKey(t *testing.T) (*ecdsa.PrivateKey, map[string]interface{}) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("鍵の生成に失敗しました: %v", err)
+	}
+	return key, map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.PublicKey.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.PublicKey.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func generateDPoPProof(t *testing.T, key *ecdsa.PrivateKey, jwk map[string]interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = jwk
+	proof, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("DPoP 証明の署名に失敗しました: %v", err)
+	}
+	return proof
+}
+
+func accessTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestDPoPPublicKey_Thumbprint(t *testing.T) {
+	// RFC 7638 3.1 の例
+	jwk := map[string]interface{}{
+		"kty": "RSA",
+		"n":   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		"e":   "AQAB",
+		"alg": "RS256",
+		"kid": "2011-04-29",
+	}
+	_, thumbprint, err := dpopPublicKey(jwk)
+	if err != nil {
+		t.Fatalf("dpopPublicKey() エラー = %v", err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; thumbprint != want {
+		t.Errorf("dpopPublicKey() 拇印 = %v, 期待値 = %v", thumbprint, want)
+	}
+
+	jwk["d"] = "private"
+	if _, _, err := dpopPublicKey(jwk); err == nil {
+		t.Errorf("dpopPublicKey() 秘密鍵を含む jwk で error = nil")
+	}
+}
+
+func TestDPoPVerifier_Verify(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	key, jwk := generateDPoPKey(t)
+	_, wantThumbprint, err := dpopPublicKey(jwk)
+	if err != nil {
+		t.Fatalf("dpopPublicKey() エラー = %v", err)
+	}
+	const requestURL = "https://api.example.com/api/customers/balance"
+	const accessToken = "access-token"
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"jti": "proof-1",
+			"htm": "GET",
+			"htu": "https://API.example.com:443/api/customers/balance?ignored=1",
+			"iat": now.Unix(),
+			"ath": accessTokenHash(accessToken),
+		}
+	}
+	with := func(name string, value interface{}) jwt.MapClaims {
+		c := validClaims()
+		if value == nil {
+			delete(c, name)
+		} else {
+			c[name] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		claims    jwt.MapClaims
+		wantError string
+	}{
+		{name: "正常系: 有効な証明", claims: validClaims()},
+		{name: "エラー: htm が異なる", claims: with("htm", "POST"), wantError: "htm"},
+		{name: "エラー: htu が異なる", claims: with("htu", "https://api.example.com/api/customers/account"), wantError: "htu"},
+		{name: "エラー: iat が古い", claims: with("iat", now.Add(-10*time.Minute).Unix()), wantError: "iat"},
+		{name: "エラー: iat が未来", claims: with("iat", now.Add(time.Minute).Unix()), wantError: "iat"},
+		{name: "エラー: ath が異なる", claims: with("ath", accessTokenHash("other-token")), wantError: "ath"},
+		{name: "エラー: ath がない", claims: with("ath", nil), wantError: "ath"},
+		{name: "エラー: jti がない", claims: with("jti", nil), wantError: "jti"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewDPoPVerifier(DPoPConfig{})
+			if err != nil {
+				t.Fatalf("NewDPoPVerifier() エラー = %v", err)
+			}
+			v.now = func() time.Time { return now }
+
+			thumbprint, err := v.Verify(generateDPoPProof(t, key, jwk, tt.claims), "GET", requestURL, accessToken)
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("Verify() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil || thumbprint != wantThumbprint {
+				t.Errorf("Verify() = %v, %v, 期待値 = %v", thumbprint, err, wantThumbprint)
+			}
+		})
+	}
+
+	t.Run("エラー: 同じ証明の再送", func(t *testing.T) {
+		v, _ := NewDPoPVerifier(DPoPConfig{})
+		v.now = func() time.Time { return now }
+		proof := generateDPoPProof(t, key, jwk, validClaims())
+		if _, err := v.Verify(proof, "GET", requestURL, accessToken); err != nil {
+			t.Fatalf("Verify() 1 回目のエラー = %v", err)
+		}
+		if _, err := v.Verify(proof, "GET", requestURL, accessToken); err == nil || !contains(err.Error(), "再送") {
+			t.Errorf("Verify() 2 回目のエラー = %v, 期待値に含まれるべき文字列 = 再送", err)
+		}
+	})
+
+	t.Run("エラー: 有効期限内の jti で上限に達した", func(t *testing.T) {
+		v, _ := NewDPoPVerifier(DPoPConfig{JTICacheSize: 2})
+		current := now
+		v.now = func() time.Time { return current }
+		proofWithJTI := func(jti string) string {
+			c := validClaims()
+			c["jti"] = jti
+			c["iat"] = current.Unix()
+			return generateDPoPProof(t, key, jwk, c)
+		}
+
+		for _, jti := range []string{"proof-1", "proof-2"} {
+			if _, err := v.Verify(proofWithJTI(jti), "GET", requestURL, accessToken); err != nil {
+				t.Fatalf("Verify(%s) エラー = %v", jti, err)
+			}
+		}
+		if _, err := v.Verify(proofWithJTI("proof-3"), "GET", requestURL, accessToken); err == nil || !contains(err.Error(), "上限") {
+			t.Errorf("Verify(proof-3) エラー = %v, 期待値に含まれるべき文字列 = 上限", err)
+		}
+		// 上限に達しても有効期限内の jti は追い出さず、再送を拒否し続ける
+		if _, err := v.Verify(proofWithJTI("proof-1"), "GET", requestURL, accessToken); err == nil || !contains(err.Error(), "再送") {
+			t.Errorf("Verify(proof-1) エラー = %v, 期待値に含まれるべき文字列 = 再送", err)
+		}
+
+		// 記録した jti の有効期限が切れると新しい証明を受け付ける
+		current = now.Add(defaultDPoPMaxAge + defaultDPoPLeeway + time.Second)
+		if _, err := v.Verify(proofWithJTI("proof-3"), "GET", requestURL, accessToken); err != nil {
+			t.Errorf("Verify(proof-3) 期限切れ後のエラー = %v", err)
+		}
+	})
+
+	t.Run("エラー: typ ヘッダーが dpop+jwt ではない", func(t *testing.T) {
+		v, _ := NewDPoPVerifier(DPoPConfig{})
+		v.now = func() time.Time { return now }
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, validClaims())
+		token.Header["jwk"] = jwk
+		proof, _ := token.SignedString(key)
+		if _, err := v.Verify(proof, "GET", requestURL, accessToken); err == nil || !contains(err.Error(), "typ") {
+			t.Errorf("Verify() エラー = %v, 期待値に含まれるべき文字列 = typ", err)
+		}
+	})
+}
+
+func TestNewDPoPVerifier_RejectsSymmetricAlgorithms(t *testing.T) {
+	if _, err := NewDPoPVerifier(DPoPConfig{Algorithms: []string{"HS256"}}); err == nil {
+		t.Errorf("NewDPoPVerifier() HS256 で error = nil")
+	}
+}
+
+func TestDPoPAuthenticator_Authenticate(t *testing.T) {
+	key, jwk := generateDPoPKey(t)
+	_, jkt, _ := dpopPublicKey(jwk)
+	_, otherJWK := generateDPoPKey(t)
+	_, otherJKT, _ := dpopPublicKey(otherJWK)
+
+	validator := &fakeValidator{
+		tokens: map[string]jwt.MapClaims{
+			"bound":       {"sub": "mobile-user", "cnf": map[string]interface{}{"jkt": jkt}},
+			"other-bound": {"sub": "mobile-user", "cnf": map[string]interface{}{"jkt": otherJKT}},
+			"unbound":     {"sub": "mobile-user"},
+		},
+		err: errors.New("トークンが無効です"),
+	}
+	verifier, err := NewDPoPVerifier(DPoPConfig{})
+	if err != nil {
+		t.Fatalf("NewDPoPVerifier() エラー = %v", err)
+	}
+	a := &DPoPAuthenticator{Validator: validator, Verifier: verifier}
+
+	request := func(authorization, token string, withProof bool) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "https://api.example.com/api/customers/balance", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		if withProof {
+			r.Header.Set("DPoP", generateDPoPProof(t, key, jwk, jwt.MapClaims{
+				"jti": token + "-" + authorization,
+				"htm": "POST",
+				"htu": "https://api.example.com/api/customers/balance",
+				"iat": time.Now().Unix(),
+				"ath": accessTokenHash(token),
+			}))
+		}
+		return r
+	}
+	multipleProofs := request("DPoP bound", "bound", true)
+	multipleProofs.Header.Add("DPoP", multipleProofs.Header.Get("DPoP"))
+
+	tests := []struct {
+		name      string
+		request   *http.Request
+		wantError string
+	}{
+		{name: "正常系: DPoP トークンと証明", request: request("DPoP bound", "bound", true)},
+		{name: "エラー: 証明の鍵がトークンの jkt と異なる", request: request("DPoP other-bound", "other-bound", true), wantError: "一致しません"},
+		{name: "エラー: DPoP に紐付いていないトークン", request: request("DPoP unbound", "unbound", true), wantError: "紐付いていない"},
+		{name: "エラー: DPoP ヘッダーがない", request: request("DPoP bound", "bound", false), wantError: "DPoP ヘッダー"},
+		{name: "エラー: DPoP ヘッダーが複数", request: multipleProofs, wantError: "複数"},
+		{name: "エラー: Bearer は扱わない", request: request("Bearer bound", "bound", true), wantError: ErrNoCredentials.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.Authenticate(tt.request)
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("Authenticate() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil || p.Subject != "mobile-user" {
+				t.Errorf("Authenticate() = %+v, %v, 期待値 sub = mobile-user", p, err)
+			}
+		})
+	}
+}
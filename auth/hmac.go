@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aki80204/go-gateway/metrics"
+)
+
+const (
+	defaultSignatureHeader = "X-Signature"
+	defaultTimestampHeader = "X-Signature-Timestamp"
+	defaultPayloadTemplate = "{timestamp}.{body}"
+	defaultHMACTolerance   = 5 * time.Minute
+	defaultNonceCacheSize  = 10000
+	defaultHMACIdentity    = "hmac"
+)
+
+// HMACConfig は HMAC 署名によるリクエスト認証の設定
+type HMACConfig struct {
+	// SignatureHeader は署名を格納するヘッダー名 (既定: X-Signature)。値の "sha256=" のような接頭辞は取り除いて比較する
+	SignatureHeader string `json:"signature_header"`
+	// TimestampHeader は署名時刻 (UNIX 秒) を格納するヘッダー名 (既定: X-Signature-Timestamp)
+	TimestampHeader string `json:"timestamp_header"`
+	// NonceHeader を指定した場合はその値を、省略した場合は署名そのものをリプレイ検知に使う。
+	// 署名していない nonce を差し替えた再送を防ぐため、指定する場合は PayloadTemplate に {nonce} を含める
+	NonceHeader string `json:"nonce_header"`
+	// Algorithm は "sha256" (既定) または "sha512"
+	Algorithm string `json:"algorithm"`
+	// Encoding は署名のエンコード方式。"hex" (既定) または "base64"
+	Encoding string `json:"encoding"`
+	// SecretEnv は共有鍵を設定した環境変数名、SecretFile は共有鍵を記載したファイルのパス
+	SecretEnv  string `json:"secret_env"`
+	SecretFile string `json:"secret_file"`
+	// PayloadTemplate は署名対象の組み立て方。{timestamp} {method} {path} {query} {nonce} {body} を置換する (既定: "{timestamp}.{body}")
+	PayloadTemplate string `json:"payload_template"`
+	// Tolerance はタイムスタンプの許容誤差 (既定: "5m")
+	Tolerance string `json:"tolerance"`
+	// Identity は認証に成功したリクエストの sub (既定: "hmac")
+	Identity string `json:"identity"`
+	// NonceCacheSize はリプレイ検知のために保持する nonce の件数 (既定: 10000)。
+	// tolerance の間にこれを超えるリクエストを受けた場合、期限切れの nonce ができるまで新しいリクエストを拒否する
+	NonceCacheSize int `json:"nonce_cache_size"`
+}
+
+// HMACAuthenticator は共有鍵による HMAC 署名でリクエストを認証する。
+// nonce はコンテナごとのメモリに保持するため、複数のコンテナにまたがるリプレイはタイムスタンプの許容誤差でのみ防ぐ。
+type HMACAuthenticator struct {
+	cfg       HMACConfig
+	secret    []byte
+	newHash   func() hash.Hash
+	tolerance time.Duration
+	nonces    *lruCache[struct{}]
+	now       func() time.Time
+}
+
+// NewHMACAuthenticator は設定値を検証し、共有鍵を読み込んで HMACAuthenticator を生成する
+func NewHMACAuthenticator(cfg HMACConfig) (*HMACAuthenticator, error) {
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = defaultSignatureHeader
+	}
+	if cfg.TimestampHeader == "" {
+		cfg.TimestampHeader = defaultTimestampHeader
+	}
+	if cfg.PayloadTemplate == "" {
+		cfg.PayloadTemplate = defaultPayloadTemplate
+	}
+	if cfg.Identity == "" {
+		cfg.Identity = defaultHMACIdentity
+	}
+	if cfg.NonceCacheSize == 0 {
+		cfg.NonceCacheSize = defaultNonceCacheSize
+	}
+	// 0 以下のキャッシュはリプレイ検知を無効にしてしまうため受け付けない
+	if cfg.NonceCacheSize < 0 {
+		return nil, fmt.Errorf("nonce_cache_size が不正です: %d", cfg.NonceCacheSize)
+	}
+
+	if cfg.NonceHeader != "" && !strings.Contains(cfg.PayloadTemplate, "{nonce}") {
+		return nil, errors.New("nonce_header を指定する場合は payload_template に {nonce} を含めてください")
+	}
+
+	a := &HMACAuthenticator{cfg: cfg, tolerance: defaultHMACTolerance, now: time.Now}
+
+	switch strings.ToLower(cfg.Algorithm) {
+	case "", "sha256":
+		a.cfg.Algorithm = "sha256"
+		a.newHash = sha256.New
+	case "sha512":
+		a.cfg.Algorithm = "sha512"
+		a.newHash = sha512.New
+	default:
+		return nil, fmt.Errorf("HMAC のアルゴリズムが不正です: %q", cfg.Algorithm)
+	}
+
+	switch cfg.Encoding {
+	case "":
+		a.cfg.Encoding = "hex"
+	case "hex", "base64":
+	default:
+		return nil, fmt.Errorf("HMAC 署名のエンコード方式が不正です: %q", cfg.Encoding)
+	}
+
+	if cfg.Tolerance != "" {
+		d, err := time.ParseDuration(cfg.Tolerance)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("HMAC の tolerance の形式が不正です: %q", cfg.Tolerance)
+		}
+		a.tolerance = d
+	}
+
+	secret, err := loadHMACSecret(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.secret = secret
+	a.nonces = newLRUCache[struct{}](cfg.NonceCacheSize)
+	a.nonces.now = func() time.Time { return a.now() }
+
+	return a, nil
+}
+
+func loadHMACSecret(cfg HMACConfig) ([]byte, error) {
+	switch {
+	case cfg.SecretFile != "":
+		data, err := os.ReadFile(cfg.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("HMAC の共有鍵ファイルの読み込みに失敗しました: %w", err)
+		}
+		secret := strings.TrimRight(string(data), "\r\n")
+		if secret == "" {
+			return nil, fmt.Errorf("HMAC の共有鍵ファイルが空です: %s", cfg.SecretFile)
+		}
+		return []byte(secret), nil
+	case cfg.SecretEnv != "":
+		secret := os.Getenv(cfg.SecretEnv)
+		if secret == "" {
+			return nil, fmt.Errorf("HMAC の共有鍵が環境変数 %s に設定されていません", cfg.SecretEnv)
+		}
+		return []byte(secret), nil
+	default:
+		return nil, errors.New("HMAC の共有鍵は secret_env または secret_file で指定してください")
+	}
+}
+
+func (a *HMACAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	signature := strings.TrimSpace(r.Header.Get(a.cfg.SignatureHeader))
+	if signature == "" {
+		return nil, ErrNoCredentials
+	}
+	signature = strings.TrimPrefix(signature, a.cfg.Algorithm+"=")
+
+	rawTimestamp := strings.TrimSpace(r.Header.Get(a.cfg.TimestampHeader))
+	ts, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return nil, errors.New("署名のタイムスタンプが存在しないか形式が不正です")
+	}
+	signedAt := time.Unix(ts, 0)
+	now := a.now()
+	if signedAt.Before(now.Add(-a.tolerance)) || signedAt.After(now.Add(a.tolerance)) {
+		return nil, errors.New("署名のタイムスタンプが許容範囲外です")
+	}
+
+	nonce := ""
+	if a.cfg.NonceHeader != "" {
+		if nonce = strings.TrimSpace(r.Header.Get(a.cfg.NonceHeader)); nonce == "" {
+			return nil, errors.New("署名の nonce が存在しません")
+		}
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return nil, errors.New("リクエストボディの読み取りに失敗しました")
+	}
+
+	payload := strings.NewReplacer(
+		"{timestamp}", rawTimestamp,
+		"{method}", r.Method,
+		"{path}", r.URL.EscapedPath(),
+		"{query}", r.URL.RawQuery,
+		"{nonce}", nonce,
+		"{body}", string(body),
+	).Replace(a.cfg.PayloadTemplate)
+
+	mac := hmac.New(a.newHash, a.secret)
+	mac.Write([]byte(payload))
+	expected := mac.Sum(nil)
+
+	got, err := a.decodeSignature(signature)
+	if err != nil || !hmac.Equal(got, expected) {
+		return nil, errors.New("署名が一致しません")
+	}
+
+	// 署名の検証に成功したリクエストだけを nonce として記録する
+	replayKey := nonce
+	if replayKey == "" {
+		replayKey = hex.EncodeToString(expected)
+	}
+	switch err := a.nonces.AddIfAbsent(replayKey, struct{}{}, signedAt.Add(a.tolerance)); {
+	case errors.Is(err, errCacheFull):
+		// 期限内の nonce を追い出すと再送を受け付けてしまうため、空きができるまで新しいリクエストを拒否する
+		metrics.Emit("ReplayCacheFull", 1, metrics.UnitCount, map[string]string{"Method": MethodHMAC})
+		return nil, errors.New("リプレイ検知の nonce が上限に達しているため受け付けられません")
+	case err != nil:
+		return nil, errors.New("同じ署名のリクエストが再送されました")
+	}
+
+	return &Principal{
+		Subject: a.cfg.Identity,
+		Claims:  jwt.MapClaims{"sub": a.cfg.Identity},
+	}, nil
+}
+
+func (a *HMACAuthenticator) decodeSignature(signature string) ([]byte, error) {
+	if a.cfg.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(signature)
+	}
+	return hex.DecodeString(strings.ToLower(signature))
+}
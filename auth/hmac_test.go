@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(newHash func() hash.Hash, secret, payload string) []byte {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func makeWebhookRequest(body string, headers map[string]string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/customers/balance/webhook", strings.NewReader(body))
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	return r
+}
+
+func TestHMACAuthenticator_Authenticate(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "whsec")
+	now := time.Unix(1760000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := `{"event":"balance.updated"}`
+
+	newAuthenticator := func(cfg HMACConfig) *HMACAuthenticator {
+		cfg.SecretEnv = "TEST_WEBHOOK_SECRET"
+		a, err := NewHMACAuthenticator(cfg)
+		if err != nil {
+			t.Fatalf("NewHMACAuthenticator() エラー = %v", err)
+		}
+		a.now = func() time.Time { return now }
+		return a
+	}
+	sha256Hex := hex.EncodeToString(sign(sha256.New, "whsec", ts+"."+body))
+
+	tests := []struct {
+		name      string
+		cfg       HMACConfig
+		request   *http.Request
+		wantError string
+	}{
+		{
+			name:    "正常系: SHA-256 / hex",
+			request: makeWebhookRequest(body, map[string]string{"x-signature": sha256Hex, "x-signature-timestamp": ts}),
+		},
+		{
+			name:    "正常系: アルゴリズム名の接頭辞付き",
+			request: makeWebhookRequest(body, map[string]string{"x-signature": "sha256=" + sha256Hex, "x-signature-timestamp": ts}),
+		},
+		{
+			name: "正常系: SHA-512 / base64 / 独自のヘッダーとテンプレート",
+			cfg: HMACConfig{
+				SignatureHeader: "X-Pay-Signature",
+				TimestampHeader: "X-Pay-Timestamp",
+				Algorithm:       "sha512",
+				Encoding:        "base64",
+				PayloadTemplate: "{method}\n{path}\n{timestamp}\n{body}",
+			},
+			request: makeWebhookRequest(body, map[string]string{
+				"x-pay-signature": base64.StdEncoding.EncodeToString(sign(sha512.New, "whsec", "POST\n/api/customers/balance/webhook\n"+ts+"\n"+body)),
+				"x-pay-timestamp": ts,
+			}),
+		},
+		{
+			name:      "エラー: ボディの改ざん",
+			request:   makeWebhookRequest(`{"event":"balance.deleted"}`, map[string]string{"x-signature": sha256Hex, "x-signature-timestamp": ts}),
+			wantError: "署名が一致しません",
+		},
+		{
+			name:      "エラー: 署名が hex ではない",
+			request:   makeWebhookRequest(body, map[string]string{"x-signature": "zz", "x-signature-timestamp": ts}),
+			wantError: "署名が一致しません",
+		},
+		{
+			name:      "エラー: タイムスタンプなし",
+			request:   makeWebhookRequest(body, map[string]string{"x-signature": sha256Hex}),
+			wantError: "タイムスタンプが存在しないか形式が不正です",
+		},
+		{
+			name: "エラー: 古いタイムスタンプ",
+			request: func() *http.Request {
+				old := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+				sig := hex.EncodeToString(sign(sha256.New, "whsec", old+"."+body))
+				return makeWebhookRequest(body, map[string]string{"x-signature": sig, "x-signature-timestamp": old})
+			}(),
+			wantError: "許容範囲外",
+		},
+		{
+			name: "正常系: tolerance を広げた場合の古いタイムスタンプ",
+			cfg:  HMACConfig{Tolerance: "15m"},
+			request: func() *http.Request {
+				old := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+				sig := hex.EncodeToString(sign(sha256.New, "whsec", old+"."+body))
+				return makeWebhookRequest(body, map[string]string{"x-signature": sig, "x-signature-timestamp": old})
+			}(),
+		},
+		{
+			name:      "エラー: nonce ヘッダーが必要",
+			cfg:       HMACConfig{NonceHeader: "X-Nonce", PayloadTemplate: "{nonce}.{timestamp}.{body}"},
+			request:   makeWebhookRequest(body, map[string]string{"x-signature": sha256Hex, "x-signature-timestamp": ts}),
+			wantError: "nonce が存在しません",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(tt.cfg)
+			p, err := a.Authenticate(tt.request)
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("Authenticate() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() エラー = %v, 期待値 = nil", err)
+			}
+			if p.Subject != "hmac" || p.Claims["sub"] != "hmac" {
+				t.Errorf("Authenticate() principal = %+v", p)
+			}
+			// 署名の検証後もプロキシがボディを転送できる
+			if forwarded, _ := io.ReadAll(tt.request.Body); string(forwarded) != body {
+				t.Errorf("認証後のボディ = %q, 期待値 = %q", forwarded, body)
+			}
+		})
+	}
+}
+
+func TestHMACAuthenticator_Replay(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "whsec")
+	now := time.Unix(1760000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := `{"event":"balance.updated"}`
+
+	t.Run("エラー: 同じ署名の再送", func(t *testing.T) {
+		a, _ := NewHMACAuthenticator(HMACConfig{SecretEnv: "TEST_WEBHOOK_SECRET", Identity: "payment-provider"})
+		a.now = func() time.Time { return now }
+		request := makeWebhookRequest(body, map[string]string{
+			"x-signature":           hex.EncodeToString(sign(sha256.New, "whsec", ts+"."+body)),
+			"x-signature-timestamp": ts,
+		})
+
+		p, err := a.Authenticate(request)
+		if err != nil || p.Subject != "payment-provider" {
+			t.Fatalf("Authenticate() = %+v, %v", p, err)
+		}
+		if _, err := a.Authenticate(request); err == nil || !contains(err.Error(), "再送") {
+			t.Errorf("Authenticate() エラー = %v, 期待値に含まれるべき文字列 = 再送", err)
+		}
+	})
+
+	t.Run("エラー: 同じ nonce の再送", func(t *testing.T) {
+		a, _ := NewHMACAuthenticator(HMACConfig{SecretEnv: "TEST_WEBHOOK_SECRET", NonceHeader: "X-Nonce", PayloadTemplate: "{nonce}.{timestamp}.{body}"})
+		a.now = func() time.Time { return now }
+		requestWithNonce := func(nonce, ts string) *http.Request {
+			return makeWebhookRequest(body, map[string]string{
+				"x-signature":           hex.EncodeToString(sign(sha256.New, "whsec", nonce+"."+ts+"."+body)),
+				"x-signature-timestamp": ts,
+				"x-nonce":               nonce,
+			})
+		}
+
+		if _, err := a.Authenticate(requestWithNonce("n-1", ts)); err != nil {
+			t.Fatalf("Authenticate() エラー = %v", err)
+		}
+		if _, err := a.Authenticate(requestWithNonce("n-2", ts)); err != nil {
+			t.Errorf("Authenticate() 別の nonce でエラー = %v", err)
+		}
+		// 署名した nonce だけを差し替えた再送は署名が一致しない
+		replayed := requestWithNonce("n-1", ts)
+		replayed.Header.Set("X-Nonce", "n-3")
+		if _, err := a.Authenticate(replayed); err == nil || !contains(err.Error(), "署名が一致しません") {
+			t.Errorf("Authenticate() エラー = %v, 期待値に含まれるべき文字列 = 署名が一致しません", err)
+		}
+		// タイムスタンプを変えて再署名しても同じ nonce は受け付けない
+		ts2 := strconv.FormatInt(now.Add(time.Second).Unix(), 10)
+		if _, err := a.Authenticate(requestWithNonce("n-1", ts2)); err == nil || !contains(err.Error(), "再送") {
+			t.Errorf("Authenticate() エラー = %v, 期待値に含まれるべき文字列 = 再送", err)
+		}
+	})
+
+	t.Run("エラー: 有効期限内の nonce で上限に達した", func(t *testing.T) {
+		a, _ := NewHMACAuthenticator(HMACConfig{SecretEnv: "TEST_WEBHOOK_SECRET", NonceHeader: "X-Nonce", PayloadTemplate: "{nonce}.{timestamp}.{body}", NonceCacheSize: 2})
+		current := now
+		a.now = func() time.Time { return current }
+		requestWithNonce := func(nonce string) *http.Request {
+			ts := strconv.FormatInt(current.Unix(), 10)
+			return makeWebhookRequest(body, map[string]string{
+				"x-signature":           hex.EncodeToString(sign(sha256.New, "whsec", nonce+"."+ts+"."+body)),
+				"x-signature-timestamp": ts,
+				"x-nonce":               nonce,
+			})
+		}
+
+		for _, nonce := range []string{"n-1", "n-2"} {
+			if _, err := a.Authenticate(requestWithNonce(nonce)); err != nil {
+				t.Fatalf("Authenticate(%s) エラー = %v", nonce, err)
+			}
+		}
+		if _, err := a.Authenticate(requestWithNonce("n-3")); err == nil || !contains(err.Error(), "上限") {
+			t.Errorf("Authenticate(n-3) エラー = %v, 期待値に含まれるべき文字列 = 上限", err)
+		}
+		// 上限に達しても有効期限内の nonce は追い出さず、再送を拒否し続ける
+		if _, err := a.Authenticate(requestWithNonce("n-1")); err == nil || !contains(err.Error(), "再送") {
+			t.Errorf("Authenticate(n-1) エラー = %v, 期待値に含まれるべき文字列 = 再送", err)
+		}
+
+		// 記録した nonce の有効期限が切れると新しいリクエストを受け付ける
+		current = now.Add(defaultHMACTolerance + time.Second)
+		if _, err := a.Authenticate(requestWithNonce("n-3")); err != nil {
+			t.Errorf("Authenticate(n-3) 期限切れ後のエラー = %v", err)
+		}
+	})
+
+	t.Run("エラー: 署名ヘッダーなし", func(t *testing.T) {
+		a, _ := NewHMACAuthenticator(HMACConfig{SecretEnv: "TEST_WEBHOOK_SECRET"})
+		_, err := a.Authenticate(makeWebhookRequest(body, map[string]string{"authorization": "Bearer token"}))
+		if !errors.Is(err, ErrNoCredentials) {
+			t.Errorf("Authenticate() エラー = %v, 期待値 = ErrNoCredentials", err)
+		}
+	})
+}
+
+func TestNewHMACAuthenticator(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EMPTY_WEBHOOK_SECRET", "")
+
+	a, err := NewHMACAuthenticator(HMACConfig{SecretFile: secretFile})
+	if err != nil {
+		t.Fatalf("NewHMACAuthenticator() エラー = %v", err)
+	}
+	if string(a.secret) != "file-secret" {
+		t.Errorf("共有鍵 = %q, 期待値 = file-secret", a.secret)
+	}
+
+	tests := []struct {
+		name string
+		cfg  HMACConfig
+	}{
+		{"エラー: 共有鍵の指定なし", HMACConfig{}},
+		{"エラー: 共有鍵の環境変数が空", HMACConfig{SecretEnv: "EMPTY_WEBHOOK_SECRET"}},
+		{"エラー: 共有鍵ファイルが存在しない", HMACConfig{SecretFile: filepath.Join(t.TempDir(), "missing")}},
+		{"エラー: 不明なアルゴリズム", HMACConfig{SecretFile: secretFile, Algorithm: "md5"}},
+		{"エラー: 不明なエンコード方式", HMACConfig{SecretFile: secretFile, Encoding: "base32"}},
+		{"エラー: tolerance の形式が不正", HMACConfig{SecretFile: secretFile, Tolerance: "5"}},
+		{"エラー: 署名しない nonce_header", HMACConfig{SecretFile: secretFile, NonceHeader: "X-Nonce"}},
+		{"エラー: nonce_cache_size が負", HMACConfig{SecretFile: secretFile, NonceCacheSize: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHMACAuthenticator(tt.cfg); err == nil {
+				t.Errorf("NewHMACAuthenticator() エラーが期待されましたが、nil が返されました")
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 有効と判定したトークンのキャッシュ件数の既定値
+const defaultIntrospectionCacheSize = 1000
+
+// IntrospectionConfig はトークンイントロスペクション (RFC 7662) の設定
+type IntrospectionConfig struct {
+	// Endpoint はイントロスペクションエンドポイントの URL
+	Endpoint string
+	// ClientID / ClientSecret はエンドポイントの認証に使うクライアントクレデンシャル (client_secret_basic)
+	ClientID     string
+	ClientSecret string
+	// Issuer を指定した場合、レスポンスの iss が一致するトークンのみ受け付ける
+	Issuer string
+	// Audience を指定した場合、レスポンスの aud に含まれるトークンのみ受け付ける
+	Audience string
+	// CacheSize は有効と判定したトークンをキャッシュする最大件数。0 以下の場合はキャッシュしない
+	CacheSize int
+	// HTTPClient を省略した場合はタイムアウト 10 秒のクライアントを使用する
+	HTTPClient *http.Client
+}
+
+// IntrospectionValidator は不透明なアクセストークンをイントロスペクションエンドポイントに問い合わせて検証する
+type IntrospectionValidator struct {
+	cfg    IntrospectionConfig
+	client *http.Client
+	cache  *lruCache[jwt.MapClaims]
+}
+
+// NewIntrospectionValidator は設定値から IntrospectionValidator を生成する
+func NewIntrospectionValidator(cfg IntrospectionConfig) (*IntrospectionValidator, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("イントロスペクションエンドポイントが設定されていません")
+	}
+	if _, err := url.ParseRequestURI(cfg.Endpoint); err != nil {
+		return nil, fmt.Errorf("イントロスペクションエンドポイントの URL が不正です: %w", err)
+	}
+	if cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, errors.New("イントロスペクションのクライアントクレデンシャルが設定されていません")
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &IntrospectionValidator{
+		cfg:    cfg,
+		client: client,
+		cache:  newLRUCache[jwt.MapClaims](cfg.CacheSize),
+	}, nil
+}
+
+// 環境変数から IntrospectionValidator を初期化する
+//
+//   - INTROSPECTION_ENDPOINT       例: "https://idp.example.com/oauth2/introspect"
+//   - INTROSPECTION_CLIENT_ID
+//   - INTROSPECTION_CLIENT_SECRET
+//   - INTROSPECTION_ISSUER         指定した場合は iss が一致するトークンのみ受け付ける
+//   - INTROSPECTION_AUDIENCE       指定した場合は aud に含まれるトークンのみ受け付ける
+//   - INTROSPECTION_CACHE_SIZE     キャッシュ件数 (既定: 1000、0 で無効)
+func NewIntrospectionValidatorFromEnv() (*IntrospectionValidator, error) {
+	cfg := IntrospectionConfig{
+		Endpoint:     os.Getenv("INTROSPECTION_ENDPOINT"),
+		ClientID:     os.Getenv("INTROSPECTION_CLIENT_ID"),
+		ClientSecret: os.Getenv("INTROSPECTION_CLIENT_SECRET"),
+		Issuer:       os.Getenv("INTROSPECTION_ISSUER"),
+		Audience:     os.Getenv("INTROSPECTION_AUDIENCE"),
+		CacheSize:    defaultIntrospectionCacheSize,
+	}
+	if raw := os.Getenv("INTROSPECTION_CACHE_SIZE"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("INTROSPECTION_CACHE_SIZE の形式が不正です: %q", raw)
+		}
+		cfg.CacheSize = size
+	}
+	return NewIntrospectionValidator(cfg)
+}
+
+// ValidateToken はトークンをイントロスペクションし、active な場合はレスポンスのメンバーを claim として返す。
+// exp を含むレスポンスは exp までキャッシュする。
+func (v *IntrospectionValidator) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	if tokenString == "" {
+		return nil, errors.New("トークンが空です")
+	}
+
+	key := tokenCacheKey(tokenString)
+	if claims, ok := v.cache.Get(key); ok {
+		return copyClaims(claims), nil
+	}
+
+	claims, err := v.introspect(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return nil, fmt.Errorf("exp claimの取得に失敗しました: %w", err)
+	}
+	if exp != nil {
+		if !time.Now().Before(exp.Time) {
+			return nil, errors.New("トークンの有効期限が切れています")
+		}
+		v.cache.Add(key, claims, exp.Time)
+	}
+
+	return copyClaims(claims), nil
+}
+
+func (v *IntrospectionValidator) introspect(tokenString string) (jwt.MapClaims, error) {
+	form := url.Values{
+		"token":           {tokenString},
+		"token_type_hint": {"access_token"},
+	}
+	req, err := http.NewRequest(http.MethodPost, v.cfg.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("イントロスペクションリクエストの生成に失敗しました: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 2.3.1 に従い、クライアントクレデンシャルは URL エンコードしてから Basic 認証に使う
+	req.SetBasicAuth(url.QueryEscape(v.cfg.ClientID), url.QueryEscape(v.cfg.ClientSecret))
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("イントロスペクションエンドポイントへの接続に失敗しました: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("イントロスペクションエンドポイントがエラーを返しました: status=%d", resp.StatusCode)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("イントロスペクションレスポンスのパースに失敗しました: %w", err)
+	}
+
+	if active, _ := body["active"].(bool); !active {
+		return nil, errors.New("トークンが無効です")
+	}
+	delete(body, "active")
+
+	// active でも別のリソースサーバー向けのトークンは受け付けない (RFC 7662 4 章)
+	if v.cfg.Issuer != "" {
+		if iss, _ := body["iss"].(string); iss != v.cfg.Issuer {
+			return nil, errors.New("issuerが不正です")
+		}
+	}
+	if v.cfg.Audience != "" && !audienceMatches(body["aud"], []string{v.cfg.Audience}) {
+		return nil, errors.New("audienceが不正です")
+	}
+
+	// sub は任意のメンバーのため、クライアントクレデンシャルで発行されたトークンでは client_id を主体とする
+	if _, ok := body["sub"]; !ok {
+		if clientID, ok := body["client_id"].(string); ok && clientID != "" {
+			body["sub"] = clientID
+		}
+	}
+
+	return jwt.MapClaims(body), nil
+}
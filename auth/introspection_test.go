@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// テスト用のイントロスペクションサーバーを起動する。responses はトークンごとのレスポンス
+func newIntrospectionServer(t *testing.T, responses map[string]map[string]interface{}, calls *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+
+		id, secret, ok := r.BasicAuth()
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+		if !ok || id != "gateway" || secret != "s3cr%t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.FormValue("token_type_hint") != "access_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resp, ok := responses[r.FormValue("token")]
+		if !ok {
+			resp = map[string]interface{}{"active": false}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestIntrospectionValidator_ValidateToken(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	responses := map[string]map[string]interface{}{
+		"opaque-active": {
+			"active":    true,
+			"sub":       "partner-1",
+			"client_id": "partner-client",
+			"scope":     "read:balance write:balance",
+			"iss":       "https://idp.example.com/",
+			"aud":       []string{"https://api.example.com", "https://other.example.com"},
+			"exp":       exp,
+		},
+		"opaque-client": {
+			"active":    true,
+			"client_id": "partner-client",
+			"scope":     "write:balance",
+			"iss":       "https://idp.example.com/",
+			"aud":       "https://api.example.com",
+			"exp":       exp,
+		},
+		"opaque-expired": {
+			"active": true,
+			"sub":    "partner-1",
+			"iss":    "https://idp.example.com/",
+			"aud":    "https://api.example.com",
+			"exp":    time.Now().Add(-time.Minute).Unix(),
+		},
+		"opaque-other-issuer": {
+			"active": true,
+			"sub":    "partner-1",
+			"iss":    "https://other-idp.example.com/",
+			"aud":    "https://api.example.com",
+			"exp":    exp,
+		},
+		"opaque-other-audience": {
+			"active": true,
+			"sub":    "partner-1",
+			"iss":    "https://idp.example.com/",
+			"aud":    "https://other.example.com",
+			"exp":    exp,
+		},
+		"opaque-no-audience": {
+			"active": true,
+			"sub":    "partner-1",
+			"iss":    "https://idp.example.com/",
+			"exp":    exp,
+		},
+	}
+	var calls int32
+	server := newIntrospectionServer(t, responses, &calls)
+
+	v, err := NewIntrospectionValidator(IntrospectionConfig{
+		Endpoint:     server.URL,
+		ClientID:     "gateway",
+		ClientSecret: "s3cr%t",
+		Issuer:       "https://idp.example.com/",
+		Audience:     "https://api.example.com",
+		CacheSize:    10,
+	})
+	if err != nil {
+		t.Fatalf("NewIntrospectionValidator() エラー = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		wantSubject string
+		wantError   string
+	}{
+		{name: "正常系: active なトークン", token: "opaque-active", wantSubject: "partner-1"},
+		{name: "正常系: sub のないトークンは client_id を主体とする", token: "opaque-client", wantSubject: "partner-client"},
+		{name: "エラー: iss が異なる", token: "opaque-other-issuer", wantError: "issuerが不正です"},
+		{name: "エラー: aud が異なる", token: "opaque-other-audience", wantError: "audienceが不正です"},
+		{name: "エラー: aud がない", token: "opaque-no-audience", wantError: "audienceが不正です"},
+		{name: "エラー: active ではないトークン", token: "opaque-unknown", wantError: "トークンが無効です"},
+		{name: "エラー: exp を過ぎたトークン", token: "opaque-expired", wantError: "有効期限が切れています"},
+		{name: "エラー: 空のトークン", token: "", wantError: "トークンが空です"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.ValidateToken(tt.token)
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("ValidateToken() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateToken() エラー = %v, 期待値 = nil", err)
+			}
+			if _, ok := claims["active"]; ok {
+				t.Errorf("ValidateToken() claims に active が含まれています")
+			}
+
+			p, err := NewPrincipal(claims)
+			if err != nil {
+				t.Fatalf("NewPrincipal() エラー = %v", err)
+			}
+			if p.Subject != tt.wantSubject || p.ClientID != "partner-client" || !p.HasScope("write:balance") {
+				t.Errorf("NewPrincipal() = %+v", p)
+			}
+			if p.ExpiresAt.Unix() != exp {
+				t.Errorf("NewPrincipal() ExpiresAt = %v, 期待値 = %v", p.ExpiresAt.Unix(), exp)
+			}
+		})
+	}
+
+	// 有効なトークンはキャッシュされ、2 回目以降はエンドポイントに問い合わせない
+	before := atomic.LoadInt32(&calls)
+	for i := 0; i < 3; i++ {
+		claims, err := v.ValidateToken("opaque-active")
+		if err != nil {
+			t.Fatalf("ValidateToken() エラー = %v", err)
+		}
+		claims["sub"] = "tampered"
+	}
+	if got := atomic.LoadInt32(&calls) - before; got != 0 {
+		t.Errorf("キャッシュ済みトークンの問い合わせ回数 = %d, 期待値 = 0", got)
+	}
+	claims, _ := v.ValidateToken("opaque-active")
+	if claims["sub"] != "partner-1" {
+		t.Errorf("キャッシュした claim が呼び出し元の変更の影響を受けました: %v", claims["sub"])
+	}
+
+	// 無効なトークンはキャッシュしない
+	before = atomic.LoadInt32(&calls)
+	_, _ = v.ValidateToken("opaque-unknown")
+	_, _ = v.ValidateToken("opaque-unknown")
+	if got := atomic.LoadInt32(&calls) - before; got != 2 {
+		t.Errorf("無効なトークンの問い合わせ回数 = %d, 期待値 = 2", got)
+	}
+}
+
+func TestIntrospectionValidator_EndpointErrors(t *testing.T) {
+	var calls int32
+	server := newIntrospectionServer(t, nil, &calls)
+
+	t.Run("エラー: クライアント認証に失敗", func(t *testing.T) {
+		v, _ := NewIntrospectionValidator(IntrospectionConfig{Endpoint: server.URL, ClientID: "gateway", ClientSecret: "wrong"})
+		if _, err := v.ValidateToken("opaque"); err == nil || !contains(err.Error(), "status=401") {
+			t.Errorf("ValidateToken() エラー = %v, 期待値に含まれるべき文字列 = status=401", err)
+		}
+	})
+
+	t.Run("エラー: 接続できない", func(t *testing.T) {
+		v, _ := NewIntrospectionValidator(IntrospectionConfig{Endpoint: "http://127.0.0.1:19999", ClientID: "gateway", ClientSecret: "s3cr%t"})
+		if _, err := v.ValidateToken("opaque"); err == nil || !contains(err.Error(), "接続に失敗しました") {
+			t.Errorf("ValidateToken() エラー = %v, 期待値に含まれるべき文字列 = 接続に失敗しました", err)
+		}
+	})
+}
+
+func TestNewIntrospectionValidatorFromEnv(t *testing.T) {
+	t.Setenv("INTROSPECTION_ENDPOINT", "https://idp.example.com/oauth2/introspect")
+	t.Setenv("INTROSPECTION_CLIENT_ID", "gateway")
+	t.Setenv("INTROSPECTION_CLIENT_SECRET", "secret")
+	t.Setenv("INTROSPECTION_ISSUER", "https://idp.example.com/")
+	t.Setenv("INTROSPECTION_AUDIENCE", "https://api.example.com")
+	t.Setenv("INTROSPECTION_CACHE_SIZE", "")
+
+	v, err := NewIntrospectionValidatorFromEnv()
+	if err != nil {
+		t.Fatalf("NewIntrospectionValidatorFromEnv() エラー = %v", err)
+	}
+	if v.cache.capacity != defaultIntrospectionCacheSize {
+		t.Errorf("キャッシュ件数 = %d, 期待値 = %d", v.cache.capacity, defaultIntrospectionCacheSize)
+	}
+	if v.cfg.Issuer != "https://idp.example.com/" || v.cfg.Audience != "https://api.example.com" {
+		t.Errorf("Issuer = %q, Audience = %q", v.cfg.Issuer, v.cfg.Audience)
+	}
+
+	t.Setenv("INTROSPECTION_CLIENT_SECRET", "")
+	if _, err := NewIntrospectionValidatorFromEnv(); err == nil {
+		t.Errorf("NewIntrospectionValidatorFromEnv() エラーが期待されましたが、nil が返されました")
+	}
+
+	t.Setenv("INTROSPECTION_CLIENT_SECRET", "secret")
+	t.Setenv("INTROSPECTION_CACHE_SIZE", "many")
+	if _, err := NewIntrospectionValidatorFromEnv(); err == nil {
+		t.Errorf("NewIntrospectionValidatorFromEnv() エラーが期待されましたが、nil が返されました")
+	}
+}
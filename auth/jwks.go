@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MicahParks/jwkset"
+	"github.com/MicahParks/keyfunc/v3"
+	"golang.org/x/time/rate"
+
+	"github.com/aki80204/go-gateway/metrics"
+)
+
+const (
+	defaultJWKSRefreshInterval    = time.Hour
+	defaultJWKSUnknownKIDInterval = 5 * time.Minute
+	// JWKS の取得 1 回あたりのタイムアウト。未知の kid による取得はリクエストの処理中に行われるため短くする
+	jwksHTTPTimeout = 5 * time.Second
+)
+
+// JWKSOptions は JWKS の取得と更新の設定
+type JWKSOptions struct {
+	// RefreshInterval は JWKS を定期的に取得し直す間隔 (既定: 1 時間)
+	RefreshInterval time.Duration
+	// UnknownKIDInterval は未知の kid のトークンを受け取ったときに JWKS を取得し直す最小間隔 (既定: 5 分)
+	UnknownKIDInterval time.Duration
+}
+
+// 環境変数から JWKS の取得設定を読み込む
+//
+//   - JWKS_REFRESH_INTERVAL      例: "30m"
+//   - JWKS_UNKNOWN_KID_INTERVAL  例: "1m"
+func jwksOptionsFromEnv() (JWKSOptions, error) {
+	var opts JWKSOptions
+	var err error
+	if opts.RefreshInterval, err = durationFromEnv("JWKS_REFRESH_INTERVAL"); err != nil {
+		return JWKSOptions{}, err
+	}
+	if opts.UnknownKIDInterval, err = durationFromEnv("JWKS_UNKNOWN_KID_INTERVAL"); err != nil {
+		return JWKSOptions{}, err
+	}
+	return opts, nil
+}
+
+// newJWKSKeyfunc は jwksURL の JWKS で署名を検証する Keyfunc を生成する。
+// fallbackFile を指定した場合は、その JWKS を取得できなかったときや取得した JWKS にない kid の検証に使い、
+// 初回の取得に失敗しても初期化を続ける。指定しない場合は初回の取得に失敗するとエラーを返す。
+//
+// JWKS の定期的な取得は ctx がキャンセルされるまで続ける。生成に失敗した場合も取得は開始済みのため、
+// 呼び出し側で ctx をキャンセルすること。
+func newJWKSKeyfunc(ctx context.Context, issuer, jwksURL, fallbackFile string, opts JWKSOptions) (keyfunc.Keyfunc, error) {
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = defaultJWKSRefreshInterval
+	}
+	if opts.UnknownKIDInterval <= 0 {
+		opts.UnknownKIDInterval = defaultJWKSUnknownKIDInterval
+	}
+
+	u, err := url.ParseRequestURI(jwksURL)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS の URL が不正です: %w", err)
+	}
+
+	given := jwkset.NewMemoryStorage()
+	if fallbackFile != "" {
+		if given, err = loadFallbackJWKS(fallbackFile); err != nil {
+			return nil, err
+		}
+	}
+
+	health := &jwksHealth{issuer: issuer, healthy: true}
+	remote, err := jwkset.NewStorageFromHTTP(u, jwkset.HTTPClientStorageOptions{
+		Client:                    &http.Client{Transport: &healthTransport{base: http.DefaultTransport, health: health}},
+		Ctx:                       ctx,
+		HTTPTimeout:               jwksHTTPTimeout,
+		NoErrorReturnFirstHTTPReq: fallbackFile != "",
+		RefreshInterval:           opts.RefreshInterval,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := jwkset.NewHTTPClient(jwkset.HTTPClientOptions{
+		Given:          given,
+		HTTPURLs:       map[string]jwkset.Storage{u.String(): remote},
+		PrioritizeHTTP: true,
+		// 待ち時間がこれを超える場合は待たずに未知の kid として扱う
+		RateLimitWaitMax:  jwksHTTPTimeout,
+		RefreshUnknownKID: rate.NewLimiter(rate.Every(opts.UnknownKIDInterval), 1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keyfunc.New(keyfunc.Options{Ctx: ctx, Storage: storage})
+}
+
+// 同梱した JWKS ファイルを読み込む
+func loadFallbackJWKS(path string) (jwkset.Storage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("フォールバック用 JWKS の読み込みに失敗しました: %w", err)
+	}
+	var jwks jwkset.JWKSMarshal
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("フォールバック用 JWKS の形式が不正です (%s): %w", path, err)
+	}
+	if len(jwks.Keys) == 0 {
+		return nil, fmt.Errorf("フォールバック用 JWKS に鍵がありません (%s)", path)
+	}
+	storage, err := jwks.ToStorage()
+	if err != nil {
+		return nil, fmt.Errorf("フォールバック用 JWKS の鍵が不正です (%s): %w", path, err)
+	}
+	return storage, nil
+}
+
+// jwksHealth は issuer ごとの JWKS の取得状況。取得のたびにメトリクスを出力し、状態が変わったときにログを出力する
+type jwksHealth struct {
+	mu                  sync.Mutex
+	issuer              string
+	healthy             bool
+	consecutiveFailures int
+}
+
+func (h *jwksHealth) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	dimensions := map[string]string{"Issuer": h.issuer}
+	if err != nil {
+		h.consecutiveFailures++
+		if h.healthy {
+			log.Printf("JWKS の取得に失敗しました (issuer=%s): %v", h.issuer, err)
+		}
+		h.healthy = false
+		metrics.Emit("JWKSRefreshFailure", 1, metrics.UnitCount, dimensions)
+		metrics.Emit("JWKSHealthy", 0, metrics.UnitNone, dimensions)
+		return
+	}
+
+	if !h.healthy {
+		log.Printf("JWKS の取得が回復しました (issuer=%s, 連続失敗回数=%d)", h.issuer, h.consecutiveFailures)
+	}
+	h.healthy = true
+	h.consecutiveFailures = 0
+	metrics.Emit("JWKSHealthy", 1, metrics.UnitNone, dimensions)
+}
+
+// healthTransport は JWKS の取得結果を jwksHealth に記録する
+type healthTransport struct {
+	base   http.RoundTripper
+	health *jwksHealth
+}
+
+func (t *healthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Close で取得を止めた場合は IdP の障害として扱わない
+	case err != nil:
+		t.health.record(err)
+	case resp.StatusCode != http.StatusOK:
+		t.health.record(fmt.Errorf("HTTP ステータス %d", resp.StatusCode))
+	default:
+		t.health.record(nil)
+	}
+	return resp, err
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewValidatorFromConfig_FallbackJWKS(t *testing.T) {
+	privateKey, publicKey := generateTestKeyPair(t)
+
+	// IdP の障害を模した JWKS サーバー
+	jwksResponse, _ := generateJWKSResponse(publicKey, "test-kid-1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	issuer := server.URL + "/"
+	audience := "https://api.example.com"
+
+	t.Run("エラー: フォールバックなしで JWKS を取得できない", func(t *testing.T) {
+		_, err := NewValidatorFromConfig(context.Background(), ValidatorConfig{
+			Issuers: []IssuerConfig{{Issuer: issuer, Audiences: []string{audience}}},
+		})
+		if err == nil || !contains(err.Error(), "JWKS の取得に失敗しました") {
+			t.Errorf("NewValidatorFromConfig() エラー = %v, 期待値に含まれるべき文字列 = JWKS の取得に失敗しました", err)
+		}
+	})
+
+	t.Run("正常系: 同梱した JWKS で検証する", func(t *testing.T) {
+		fallback := filepath.Join(t.TempDir(), "jwks.json")
+		if err := os.WriteFile(fallback, jwksResponse, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		validator, err := NewValidatorFromConfig(context.Background(), ValidatorConfig{
+			Issuers: []IssuerConfig{{Issuer: issuer, Audiences: []string{audience}, FallbackJWKSFile: fallback}},
+		})
+		if err != nil {
+			t.Fatalf("NewValidatorFromConfig() エラー = %v, 期待値 = nil", err)
+		}
+		if _, err := validator.ValidateToken(generateTestToken(t, privateKey, issuer, audience, time.Hour)); err != nil {
+			t.Errorf("ValidateToken() エラー = %v, 期待値 = nil", err)
+		}
+	})
+
+	t.Run("エラー: 同梱した JWKS の形式が不正", func(t *testing.T) {
+		fallback := filepath.Join(t.TempDir(), "jwks.json")
+		if err := os.WriteFile(fallback, []byte(`{"keys": []}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := NewValidatorFromConfig(context.Background(), ValidatorConfig{
+			Issuers: []IssuerConfig{{Issuer: issuer, Audiences: []string{audience}, FallbackJWKSFile: fallback}},
+		})
+		if err == nil || !contains(err.Error(), "フォールバック") {
+			t.Errorf("NewValidatorFromConfig() エラー = %v, 期待値に含まれるべき文字列 = フォールバック", err)
+		}
+	})
+}
+
+func TestNewValidatorFromConfig_StopsRefreshOnFailure(t *testing.T) {
+	_, publicKey := generateTestKeyPair(t)
+	jwksResponse, _ := generateJWKSResponse(publicKey, "test-kid-1")
+
+	var fetches atomic.Int64
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jwksResponse)
+	}))
+	t.Cleanup(healthy.Close)
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(unavailable.Close)
+
+	// 定期的な取得が止まっていれば、待っている間に取得回数は増えない
+	assertNoRefresh := func(t *testing.T) {
+		t.Helper()
+		time.Sleep(50 * time.Millisecond)
+		before := fetches.Load()
+		time.Sleep(200 * time.Millisecond)
+		if after := fetches.Load(); after != before {
+			t.Errorf("JWKS の取得回数 = %d → %d, 期待値 = 増えない", before, after)
+		}
+	}
+	jwksOpts := JWKSOptions{RefreshInterval: 10 * time.Millisecond}
+
+	t.Run("後続の issuer の初期化に失敗した場合は取得を止める", func(t *testing.T) {
+		_, err := NewValidatorFromConfig(context.Background(), ValidatorConfig{
+			Issuers: []IssuerConfig{
+				{Issuer: healthy.URL + "/", Audiences: []string{"a"}},
+				{Issuer: unavailable.URL + "/", Audiences: []string{"b"}},
+			},
+			JWKS: jwksOpts,
+		})
+		if err == nil {
+			t.Fatal("NewValidatorFromConfig() エラー = nil, 期待値 = エラー")
+		}
+		assertNoRefresh(t)
+	})
+
+	t.Run("Close で取得を止める", func(t *testing.T) {
+		validator, err := NewValidatorFromConfig(context.Background(), ValidatorConfig{
+			Issuers: []IssuerConfig{{Issuer: healthy.URL + "/", Audiences: []string{"a"}}},
+			JWKS:    jwksOpts,
+		})
+		if err != nil {
+			t.Fatalf("NewValidatorFromConfig() エラー = %v, 期待値 = nil", err)
+		}
+		before := fetches.Load()
+		time.Sleep(100 * time.Millisecond)
+		if fetches.Load() == before {
+			t.Fatal("JWKS が定期的に取得されていません")
+		}
+		validator.Close()
+		assertNoRefresh(t)
+	})
+}
+
+func TestJWKSHealth_Record(t *testing.T) {
+	h := &jwksHealth{issuer: "https://idp.example.com/", healthy: true}
+
+	h.record(errors.New("connection refused"))
+	h.record(errors.New("connection refused"))
+	if h.healthy || h.consecutiveFailures != 2 {
+		t.Errorf("失敗後の状態 = healthy:%v failures:%d, 期待値 = healthy:false failures:2", h.healthy, h.consecutiveFailures)
+	}
+
+	h.record(nil)
+	if !h.healthy || h.consecutiveFailures != 0 {
+		t.Errorf("回復後の状態 = healthy:%v failures:%d, 期待値 = healthy:true failures:0", h.healthy, h.consecutiveFailures)
+	}
+}
+
+func TestJWKSOptionsFromEnv(t *testing.T) {
+	t.Setenv("JWKS_REFRESH_INTERVAL", "30m")
+	t.Setenv("JWKS_UNKNOWN_KID_INTERVAL", "1m")
+	opts, err := jwksOptionsFromEnv()
+	if err != nil {
+		t.Fatalf("jwksOptionsFromEnv() エラー = %v", err)
+	}
+	if opts.RefreshInterval != 30*time.Minute || opts.UnknownKIDInterval != time.Minute {
+		t.Errorf("jwksOptionsFromEnv() = %+v", opts)
+	}
+
+	t.Setenv("JWKS_UNKNOWN_KID_INTERVAL", "1")
+	if _, err := jwksOptionsFromEnv(); err == nil {
+		t.Errorf("jwksOptionsFromEnv() 不正な間隔で error = nil")
+	}
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"crypto"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -12,10 +14,52 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// IssuerConfig は信頼する issuer ごとの設定
+type IssuerConfig struct {
+	// Issuer はトークンの iss claim と完全一致で比較する値
+	Issuer string `json:"issuer"`
+	// JWKSURL を省略した場合は Issuer に "/.well-known/jwks.json" を付けた URL を使用する
+	JWKSURL string `json:"jwks_url"`
+	// Audiences のいずれかが aud claim に含まれていれば受け付ける
+	Audiences []string `json:"audiences"`
+	// Routes を指定した場合、この issuer のトークンは列挙したパス配下でのみ受け付ける
+	Routes []string `json:"routes"`
+	// Algorithms を指定した場合、この issuer については ValidationOptions.Algorithms の代わりに使用する
+	Algorithms []string `json:"algorithms"`
+	// FallbackJWKSFile を指定した場合、JWKS を取得できないときはこのファイルの鍵で検証する
+	FallbackJWKSFile string `json:"fallback_jwks_file"`
+}
+
+// ValidatorConfig は Validator の初期化設定
+type ValidatorConfig struct {
+	Issuers []IssuerConfig
+	Options ValidationOptions
+	JWKS    JWKSOptions
+}
+
+type trustedIssuer struct {
+	keyfunc   keyfunc.Keyfunc
+	parser    *jwt.Parser
+	audiences []string
+	routes    []string
+}
+
 type Validator struct {
-	keyfunc  keyfunc.Keyfunc
-	issuer   string
-	audience string
+	issuers map[string]*trustedIssuer
+	options ValidationOptions
+	// cache は検証済みのトークンを exp (最大 CacheMaxTTL) まで保持する
+	cache *lruCache[*validatedToken]
+	// cancel は JWKS の定期的な取得を止める
+	cancel context.CancelFunc
+}
+
+// validatedToken は検証済みトークンのキャッシュエントリ。
+// 署名に使った鍵を保持し、JWKS のローテーションで鍵が変わった場合はキャッシュを使わない
+type validatedToken struct {
+	issuer *trustedIssuer
+	header map[string]interface{}
+	key    crypto.PublicKey
+	claims jwt.MapClaims
 }
 
 // 環境変数を使用してAuth0のValidatorを初期化する
@@ -23,45 +67,179 @@ type Validator struct {
 // 必要な環境変数:
 //   - AUTH0_DOMAIN  例: "example-region.auth0.com" または "https://example-region.auth0.com"
 //   - AUTH0_AUDIENCE (API Identifier)
+//
+// 署名アルゴリズムや claim の検証方法は JWT_* 環境変数、JWKS の取得間隔は JWKS_* 環境変数で変更できる
+// (validationOptionsFromEnv、jwksOptionsFromEnv を参照)。
+// JWKS_FALLBACK_FILE を設定すると、JWKS を取得できない場合にそのファイルの鍵で検証する。
+//
+// 複数の issuer を信頼する場合は AUTH_ISSUERS に IssuerConfig の JSON 配列を設定する。
+// AUTH_ISSUERS が設定されている場合、AUTH0_DOMAIN/AUTH0_AUDIENCE は参照しない。
+//
+//	AUTH_ISSUERS='[{"issuer":"https://a.auth0.com/","audiences":["https://api.example.com"]},
+//	               {"issuer":"https://idp.example.com/","audiences":["batch"],"routes":["/api/customers/balance"]}]'
 func NewValidator(ctx context.Context) (*Validator, error) {
+	cfg, err := validatorConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewValidatorFromConfig(ctx, cfg)
+}
+
+func validatorConfigFromEnv() (ValidatorConfig, error) {
+	opts, err := validationOptionsFromEnv()
+	if err != nil {
+		return ValidatorConfig{}, err
+	}
+	jwksOpts, err := jwksOptionsFromEnv()
+	if err != nil {
+		return ValidatorConfig{}, err
+	}
+
+	if raw := os.Getenv("AUTH_ISSUERS"); raw != "" {
+		var issuers []IssuerConfig
+		if err := json.Unmarshal([]byte(raw), &issuers); err != nil {
+			return ValidatorConfig{}, fmt.Errorf("AUTH_ISSUERS の形式が不正です: %w", err)
+		}
+		return ValidatorConfig{Issuers: issuers, Options: opts, JWKS: jwksOpts}, nil
+	}
+
 	domain := os.Getenv("AUTH0_DOMAIN")
 	audience := os.Getenv("AUTH0_AUDIENCE")
 
 	if domain == "" || audience == "" {
-		return nil, fmt.Errorf("AUTH0_DOMAIN と AUTH0_AUDIENCE を環境変数に設定してください")
+		return ValidatorConfig{}, fmt.Errorf("AUTH0_DOMAIN と AUTH0_AUDIENCE を環境変数に設定してください")
 	}
 
-	// ドメインの正規化
-	if !strings.HasPrefix(domain, "https://") && !strings.HasPrefix(domain, "http://") {
-		domain = "https://" + domain
+	return ValidatorConfig{
+		Issuers: []IssuerConfig{{
+			Issuer:           normalizeIssuer(domain),
+			Audiences:        []string{audience},
+			FallbackJWKSFile: os.Getenv("JWKS_FALLBACK_FILE"),
+		}},
+		Options: opts,
+		JWKS:    jwksOpts,
+	}, nil
+}
+
+// NewValidatorFromConfig は設定値から Validator を初期化する。
+// issuer ごとに JWKS を取得するため、別の issuer の鍵で署名されたトークンは受け付けない。
+func NewValidatorFromConfig(ctx context.Context, cfg ValidatorConfig) (*Validator, error) {
+	if len(cfg.Issuers) == 0 {
+		return nil, errors.New("信頼する issuer が設定されていません")
 	}
-	domain = strings.TrimRight(domain, "/")
 
-	issuer := domain + "/"
-	jwksURL := issuer + ".well-known/jwks.json"
+	// JWKS の定期的な取得は init のタイムアウトに関係なく Close まで続ける。
+	// 初期化に失敗した場合は、それまでに開始した取得を止める
+	refreshCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
 
-	// keyfunc v3 のデフォルト設定で JWKS を取得する。
-	// 以後は 内部キャッシュを使いつつ、自動的にリフレッシュする仕組み
-	kf, err := keyfunc.NewDefaultCtx(ctx, []string{jwksURL})
-	if err != nil {
-		return nil, fmt.Errorf("JWKS の取得に失敗しました (%s): %w", jwksURL, err)
+	issuers := make(map[string]*trustedIssuer, len(cfg.Issuers))
+	for _, ic := range cfg.Issuers {
+		if ic.Issuer == "" || len(ic.Audiences) == 0 {
+			cancel()
+			return nil, fmt.Errorf("issuer と audiences は必須です: %+v", ic)
+		}
+		issuer := ic.Issuer
+		if _, dup := issuers[issuer]; dup {
+			cancel()
+			return nil, fmt.Errorf("issuer が重複しています: %s", issuer)
+		}
+
+		jwksURL := ic.JWKSURL
+		if jwksURL == "" {
+			jwksURL = strings.TrimRight(issuer, "/") + "/.well-known/jwks.json"
+		}
+
+		// JWKS を取得し、以後は内部キャッシュを使いつつ JWKSOptions の間隔で自動的にリフレッシュする
+		kf, err := newJWKSKeyfunc(refreshCtx, issuer, jwksURL, ic.FallbackJWKSFile, cfg.JWKS)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("JWKS の取得に失敗しました (%s): %w", jwksURL, err)
+		}
+
+		algorithms := ic.Algorithms
+		if len(algorithms) == 0 {
+			algorithms = cfg.Options.Algorithms
+		}
+
+		issuers[issuer] = &trustedIssuer{
+			keyfunc:   kf,
+			parser:    newParser(algorithms, cfg.Options),
+			audiences: ic.Audiences,
+			routes:    ic.Routes,
+		}
 	}
 
 	return &Validator{
-		keyfunc:  kf,
-		issuer:   issuer,
-		audience: audience,
+		issuers: issuers,
+		options: cfg.Options,
+		cache:   newLRUCache[*validatedToken](cfg.Options.CacheSize),
+		cancel:  cancel,
 	}, nil
 }
 
+// Close は JWKS の定期的な取得を止める。Close した Validator はキャッシュ済みの鍵でのみ検証できる
+func (v *Validator) Close() {
+	v.cancel()
+}
+
+// 署名アルゴリズムの許可リストと exp / nbf / iat の検証を組み込んだパーサーを生成する
+func newParser(algorithms []string, opts ValidationOptions) *jwt.Parser {
+	if len(algorithms) == 0 {
+		algorithms = defaultAlgorithms
+	}
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithLeeway(opts.Leeway),
+		jwt.WithIssuedAt(),
+	}
+	for _, c := range opts.RequiredClaims {
+		if c == "exp" {
+			parserOpts = append(parserOpts, jwt.WithExpirationRequired())
+		}
+	}
+	return jwt.NewParser(parserOpts...)
+}
+
+// AUTH0_DOMAIN のドメインを Auth0 の iss の形式に正規化する ("example.auth0.com" → "https://example.auth0.com/")
+func normalizeIssuer(domain string) string {
+	if !strings.HasPrefix(domain, "https://") && !strings.HasPrefix(domain, "http://") {
+		domain = "https://" + domain
+	}
+	return strings.TrimRight(domain, "/") + "/"
+}
+
 // ValidateToken は渡された JWT 文字列を検証し、有効な場合はクレームを返します。
+// 検証済みのトークンはキャッシュし、同じトークンの署名検証を省略する。
 func (v *Validator) ValidateToken(tokenString string) (jwt.MapClaims, error) {
 	if tokenString == "" {
 		return nil, errors.New("トークンが空です")
 	}
 
+	cacheKey := tokenCacheKey(tokenString)
+	if claims, ok := v.cachedClaims(cacheKey); ok {
+		return claims, nil
+	}
+
+	// 署名検証の前に iss を読み取り、使用する JWKS と audience を決める。
+	// ここで読んだ値は信頼せず、署名検証後に改めて照合する。
+	unverified, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("トークンのパースに失敗しました: %w", err)
+	}
+	unverifiedIss, _ := unverified.Claims.(jwt.MapClaims)["iss"].(string)
+	ti, ok := v.issuers[unverifiedIss]
+	if !ok {
+		return nil, errors.New("issuerが不正です")
+	}
+
 	// keyfunc v3のKeyfuncを使って署名検証を行う。
-	token, err := jwt.Parse(tokenString, v.keyfunc.Keyfunc)
+	// アルゴリズムの許可リスト、exp / nbf / iat (leeway 込み) の検証もここで行われる。
+	var key crypto.PublicKey
+	token, err := ti.parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		k, err := ti.keyfunc.Keyfunc(token)
+		key = k
+		return k, err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("トークンのパースに失敗しました: %w", err)
 	}
@@ -75,35 +253,136 @@ func (v *Validator) ValidateToken(tokenString string) (jwt.MapClaims, error) {
 		return nil, errors.New("トークンのclaim形式が想定外です")
 	}
 
-	// 有効期限の検証
-	if exp, err := claims.GetExpirationTime(); err != nil {
-		return nil, fmt.Errorf("exp claimの取得に失敗しました: %w", err)
-	} else if exp != nil && time.Now().After(exp.Time) {
-		return nil, errors.New("トークンの有効期限が切れています")
+	if err := v.validateRegisteredClaims(token, claims); err != nil {
+		return nil, err
 	}
 
 	// iss検証
-	if iss, ok := claims["iss"].(string); !ok || iss != v.issuer {
+	if iss, ok := claims["iss"].(string); !ok || iss != unverifiedIss {
 		return nil, errors.New("issuerが不正です")
 	}
 
 	// aud検証 (Auth0では文字列または配列のどちらか)
-	audValid := false
-	if aud, ok := claims["aud"].(string); ok {
-		if aud == v.audience {
-			audValid = true
-		}
-	} else if auds, ok := claims["aud"].([]interface{}); ok {
-		for _, a := range auds {
-			if s, ok := a.(string); ok && s == v.audience {
-				audValid = true
-				break
+	if !audienceMatches(claims["aud"], ti.audiences) {
+		return nil, errors.New("audienceが不正です")
+	}
+
+	v.cacheToken(cacheKey, &validatedToken{issuer: ti, header: token.Header, key: key, claims: claims})
+	return copyClaims(claims), nil
+}
+
+// キャッシュ済みのトークンの claim を返す。署名に使った鍵が JWKS から削除または変更されていればキャッシュを捨てる
+func (v *Validator) cachedClaims(cacheKey string) (jwt.MapClaims, bool) {
+	entry, ok := v.cache.Get(cacheKey)
+	if !ok {
+		return nil, false
+	}
+	current, err := entry.issuer.keyfunc.Keyfunc(&jwt.Token{Header: entry.header})
+	if err != nil || !publicKeyEqual(entry.key, current) {
+		v.cache.Remove(cacheKey)
+		return nil, false
+	}
+	return copyClaims(entry.claims), true
+}
+
+// 検証済みのトークンを exp、最大経過時間、CacheMaxTTL のうち最も早い時刻までキャッシュする
+func (v *Validator) cacheToken(cacheKey string, entry *validatedToken) {
+	maxTTL := v.options.CacheMaxTTL
+	if maxTTL <= 0 {
+		maxTTL = defaultCacheMaxTTL
+	}
+	expiresAt := v.cache.now().Add(maxTTL)
+	if exp, err := entry.claims.GetExpirationTime(); err == nil && exp != nil && exp.Before(expiresAt) {
+		expiresAt = exp.Time
+	}
+	if v.options.MaxTokenAge > 0 {
+		if iat, err := entry.claims.GetIssuedAt(); err == nil && iat != nil {
+			if limit := iat.Add(v.options.MaxTokenAge); limit.Before(expiresAt) {
+				expiresAt = limit
 			}
 		}
 	}
-	if !audValid {
-		return nil, errors.New("audienceが不正です")
+	v.cache.Add(cacheKey, entry, expiresAt)
+}
+
+func publicKeyEqual(a, b interface{}) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
+
+// パーサーが扱わない typ ヘッダー、必須 claim、トークンの最大経過時間を検証する
+func (v *Validator) validateRegisteredClaims(token *jwt.Token, claims jwt.MapClaims) error {
+	if v.options.ExpectedType != "" {
+		typ, _ := token.Header["typ"].(string)
+		if normalizeType(typ) != normalizeType(v.options.ExpectedType) {
+			return fmt.Errorf("typ ヘッダーが不正です: %q", typ)
+		}
+	}
+
+	for _, c := range v.options.RequiredClaims {
+		if claims[c] == nil {
+			return fmt.Errorf("必須claimが存在しません: %s", c)
+		}
 	}
 
-	return claims, nil
+	if v.options.MaxTokenAge > 0 {
+		iat, err := claims.GetIssuedAt()
+		if err != nil {
+			return fmt.Errorf("iat claimの取得に失敗しました: %w", err)
+		}
+		if iat == nil {
+			return errors.New("iat claimが存在しないためトークンの経過時間を検証できません")
+		}
+		if time.Since(iat.Time) > v.options.MaxTokenAge+v.options.Leeway {
+			return errors.New("トークンの発行から最大経過時間を超えています")
+		}
+	}
+
+	return nil
+}
+
+// AllowsRoute は issuer のトークンを path で受け付けてよいかを返す。
+// Routes を設定していない issuer や、この Validator が管理していない issuer は制限しない。
+func (v *Validator) AllowsRoute(issuer, path string) bool {
+	ti, ok := v.issuers[issuer]
+	if !ok || len(ti.routes) == 0 {
+		return true
+	}
+	for _, r := range ti.routes {
+		if pathHasPrefix(path, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func audienceMatches(claim interface{}, audiences []string) bool {
+	var values []string
+	switch aud := claim.(type) {
+	case string:
+		values = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	for _, v := range values {
+		for _, want := range audiences {
+			if v == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// パスがセグメント単位で prefix 配下にあるかを判定する ("/api/a" は "/api/ab" にはマッチしない)
+func pathHasPrefix(path, prefix string) bool {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
 }
@@ -6,10 +6,12 @@ import (
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -17,7 +19,7 @@ import (
 )
 
 // テスト用の RSA 鍵ペアを生成
-func generateTestKeyPair(t *testing.T) (*rsa.PrivateKey, *rsa.PublicKey) {
+func generateTestKeyPair(t testing.TB) (*rsa.PrivateKey, *rsa.PublicKey) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		t.Fatalf("鍵の生成に失敗しました: %v", err)
@@ -57,7 +59,7 @@ func generateJWKSResponse(publicKey *rsa.PublicKey, kid string) ([]byte, error)
 }
 
 // テスト用の JWT トークンを生成
-func generateTestToken(t *testing.T, privateKey *rsa.PrivateKey, issuer, audience string, expiresIn time.Duration) string {
+func generateTestToken(t testing.TB, privateKey *rsa.PrivateKey, issuer, audience string, expiresIn time.Duration) string {
 	now := time.Now()
 	claims := jwt.MapClaims{
 		"iss": issuer,
@@ -77,6 +79,21 @@ func generateTestToken(t *testing.T, privateKey *rsa.PrivateKey, issuer, audienc
 	return tokenString
 }
 
+// 任意の claim・ヘッダー・署名アルゴリズムでテスト用の JWT トークンを生成
+func generateTestTokenWithClaims(t *testing.T, privateKey *rsa.PrivateKey, method jwt.SigningMethod, claims jwt.MapClaims, header map[string]interface{}) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = "test-kid-1"
+	for k, v := range header {
+		token.Header[k] = v
+	}
+
+	tokenString, err := token.SignedString(privateKey)
+	if err != nil {
+		t.Fatalf("トークンの署名に失敗しました: %v", err)
+	}
+	return tokenString
+}
+
 func TestNewValidator(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -163,11 +180,13 @@ func TestNewValidator(t *testing.T) {
 					t.Errorf("NewValidator() validator = nil, 期待値 = 非 nil")
 					return
 				}
-				if validator.issuer == "" {
-					t.Errorf("NewValidator() issuer が空です")
+				ti, ok := validator.issuers["https://test-domain.auth0.com/"]
+				if !ok {
+					t.Errorf("NewValidator() issuer が登録されていません: %v", validator.issuers)
+					return
 				}
-				if validator.audience == "" {
-					t.Errorf("NewValidator() audience が空です")
+				if len(ti.audiences) != 1 || ti.audiences[0] != tt.auth0Audience {
+					t.Errorf("NewValidator() audiences = %v, 期待値 = [%v]", ti.audiences, tt.auth0Audience)
 				}
 			}
 		})
@@ -389,7 +408,418 @@ func TestValidateToken_AudienceArray(t *testing.T) {
 	}
 }
 
+// モック JWKS サーバーを起動する
+func newJWKSServer(t testing.TB, publicKey *rsa.PublicKey, kid string) *httptest.Server {
+	jwksResponse, err := generateJWKSResponse(publicKey, kid)
+	if err != nil {
+		t.Fatalf("JWKS レスポンスの生成に失敗しました: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/jwks.json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(jwksResponse)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewValidator_IssuersFromEnv(t *testing.T) {
+	t.Setenv("AUTH0_DOMAIN", "")
+	t.Setenv("AUTH0_AUDIENCE", "")
+
+	t.Run("エラー: AUTH_ISSUERS が JSON ではない", func(t *testing.T) {
+		t.Setenv("AUTH_ISSUERS", "not-json")
+		if _, err := NewValidator(context.Background()); err == nil || !contains(err.Error(), "AUTH_ISSUERS") {
+			t.Errorf("NewValidator() エラー = %v, 期待値に含まれるべき文字列 = AUTH_ISSUERS", err)
+		}
+	})
+
+	t.Run("エラー: audiences が空", func(t *testing.T) {
+		t.Setenv("AUTH_ISSUERS", `[{"issuer":"https://a.example.com/"}]`)
+		if _, err := NewValidator(context.Background()); err == nil || !contains(err.Error(), "audiences") {
+			t.Errorf("NewValidator() エラー = %v, 期待値に含まれるべき文字列 = audiences", err)
+		}
+	})
+
+	t.Run("エラー: issuer が重複", func(t *testing.T) {
+		_, publicKey := generateTestKeyPair(t)
+		issuer := newJWKSServer(t, publicKey, "test-kid-1").URL + "/"
+		t.Setenv("AUTH_ISSUERS", fmt.Sprintf(`[{"issuer":%q,"audiences":["x"]},{"issuer":%q,"audiences":["y"]}]`, issuer, issuer))
+		if _, err := NewValidator(context.Background()); err == nil || !contains(err.Error(), "重複") {
+			t.Errorf("NewValidator() エラー = %v, 期待値に含まれるべき文字列 = 重複", err)
+		}
+	})
+}
+
+func TestValidateToken_MultipleIssuers(t *testing.T) {
+	keyA, pubA := generateTestKeyPair(t)
+	keyB, pubB := generateTestKeyPair(t)
+	serverA := newJWKSServer(t, pubA, "test-kid-1")
+	serverB := newJWKSServer(t, pubB, "test-kid-1")
+
+	// Okta や Keycloak のように末尾に / のない issuer はそのまま iss と比較する
+	issuerA := serverA.URL
+	issuerB := serverB.URL + "/"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	validator, err := NewValidatorFromConfig(ctx, ValidatorConfig{
+		Issuers: []IssuerConfig{
+			{Issuer: issuerA, Audiences: []string{"https://api.example.com"}},
+			{Issuer: issuerB, Audiences: []string{"machine-api", "https://api.example.com"}, Routes: []string{"/api/customers/balance"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewValidatorFromConfig() の初期化に失敗しました: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		wantError string
+	}{
+		{
+			name:  "正常系: issuer A のトークン",
+			token: generateTestToken(t, keyA, issuerA, "https://api.example.com", time.Hour),
+		},
+		{
+			name:  "正常系: issuer B のトークン (2 つ目の audience)",
+			token: generateTestToken(t, keyB, issuerB, "machine-api", time.Hour),
+		},
+		{
+			name:      "エラー: issuer B の audience を issuer A で使用",
+			token:     generateTestToken(t, keyA, issuerA, "machine-api", time.Hour),
+			wantError: "audienceが不正です",
+		},
+		{
+			name:      "エラー: issuer A の鍵で署名し issuer B を名乗る",
+			token:     generateTestToken(t, keyA, issuerB, "machine-api", time.Hour),
+			wantError: "トークンのパースに失敗しました",
+		},
+		{
+			name:      "エラー: 設定と末尾の / が異なる issuer",
+			token:     generateTestToken(t, keyA, issuerA+"/", "https://api.example.com", time.Hour),
+			wantError: "issuerが不正です",
+		},
+		{
+			name:      "エラー: 未登録の issuer",
+			token:     generateTestToken(t, keyA, "https://unknown.example.com/", "https://api.example.com", time.Hour),
+			wantError: "issuerが不正です",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := validator.ValidateToken(tt.token)
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("ValidateToken() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ValidateToken() エラー = %v, 期待値 = nil", err)
+				return
+			}
+			if claims["sub"] != "test-user-123" {
+				t.Errorf("ValidateToken() sub = %v, 期待値 = test-user-123", claims["sub"])
+			}
+		})
+	}
+
+	routeTests := []struct {
+		issuer string
+		path   string
+		want   bool
+	}{
+		{issuerA, "/api/customers/account", true},
+		{issuerB, "/api/customers/balance", true},
+		{issuerB, "/api/customers/balance/history", true},
+		{issuerB, "/api/customers/balances", false},
+		{issuerB, "/api/customers/account", false},
+		{"https://unknown.example.com/", "/api/customers/account", true},
+	}
+	for _, tt := range routeTests {
+		if got := validator.AllowsRoute(tt.issuer, tt.path); got != tt.want {
+			t.Errorf("AllowsRoute(%q, %q) = %v, 期待値 = %v", tt.issuer, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidateToken_RegisteredClaims(t *testing.T) {
+	privateKey, publicKey := generateTestKeyPair(t)
+	server := newJWKSServer(t, publicKey, "test-kid-1")
+	issuer := server.URL + "/"
+	audience := "https://api.example.com"
+
+	newValidator := func(opts ValidationOptions) *Validator {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		v, err := NewValidatorFromConfig(ctx, ValidatorConfig{
+			Issuers: []IssuerConfig{{Issuer: issuer, Audiences: []string{audience}}},
+			Options: opts,
+		})
+		if err != nil {
+			t.Fatalf("NewValidatorFromConfig() の初期化に失敗しました: %v", err)
+		}
+		return v
+	}
+
+	// 基本の claim に overrides を上書きする (値が nil の claim は削除する)
+	claimsWith := func(overrides jwt.MapClaims) jwt.MapClaims {
+		now := time.Now()
+		claims := jwt.MapClaims{
+			"iss": issuer,
+			"aud": audience,
+			"sub": "test-user-123",
+			"iat": now.Unix(),
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		opts      ValidationOptions
+		method    jwt.SigningMethod
+		claims    jwt.MapClaims
+		header    map[string]interface{}
+		wantError string
+	}{
+		{
+			name:   "正常系: 既定では RS256 を受け付ける",
+			method: jwt.SigningMethodRS256,
+			claims: claimsWith(nil),
+		},
+		{
+			name:      "エラー: 既定の許可リストに RS512 は含まれない",
+			method:    jwt.SigningMethodRS512,
+			claims:    claimsWith(nil),
+			wantError: "signing method RS512 is invalid",
+		},
+		{
+			name:   "正常系: 許可リストに追加した RS512",
+			opts:   ValidationOptions{Algorithms: []string{"RS256", "RS512"}},
+			method: jwt.SigningMethodRS512,
+			claims: claimsWith(nil),
+		},
+		{
+			name:   "正常系: leeway 内の期限切れ",
+			opts:   ValidationOptions{Leeway: 30 * time.Second},
+			method: jwt.SigningMethodRS256,
+			claims: claimsWith(jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()}),
+		},
+		{
+			name:      "エラー: nbf が未来",
+			method:    jwt.SigningMethodRS256,
+			claims:    claimsWith(jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()}),
+			wantError: "not valid yet",
+		},
+		{
+			name:   "正常系: leeway 内の nbf",
+			opts:   ValidationOptions{Leeway: 30 * time.Second},
+			method: jwt.SigningMethodRS256,
+			claims: claimsWith(jwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix()}),
+		},
+		{
+			name:      "エラー: iat が未来",
+			method:    jwt.SigningMethodRS256,
+			claims:    claimsWith(jwt.MapClaims{"iat": now.Add(time.Minute).Unix()}),
+			wantError: "used before issued",
+		},
+		{
+			name:      "エラー: 最大経過時間を超えている",
+			opts:      ValidationOptions{MaxTokenAge: time.Hour},
+			method:    jwt.SigningMethodRS256,
+			claims:    claimsWith(jwt.MapClaims{"iat": now.Add(-2 * time.Hour).Unix()}),
+			wantError: "最大経過時間",
+		},
+		{
+			name:      "エラー: 最大経過時間の検証に iat がない",
+			opts:      ValidationOptions{MaxTokenAge: time.Hour},
+			method:    jwt.SigningMethodRS256,
+			claims:    claimsWith(jwt.MapClaims{"iat": nil}),
+			wantError: "iat claimが存在しない",
+		},
+		{
+			name:      "エラー: 必須 claim がない",
+			opts:      ValidationOptions{RequiredClaims: []string{"sub", "azp"}},
+			method:    jwt.SigningMethodRS256,
+			claims:    claimsWith(nil),
+			wantError: "必須claimが存在しません: azp",
+		},
+		{
+			name:      "エラー: 必須の exp がない",
+			opts:      ValidationOptions{RequiredClaims: []string{"exp"}},
+			method:    jwt.SigningMethodRS256,
+			claims:    claimsWith(jwt.MapClaims{"exp": nil}),
+			wantError: "exp claim is required",
+		},
+		{
+			name:   "正常系: typ が at+jwt",
+			opts:   ValidationOptions{ExpectedType: "at+jwt"},
+			method: jwt.SigningMethodRS256,
+			claims: claimsWith(nil),
+			header: map[string]interface{}{"typ": "at+jwt"},
+		},
+		{
+			name:   "正常系: typ が application/at+jwt",
+			opts:   ValidationOptions{ExpectedType: "at+jwt"},
+			method: jwt.SigningMethodRS256,
+			claims: claimsWith(nil),
+			header: map[string]interface{}{"typ": "application/at+jwt"},
+		},
+		{
+			name:      "エラー: typ が JWT",
+			opts:      ValidationOptions{ExpectedType: "at+jwt"},
+			method:    jwt.SigningMethodRS256,
+			claims:    claimsWith(nil),
+			wantError: "typ ヘッダーが不正です",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := newValidator(tt.opts)
+			tokenString := generateTestTokenWithClaims(t, privateKey, tt.method, tt.claims, tt.header)
+
+			claims, err := validator.ValidateToken(tokenString)
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("ValidateToken() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				if claims != nil {
+					t.Errorf("ValidateToken() claims = %v, 期待値 = nil", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ValidateToken() エラー = %v, 期待値 = nil", err)
+			}
+		})
+	}
+}
+
 // ヘルパー関数: 文字列が含まれているかチェック
+func TestValidateToken_Cache(t *testing.T) {
+	privateKey, publicKey := generateTestKeyPair(t)
+	_, rotatedPublicKey := generateTestKeyPair(t)
+
+	// 同じ kid のまま鍵を差し替えられる JWKS サーバー
+	var jwks atomic.Value
+	initial, _ := generateJWKSResponse(publicKey, "test-kid-1")
+	jwks.Store(initial)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jwks.Load().([]byte))
+	}))
+	t.Cleanup(server.Close)
+
+	issuer := server.URL + "/"
+	audience := "https://api.example.com"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	validator, err := NewValidatorFromConfig(ctx, ValidatorConfig{
+		Issuers: []IssuerConfig{{Issuer: issuer, Audiences: []string{audience}}},
+		Options: ValidationOptions{CacheSize: 10, CacheMaxTTL: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("NewValidatorFromConfig() の初期化に失敗しました: %v", err)
+	}
+	token := generateTestToken(t, privateKey, issuer, audience, time.Hour)
+
+	claims, err := validator.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken() エラー = %v, 期待値 = nil", err)
+	}
+	if validator.cache.Len() != 1 {
+		t.Fatalf("キャッシュ件数 = %d, 期待値 = 1", validator.cache.Len())
+	}
+
+	t.Run("正常系: 返した claim を変更してもキャッシュに影響しない", func(t *testing.T) {
+		claims["sub"] = "tampered"
+		cached, err := validator.ValidateToken(token)
+		if err != nil || cached["sub"] != "test-user-123" {
+			t.Errorf("ValidateToken() = %v, %v, 期待値 sub = test-user-123", cached, err)
+		}
+	})
+
+	t.Run("正常系: CacheMaxTTL を過ぎたエントリは使わない", func(t *testing.T) {
+		validator.cache.now = func() time.Time { return time.Now().Add(time.Minute) }
+		defer func() { validator.cache.now = time.Now }()
+		if _, ok := validator.cache.Get(tokenCacheKey(token)); ok {
+			t.Errorf("CacheMaxTTL を過ぎたエントリが返されました")
+		}
+	})
+
+	t.Run("エラー: JWKS のローテーションで署名鍵が変わった", func(t *testing.T) {
+		if _, err := validator.ValidateToken(token); err != nil {
+			t.Fatalf("ValidateToken() エラー = %v, 期待値 = nil", err)
+		}
+		rotated, _ := generateJWKSResponse(rotatedPublicKey, "test-kid-1")
+		jwks.Store(rotated)
+		// 保持している鍵を削除し、次の参照で JWKS を取得し直させる
+		if _, err := validator.issuers[issuer].keyfunc.Storage().KeyDelete(ctx, "test-kid-1"); err != nil {
+			t.Fatalf("KeyDelete() エラー = %v", err)
+		}
+
+		if _, err := validator.ValidateToken(token); err == nil {
+			t.Errorf("ValidateToken() ローテーション後のエラー = nil, キャッシュを使わずに再検証するべき")
+		}
+		if validator.cache.Len() != 0 {
+			t.Errorf("キャッシュ件数 = %d, 期待値 = 0", validator.cache.Len())
+		}
+	})
+}
+
+// キャッシュの有無による ValidateToken のレイテンシの比較
+func BenchmarkValidateToken(b *testing.B) {
+	privateKey, publicKey := generateTestKeyPair(b)
+	server := newJWKSServer(b, publicKey, "test-kid-1")
+	issuer := server.URL + "/"
+	audience := "https://api.example.com"
+	token := generateTestToken(b, privateKey, issuer, audience, time.Hour)
+
+	for _, bm := range []struct {
+		name      string
+		cacheSize int
+	}{
+		{name: "キャッシュなし", cacheSize: 0},
+		{name: "キャッシュあり", cacheSize: 1000},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			validator, err := NewValidatorFromConfig(context.Background(), ValidatorConfig{
+				Issuers: []IssuerConfig{{Issuer: issuer, Audiences: []string{audience}}},
+				Options: ValidationOptions{CacheSize: bm.cacheSize},
+			})
+			if err != nil {
+				b.Fatalf("NewValidatorFromConfig() の初期化に失敗しました: %v", err)
+			}
+			b.Cleanup(validator.Close)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := validator.ValidateToken(token); err != nil {
+					b.Fatalf("ValidateToken() エラー = %v", err)
+				}
+			}
+		})
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 || findSubstring(s, substr))
 }
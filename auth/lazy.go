@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aki80204/go-gateway/metrics"
+)
+
+const (
+	defaultLazyInitTimeout = 5 * time.Second
+	defaultMinInitBackoff  = time.Second
+	defaultMaxInitBackoff  = time.Minute
+)
+
+// ErrValidatorUnavailable はトークンの検証方式の初期化に失敗しており、トークンを検証できないことを表す
+var ErrValidatorUnavailable = errors.New("トークンの検証方式を初期化できていません")
+
+// LazyValidator は初期化に失敗した TokenValidator をリクエスト時に再試行する。
+// IdP の一時的な障害でコールドスタート時の初期化に失敗しても、コンテナが回復できなくなることを防ぐ。
+// 再試行の間隔は失敗するたびに 2 倍にし (最大 1 分)、その間のリクエストには ErrValidatorUnavailable を返す。
+type LazyValidator struct {
+	mu          sync.Mutex
+	init        func(ctx context.Context) (TokenValidator, error)
+	validator   TokenValidator
+	lastErr     error
+	backoff     time.Duration
+	nextAttempt time.Time
+	now         func() time.Time
+}
+
+// NewLazyValidator は init で TokenValidator を初期化する LazyValidator を生成する。init はまだ呼び出さない
+func NewLazyValidator(init func(ctx context.Context) (TokenValidator, error)) *LazyValidator {
+	return &LazyValidator{init: init, now: time.Now}
+}
+
+// Init は初期化を試み、失敗した場合はそのエラーを返す。初期化済みの場合や再試行の待ち時間中は何もしない
+func (v *LazyValidator) Init(ctx context.Context) error {
+	_, err := v.get(ctx)
+	return err
+}
+
+func (v *LazyValidator) get(ctx context.Context) (TokenValidator, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.validator != nil {
+		return v.validator, nil
+	}
+	if v.now().Before(v.nextAttempt) {
+		return nil, fmt.Errorf("%w: %w", ErrValidatorUnavailable, v.lastErr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultLazyInitTimeout)
+	defer cancel()
+	validator, err := v.init(ctx)
+	if err != nil {
+		if v.backoff == 0 {
+			v.backoff = defaultMinInitBackoff
+		} else {
+			v.backoff = min(v.backoff*2, defaultMaxInitBackoff)
+		}
+		v.lastErr = err
+		v.nextAttempt = v.now().Add(v.backoff)
+		log.Printf("トークンの検証方式の初期化に失敗しました。%s 後に再試行します: %v", v.backoff, err)
+		metrics.Emit("ValidatorInitFailure", 1, metrics.UnitCount, nil)
+		return nil, fmt.Errorf("%w: %w", ErrValidatorUnavailable, err)
+	}
+
+	if v.lastErr != nil {
+		log.Printf("トークンの検証方式の初期化に成功しました")
+	}
+	v.validator = validator
+	v.lastErr = nil
+	metrics.Emit("ValidatorInitSuccess", 1, metrics.UnitCount, nil)
+	return validator, nil
+}
+
+func (v *LazyValidator) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	validator, err := v.get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return validator.ValidateToken(tokenString)
+}
+
+// AllowsRoute は初期化済みの TokenValidator が RouteRestrictor を実装していればその判定を返す
+func (v *LazyValidator) AllowsRoute(issuer, path string) bool {
+	v.mu.Lock()
+	validator := v.validator
+	v.mu.Unlock()
+
+	if rr, ok := validator.(RouteRestrictor); ok {
+		return rr.AllowsRoute(issuer, path)
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLazyValidator_RetriesWithBackoff(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	attempts := 0
+	v := NewLazyValidator(func(context.Context) (TokenValidator, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("JWKS の取得に失敗しました")
+		}
+		return &fakeValidator{tokens: map[string]jwt.MapClaims{"token": {"sub": "user-1"}}}, nil
+	})
+	v.now = func() time.Time { return now }
+
+	steps := []struct {
+		name         string
+		advance      time.Duration
+		wantAttempts int
+		wantOK       bool
+	}{
+		{name: "1 回目の初期化に失敗", wantAttempts: 1},
+		{name: "待ち時間中は再試行しない", advance: 500 * time.Millisecond, wantAttempts: 1},
+		{name: "1 秒後に再試行して失敗", advance: 500 * time.Millisecond, wantAttempts: 2},
+		{name: "待ち時間は 2 秒に延びる", advance: time.Second, wantAttempts: 2},
+		{name: "2 秒後に再試行して成功", advance: time.Second, wantAttempts: 3, wantOK: true},
+		{name: "初期化済みなら再試行しない", advance: time.Hour, wantAttempts: 3, wantOK: true},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		claims, err := v.ValidateToken("token")
+		if attempts != step.wantAttempts {
+			t.Errorf("%s: 初期化の試行回数 = %d, 期待値 = %d", step.name, attempts, step.wantAttempts)
+		}
+		if step.wantOK {
+			if err != nil || claims["sub"] != "user-1" {
+				t.Errorf("%s: ValidateToken() = %v, %v, 期待値 sub = user-1", step.name, claims, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrValidatorUnavailable) {
+			t.Errorf("%s: ValidateToken() エラー = %v, 期待値 = ErrValidatorUnavailable", step.name, err)
+		}
+	}
+}
+
+func TestLazyValidator_AllowsRoute(t *testing.T) {
+	v := NewLazyValidator(func(context.Context) (TokenValidator, error) {
+		return &restrictedValidator{allowed: map[string]bool{"https://idp/ /api/a": true}}, nil
+	})
+	if err := v.Init(context.Background()); err != nil {
+		t.Fatalf("Init() エラー = %v", err)
+	}
+	if !v.AllowsRoute("https://idp/", "/api/a") || v.AllowsRoute("https://idp/", "/api/b") {
+		t.Errorf("AllowsRoute() は初期化済みの TokenValidator の判定を使うべき")
+	}
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 証明書の識別ルールで参照できる項目
+const (
+	CertFieldSubjectDN = "subject_dn"
+	CertFieldSubjectCN = "subject_cn"
+	CertFieldIssuerDN  = "issuer_dn"
+	CertFieldSerial    = "serial"
+	CertFieldSANDNS    = "san_dns"
+	CertFieldSANURI    = "san_uri"
+	CertFieldSANEmail  = "san_email"
+)
+
+// CertIdentityRule はクライアント証明書から呼び出し元の識別子を取り出すルール
+type CertIdentityRule struct {
+	// Field は照合する証明書の項目 (subject_dn, subject_cn, issuer_dn, serial, san_dns, san_uri, san_email)
+	Field string `json:"field"`
+	// Pattern は項目の値と照合する正規表現。省略した場合は任意の値に一致する
+	Pattern string `json:"pattern"`
+	// Identity は一致した場合の sub。$1 や ${name} で Pattern のキャプチャを参照できる。省略した場合は項目の値そのもの
+	Identity string `json:"identity"`
+}
+
+// MTLSConfig は mTLS のクライアント証明書による認証の設定
+type MTLSConfig struct {
+	// Rules は先頭から順に照合し、最初に一致したルールで識別子を決める (既定: subject_cn をそのまま使う)
+	Rules []CertIdentityRule `json:"rules"`
+}
+
+// ClientCertificate は mTLS で提示されたクライアント証明書の情報
+type ClientCertificate struct {
+	// Identity は識別ルールで取り出した識別子
+	Identity     string
+	SubjectDN    string
+	IssuerDN     string
+	SerialNumber string
+	NotBefore    time.Time
+	NotAfter     time.Time
+	Certificate  *x509.Certificate
+}
+
+type certIdentityRule struct {
+	CertIdentityRule
+	pattern *regexp.Regexp
+}
+
+// MTLSAuthenticator は TLS で提示されたクライアント証明書で認証する。
+// Lambda では API Gateway が検証した証明書 (requestContext.authentication.clientCert) をアダプターが設定する。
+// serve モードでは、サーバーが TLS のハンドシェイクで検証した証明書を使う。
+// 証明書チェーンの検証は API Gateway のトラストストアや TLS の終端で行われる前提で、ここでは有効期間と識別ルールを確認する。
+type MTLSAuthenticator struct {
+	rules []certIdentityRule
+	now   func() time.Time
+}
+
+// NewMTLSAuthenticator は識別ルールを検証して MTLSAuthenticator を生成する
+func NewMTLSAuthenticator(cfg MTLSConfig) (*MTLSAuthenticator, error) {
+	rules := cfg.Rules
+	if len(rules) == 0 {
+		rules = []CertIdentityRule{{Field: CertFieldSubjectCN}}
+	}
+
+	a := &MTLSAuthenticator{now: time.Now}
+	for i, r := range rules {
+		switch r.Field {
+		case CertFieldSubjectDN, CertFieldSubjectCN, CertFieldIssuerDN, CertFieldSerial,
+			CertFieldSANDNS, CertFieldSANURI, CertFieldSANEmail:
+		default:
+			return nil, fmt.Errorf("証明書の識別ルールの field が不正です (rules[%d]): %q", i, r.Field)
+		}
+		compiled := certIdentityRule{CertIdentityRule: r}
+		if r.Pattern != "" {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("証明書の識別ルールの pattern が不正です (rules[%d]): %w", i, err)
+			}
+			compiled.pattern = re
+		}
+		a.rules = append(a.rules, compiled)
+	}
+	return a, nil
+}
+
+// NewMTLSAuthenticatorFromEnv は MTLS_IDENTITY_RULES (CertIdentityRule の JSON 配列) から MTLSAuthenticator を生成する
+func NewMTLSAuthenticatorFromEnv() (*MTLSAuthenticator, error) {
+	var cfg MTLSConfig
+	if raw := os.Getenv("MTLS_IDENTITY_RULES"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.Rules); err != nil {
+			return nil, fmt.Errorf("MTLS_IDENTITY_RULES の形式が不正です: %w", err)
+		}
+	}
+	return NewMTLSAuthenticator(cfg)
+}
+
+func (a *MTLSAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	x := peerCertificate(r)
+	if x == nil {
+		return nil, ErrNoCredentials
+	}
+	cert := &ClientCertificate{
+		SubjectDN:    x.Subject.String(),
+		IssuerDN:     x.Issuer.String(),
+		SerialNumber: x.SerialNumber.String(),
+		NotBefore:    x.NotBefore,
+		NotAfter:     x.NotAfter,
+		Certificate:  x,
+	}
+
+	now := a.now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, errors.New("クライアント証明書の有効期間外です")
+	}
+
+	identity, ok := a.identify(cert)
+	if !ok {
+		return nil, errors.New("クライアント証明書に一致する識別ルールがありません")
+	}
+	cert.Identity = identity
+
+	return &Principal{
+		Subject:    identity,
+		Claims:     jwt.MapClaims{"sub": identity},
+		ClientCert: cert,
+	}, nil
+}
+
+// 先頭のルールから順に照合し、最初に一致したルールの識別子を返す
+func (a *MTLSAuthenticator) identify(cert *ClientCertificate) (string, bool) {
+	for _, r := range a.rules {
+		for _, value := range certFieldValues(cert, r.Field) {
+			if value == "" {
+				continue
+			}
+			if r.pattern == nil {
+				if r.Identity != "" {
+					return r.Identity, true
+				}
+				return value, true
+			}
+			match := r.pattern.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			if r.Identity == "" {
+				return value, true
+			}
+			return string(r.pattern.ExpandString(nil, r.Identity, value, match)), true
+		}
+	}
+	return "", false
+}
+
+func certFieldValues(cert *ClientCertificate, field string) []string {
+	switch field {
+	case CertFieldSubjectDN:
+		return []string{cert.SubjectDN}
+	case CertFieldIssuerDN:
+		return []string{cert.IssuerDN}
+	case CertFieldSerial:
+		return []string{cert.SerialNumber}
+	case CertFieldSubjectCN:
+		return []string{cert.Certificate.Subject.CommonName}
+	case CertFieldSANDNS:
+		return cert.Certificate.DNSNames
+	case CertFieldSANEmail:
+		return cert.Certificate.EmailAddresses
+	case CertFieldSANURI:
+		uris := make([]string, 0, len(cert.Certificate.URIs))
+		for _, u := range cert.Certificate.URIs {
+			uris = append(uris, u.String())
+		}
+		return uris
+	}
+	return nil
+}
+
+// peerCertificate は TLS で提示されたクライアント証明書を返す。提示されていない場合は nil
+func peerCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.TLS.PeerCertificates[0]
+}
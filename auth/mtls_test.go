@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// 自己署名のクライアント証明書を PEM で生成する
+func generateClientCertPEM(t *testing.T, template *x509.Certificate) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("鍵の生成に失敗しました: %v", err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("証明書の生成に失敗しました: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// certPEM のクライアント証明書を TLS で提示したリクエストを生成する。certPEM が空の場合は証明書を提示しない
+func makeMTLSRequest(t *testing.T, certPEM string) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "https://api.example.com/api/customers/account", nil)
+	if certPEM == "" {
+		return r
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("証明書の解析に失敗しました: %v", err)
+	}
+	r.TLS.PeerCertificates = []*x509.Certificate{cert}
+	return r
+}
+
+func TestMTLSAuthenticator_Authenticate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	spiffe, _ := url.Parse("spiffe://example.com/ns/payments/sa/batch")
+	certPEM := generateClientCertPEM(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4096),
+		Subject:      pkix.Name{CommonName: "batch.payments.internal", Organization: []string{"Example"}},
+		DNSNames:     []string{"batch.payments.internal"},
+		URIs:         []*url.URL{spiffe},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+	})
+	expiredPEM := generateClientCertPEM(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "expired"},
+		NotBefore:    now.Add(-48 * time.Hour),
+		NotAfter:     now.Add(-24 * time.Hour),
+	})
+
+	tests := []struct {
+		name      string
+		rules     []CertIdentityRule
+		certPEM   string
+		wantSub   string
+		wantError string
+	}{
+		{
+			name:    "正常系: 既定のルールは CN",
+			certPEM: certPEM,
+			wantSub: "batch.payments.internal",
+		},
+		{
+			name:    "正常系: SAN URI のキャプチャで識別子を組み立てる",
+			rules:   []CertIdentityRule{{Field: CertFieldSANURI, Pattern: `^spiffe://example\.com/ns/(\w+)/sa/(\w+)$`, Identity: "$1:$2"}},
+			certPEM: certPEM,
+			wantSub: "payments:batch",
+		},
+		{
+			name: "正常系: 一致しないルールは次のルールに進む",
+			rules: []CertIdentityRule{
+				{Field: CertFieldSANDNS, Pattern: `\.partners\.example\.com$`, Identity: "partner"},
+				{Field: CertFieldSerial, Pattern: `^4096$`, Identity: "serial-4096"},
+			},
+			certPEM: certPEM,
+			wantSub: "serial-4096",
+		},
+		{
+			name:    "正常系: subject_dn の照合",
+			rules:   []CertIdentityRule{{Field: CertFieldSubjectDN, Pattern: `^CN=batch\.payments\.internal,O=Example$`, Identity: "batch"}},
+			certPEM: certPEM,
+			wantSub: "batch",
+		},
+		{
+			name:      "エラー: 有効期間外",
+			certPEM:   expiredPEM,
+			wantError: "有効期間外",
+		},
+		{
+			name:      "エラー: 一致するルールがない",
+			rules:     []CertIdentityRule{{Field: CertFieldSubjectDN, Pattern: `O=Partner`}},
+			certPEM:   certPEM,
+			wantError: "識別ルール",
+		},
+		{
+			name:      "エラー: クライアント証明書なし",
+			wantError: ErrNoCredentials.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewMTLSAuthenticator(MTLSConfig{Rules: tt.rules})
+			if err != nil {
+				t.Fatalf("NewMTLSAuthenticator() エラー = %v", err)
+			}
+			a.now = func() time.Time { return now }
+
+			p, err := a.Authenticate(makeMTLSRequest(t, tt.certPEM))
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("Authenticate() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() エラー = %v", err)
+			}
+			if p.Subject != tt.wantSub || p.ClientCert == nil || p.ClientCert.Identity != tt.wantSub {
+				t.Errorf("Authenticate() = %+v, 期待値 sub = %v", p, tt.wantSub)
+			}
+		})
+	}
+}
+
+func TestNewMTLSAuthenticator_InvalidRule(t *testing.T) {
+	if _, err := NewMTLSAuthenticator(MTLSConfig{Rules: []CertIdentityRule{{Field: "subject"}}}); err == nil {
+		t.Errorf("NewMTLSAuthenticator() 未知の field で error = nil")
+	}
+	if _, err := NewMTLSAuthenticator(MTLSConfig{Rules: []CertIdentityRule{{Field: CertFieldSubjectCN, Pattern: "("}}}); err == nil {
+		t.Errorf("NewMTLSAuthenticator() 不正な pattern で error = nil")
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 署名アルゴリズムの既定の許可リスト (Auth0 の既定値)
+var defaultAlgorithms = []string{"RS256"}
+
+const (
+	defaultTokenCacheSize = 1000
+	defaultCacheMaxTTL    = 5 * time.Minute
+)
+
+// ValidationOptions は JWT の登録済み claim とヘッダーの検証方法を指定する
+type ValidationOptions struct {
+	// Algorithms は受け付ける署名アルゴリズム。空の場合は RS256 のみ
+	Algorithms []string
+	// Leeway は exp / nbf / iat の検証で許容する時計のずれ
+	Leeway time.Duration
+	// MaxTokenAge が 0 より大きい場合、iat からの経過時間がこれを超えるトークンを拒否する
+	MaxTokenAge time.Duration
+	// RequiredClaims に列挙した claim が存在しないトークンを拒否する
+	RequiredClaims []string
+	// ExpectedType を指定した場合、JWT ヘッダーの typ を照合する (例: RFC 9068 の "at+jwt")
+	ExpectedType string
+	// CacheSize は検証済みトークンのキャッシュ件数。0 の場合はキャッシュしない
+	CacheSize int
+	// CacheMaxTTL は検証済みトークンをキャッシュする最大時間。0 の場合は 5 分
+	CacheMaxTTL time.Duration
+}
+
+// 環境変数から検証オプションを読み込む
+//
+//   - JWT_ALGORITHMS       例: "RS256,ES256"
+//   - JWT_LEEWAY           例: "30s"
+//   - JWT_MAX_TOKEN_AGE    例: "24h"
+//   - JWT_REQUIRED_CLAIMS  例: "sub,exp,iat"
+//   - JWT_EXPECTED_TYP     例: "at+jwt"
+//   - JWT_CACHE_SIZE       検証済みトークンのキャッシュ件数 (既定: 1000、0 で無効)
+//   - JWT_CACHE_MAX_TTL    例: "1m" (既定: 5m)
+func validationOptionsFromEnv() (ValidationOptions, error) {
+	opts := ValidationOptions{
+		Algorithms:     splitList(os.Getenv("JWT_ALGORITHMS")),
+		RequiredClaims: splitList(os.Getenv("JWT_REQUIRED_CLAIMS")),
+		ExpectedType:   strings.TrimSpace(os.Getenv("JWT_EXPECTED_TYP")),
+		CacheSize:      defaultTokenCacheSize,
+	}
+	if raw := os.Getenv("JWT_CACHE_SIZE"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 0 {
+			return ValidationOptions{}, fmt.Errorf("JWT_CACHE_SIZE の形式が不正です: %q", raw)
+		}
+		opts.CacheSize = size
+	}
+
+	var err error
+	if opts.Leeway, err = durationFromEnv("JWT_LEEWAY"); err != nil {
+		return ValidationOptions{}, err
+	}
+	if opts.MaxTokenAge, err = durationFromEnv("JWT_MAX_TOKEN_AGE"); err != nil {
+		return ValidationOptions{}, err
+	}
+	if opts.CacheMaxTTL, err = durationFromEnv("JWT_CACHE_MAX_TTL"); err != nil {
+		return ValidationOptions{}, err
+	}
+	return opts, nil
+}
+
+func durationFromEnv(key string) (time.Duration, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s の形式が不正です: %q", key, raw)
+	}
+	return d, nil
+}
+
+// カンマ区切りの値を空要素を除いて分割する
+func splitList(raw string) []string {
+	var values []string
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// typ ヘッダーを比較用に正規化する。RFC 7515 により "application/" は省略できる
+func normalizeType(typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	return strings.TrimPrefix(typ, "application/")
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidationOptionsFromEnv(t *testing.T) {
+	t.Run("正常系: 全項目を設定", func(t *testing.T) {
+		t.Setenv("JWT_ALGORITHMS", "RS256, ES256,")
+		t.Setenv("JWT_LEEWAY", "30s")
+		t.Setenv("JWT_MAX_TOKEN_AGE", "24h")
+		t.Setenv("JWT_REQUIRED_CLAIMS", "sub,exp")
+		t.Setenv("JWT_EXPECTED_TYP", "at+jwt")
+		t.Setenv("JWT_CACHE_SIZE", "500")
+		t.Setenv("JWT_CACHE_MAX_TTL", "1m")
+
+		opts, err := validationOptionsFromEnv()
+		if err != nil {
+			t.Fatalf("validationOptionsFromEnv() エラー = %v, 期待値 = nil", err)
+		}
+		want := ValidationOptions{
+			Algorithms:     []string{"RS256", "ES256"},
+			Leeway:         30 * time.Second,
+			MaxTokenAge:    24 * time.Hour,
+			RequiredClaims: []string{"sub", "exp"},
+			ExpectedType:   "at+jwt",
+			CacheSize:      500,
+			CacheMaxTTL:    time.Minute,
+		}
+		if !reflect.DeepEqual(opts, want) {
+			t.Errorf("validationOptionsFromEnv() = %+v, 期待値 = %+v", opts, want)
+		}
+	})
+
+	t.Run("正常系: キャッシュ件数の既定値", func(t *testing.T) {
+		opts, err := validationOptionsFromEnv()
+		if err != nil {
+			t.Fatalf("validationOptionsFromEnv() エラー = %v, 期待値 = nil", err)
+		}
+		if opts.CacheSize != 1000 {
+			t.Errorf("CacheSize = %d, 期待値 = 1000", opts.CacheSize)
+		}
+	})
+
+	t.Run("エラー: キャッシュ件数が負の値", func(t *testing.T) {
+		t.Setenv("JWT_CACHE_SIZE", "-1")
+		if _, err := validationOptionsFromEnv(); err == nil {
+			t.Errorf("validationOptionsFromEnv() エラーが期待されましたが、nil が返されました")
+		}
+	})
+
+	t.Run("エラー: leeway の形式が不正", func(t *testing.T) {
+		t.Setenv("JWT_LEEWAY", "30")
+		if _, err := validationOptionsFromEnv(); err == nil {
+			t.Errorf("validationOptionsFromEnv() エラーが期待されましたが、nil が返されました")
+		}
+	})
+}
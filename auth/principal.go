@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Principal は認証済みの呼び出し元を表す
+type Principal struct {
+	Subject     string
+	Issuer      string
+	Audience    []string
+	Scopes      []string
+	Permissions []string
+	ClientID    string
+	ExpiresAt   time.Time
+	// Claims は検証済みの claim 全体
+	Claims jwt.MapClaims
+	// ClientCert は mTLS で認証した場合のクライアント証明書
+	ClientCert *ClientCertificate
+}
+
+// NewPrincipal は検証済みの claim から Principal を生成する。
+// 型が想定外の claim は panic させずにエラーまたは空値として扱う。
+func NewPrincipal(claims jwt.MapClaims) (*Principal, error) {
+	if claims == nil {
+		return nil, errors.New("claim が存在しません")
+	}
+
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return nil, errors.New("sub claimが存在しないか文字列ではありません")
+	}
+
+	p := &Principal{
+		Subject:     sub,
+		Audience:    stringList(claims["aud"]),
+		Permissions: stringList(claims["permissions"]),
+		Claims:      claims,
+	}
+
+	if v, ok := claims["iss"]; ok {
+		if p.Issuer, ok = v.(string); !ok {
+			return nil, errors.New("iss claimが文字列ではありません")
+		}
+	}
+
+	// OAuth 2.0 の scope はスペース区切りの文字列。一部の IdP は scp に配列で入れる
+	if scope, ok := claims["scope"].(string); ok {
+		p.Scopes = strings.Fields(scope)
+	} else {
+		p.Scopes = stringList(claims["scp"])
+	}
+
+	// Auth0 のアクセストークンは azp、RFC 9068 は client_id
+	if clientID, ok := claims["client_id"].(string); ok {
+		p.ClientID = clientID
+	} else if azp, ok := claims["azp"].(string); ok {
+		p.ClientID = azp
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return nil, fmt.Errorf("exp claimの取得に失敗しました: %w", err)
+	}
+	if exp != nil {
+		p.ExpiresAt = exp.Time
+	}
+
+	return p, nil
+}
+
+// HasScope は scope を保持しているかを返す
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// 文字列またはスペース区切りの文字列、文字列の配列を []string に変換する。文字列以外の要素は無視する
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestNewPrincipal(t *testing.T) {
+	exp := time.Unix(1893456000, 0)
+
+	tests := []struct {
+		name      string
+		claims    jwt.MapClaims
+		want      *Principal
+		wantError string
+	}{
+		{
+			name: "正常系: Auth0 のアクセストークン",
+			claims: jwt.MapClaims{
+				"sub":         "auth0|user-1",
+				"iss":         "https://tenant.auth0.com/",
+				"aud":         []interface{}{"https://api.example.com", "https://tenant.auth0.com/userinfo"},
+				"scope":       "openid read:balance",
+				"permissions": []interface{}{"read:balance", 42},
+				"azp":         "client-abc",
+				"exp":         float64(exp.Unix()),
+			},
+			want: &Principal{
+				Subject:     "auth0|user-1",
+				Issuer:      "https://tenant.auth0.com/",
+				Audience:    []string{"https://api.example.com", "https://tenant.auth0.com/userinfo"},
+				Scopes:      []string{"openid", "read:balance"},
+				Permissions: []string{"read:balance"},
+				ClientID:    "client-abc",
+				ExpiresAt:   exp,
+			},
+		},
+		{
+			name: "正常系: scp 配列と client_id",
+			claims: jwt.MapClaims{
+				"sub":       "machine-1",
+				"aud":       "batch",
+				"scp":       []interface{}{"jobs:run"},
+				"client_id": "batch-client",
+				"azp":       "ignored",
+			},
+			want: &Principal{
+				Subject:  "machine-1",
+				Audience: []string{"batch"},
+				Scopes:   []string{"jobs:run"},
+				ClientID: "batch-client",
+			},
+		},
+		{
+			name:      "エラー: sub がない",
+			claims:    jwt.MapClaims{"iss": "https://tenant.auth0.com/"},
+			wantError: "sub claimが存在しないか文字列ではありません",
+		},
+		{
+			name:      "エラー: sub が数値",
+			claims:    jwt.MapClaims{"sub": float64(123)},
+			wantError: "sub claimが存在しないか文字列ではありません",
+		},
+		{
+			name:      "エラー: sub が空文字",
+			claims:    jwt.MapClaims{"sub": ""},
+			wantError: "sub claimが存在しないか文字列ではありません",
+		},
+		{
+			name:      "エラー: iss が文字列ではない",
+			claims:    jwt.MapClaims{"sub": "user-1", "iss": true},
+			wantError: "iss claimが文字列ではありません",
+		},
+		{
+			name:      "エラー: exp が数値ではない",
+			claims:    jwt.MapClaims{"sub": "user-1", "exp": "tomorrow"},
+			wantError: "exp claimの取得に失敗しました",
+		},
+		{
+			name:      "エラー: claim が nil",
+			claims:    nil,
+			wantError: "claim が存在しません",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPrincipal(tt.claims)
+			if tt.wantError != "" {
+				if err == nil || !contains(err.Error(), tt.wantError) {
+					t.Errorf("NewPrincipal() エラー = %v, 期待値に含まれるべき文字列 = %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewPrincipal() エラー = %v, 期待値 = nil", err)
+			}
+			tt.want.Claims = tt.claims
+			if !reflect.DeepEqual(p, tt.want) {
+				t.Errorf("NewPrincipal() = %+v, 期待値 = %+v", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrincipal_HasScope(t *testing.T) {
+	p := &Principal{Scopes: []string{"openid", "read:pii"}}
+	if !p.HasScope("read:pii") {
+		t.Errorf("HasScope(read:pii) = false, 期待値 = true")
+	}
+	if p.HasScope("read") {
+		t.Errorf("HasScope(read) = true, 期待値 = false")
+	}
+}
+
+func TestCheckAuth_NonStringSub(t *testing.T) {
+	privateKey, publicKey := generateTestKeyPair(t)
+	server := newJWKSServer(t, publicKey, "test-kid-1")
+	issuer := server.URL + "/"
+	audience := "https://api.example.com"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	validator, err := NewValidatorFromConfig(ctx, ValidatorConfig{
+		Issuers: []IssuerConfig{{Issuer: issuer, Audiences: []string{audience}}},
+	})
+	if err != nil {
+		t.Fatalf("NewValidatorFromConfig() の初期化に失敗しました: %v", err)
+	}
+
+	for name, sub := range map[string]interface{}{"数値": float64(42), "存在しない": nil} {
+		t.Run("エラー: sub が"+name, func(t *testing.T) {
+			claims := jwt.MapClaims{
+				"iss": issuer,
+				"aud": audience,
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}
+			if sub != nil {
+				claims["sub"] = sub
+			}
+			token := generateTestTokenWithClaims(t, privateKey, jwt.SigningMethodRS256, claims, nil)
+			request := newTestRequest(http.MethodGet, "/api/customers/account", map[string]string{"Authorization": "Bearer " + token})
+
+			p, err := CheckAuth(validator, request)
+			if err == nil || !contains(err.Error(), "sub claim") {
+				t.Errorf("CheckAuth() エラー = %v, 期待値に含まれるべき文字列 = sub claim", err)
+			}
+			if p != nil {
+				t.Errorf("CheckAuth() principal = %+v, 期待値 = nil", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const defaultRevocationRefreshInterval = time.Minute
+
+// ErrTokenRevoked は署名などの検証には成功したトークンが失効リストに含まれていることを表す
+var ErrTokenRevoked = errors.New("トークンは失効しています")
+
+// DenyListEntries は失効リストの内容
+//
+//	{"jti": ["token-id"], "sub": ["disabled-user"], "issued_before": {"user-1": "2026-10-01T00:00:00Z"}}
+type DenyListEntries struct {
+	// TokenIDs は失効させるトークンの jti
+	TokenIDs []string `json:"jti"`
+	// Subjects はすべてのトークンを失効させる sub
+	Subjects []string `json:"sub"`
+	// IssuedBefore は sub ごとに、この時刻より前に発行されたトークンを失効させる
+	IssuedBefore map[string]time.Time `json:"issued_before"`
+}
+
+// DenyListLoader は失効リストを外部から読み込む
+type DenyListLoader interface {
+	LoadDenyList(ctx context.Context) (*DenyListEntries, error)
+}
+
+// FileDenyListLoader は JSON ファイルから失効リストを読み込む
+type FileDenyListLoader struct {
+	Path string
+}
+
+func (l *FileDenyListLoader) LoadDenyList(_ context.Context) (*DenyListEntries, error) {
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		return nil, fmt.Errorf("失効リストの読み込みに失敗しました: %w", err)
+	}
+	return parseDenyList(data)
+}
+
+// S3ObjectGetter は S3 の GetObject に相当する最小限のインターフェース。
+// aws-sdk-go-v2 の s3.Client をラップし、オブジェクトの本文を返す実装を想定している。
+type S3ObjectGetter interface {
+	GetObject(ctx context.Context, bucket, key string) ([]byte, error)
+}
+
+// S3DenyListLoader は S3 のオブジェクトから失効リストを読み込む
+type S3DenyListLoader struct {
+	Client S3ObjectGetter
+	Bucket string
+	Key    string
+}
+
+func (l *S3DenyListLoader) LoadDenyList(ctx context.Context) (*DenyListEntries, error) {
+	data, err := l.Client.GetObject(ctx, l.Bucket, l.Key)
+	if err != nil {
+		return nil, fmt.Errorf("失効リストの取得に失敗しました (s3://%s/%s): %w", l.Bucket, l.Key, err)
+	}
+	return parseDenyList(data)
+}
+
+func parseDenyList(data []byte) (*DenyListEntries, error) {
+	var entries DenyListEntries
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("失効リストの形式が不正です: %w", err)
+	}
+	return &entries, nil
+}
+
+// DenyList はメモリ上の失効リスト。
+// loader を指定した場合は refreshInterval ごとに読み込み直し、失敗した場合は直前の内容を使い続ける。
+type DenyList struct {
+	mu              sync.RWMutex
+	tokenIDs        map[string]struct{}
+	subjects        map[string]struct{}
+	issuedBefore    map[string]time.Time
+	loader          DenyListLoader
+	refreshInterval time.Duration
+	loadedAt        time.Time
+	now             func() time.Time
+}
+
+// NewDenyList は空の失効リストを生成する
+func NewDenyList() *DenyList {
+	return &DenyList{
+		tokenIDs:     make(map[string]struct{}),
+		subjects:     make(map[string]struct{}),
+		issuedBefore: make(map[string]time.Time),
+		now:          time.Now,
+	}
+}
+
+// NewDenyListFromLoader は loader から失効リストを読み込む。refreshInterval が 0 の場合は 1 分ごとに読み込み直す
+func NewDenyListFromLoader(ctx context.Context, loader DenyListLoader, refreshInterval time.Duration) (*DenyList, error) {
+	if refreshInterval == 0 {
+		refreshInterval = defaultRevocationRefreshInterval
+	}
+	d := NewDenyList()
+	d.loader = loader
+	d.refreshInterval = refreshInterval
+
+	entries, err := loader.LoadDenyList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.Replace(entries)
+	return d, nil
+}
+
+// NewDenyListFromEnv は環境変数から失効リストを生成する。REVOCATION_LIST_FILE が未設定の場合は nil を返す。
+//   - REVOCATION_LIST_FILE         失効リストの JSON ファイルのパス
+//   - REVOCATION_REFRESH_INTERVAL  読み込み直す間隔 (既定: "1m")
+func NewDenyListFromEnv(ctx context.Context) (*DenyList, error) {
+	path := os.Getenv("REVOCATION_LIST_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	interval, err := durationFromEnv("REVOCATION_REFRESH_INTERVAL")
+	if err != nil {
+		return nil, err
+	}
+	return NewDenyListFromLoader(ctx, &FileDenyListLoader{Path: path}, interval)
+}
+
+// Replace は失効リストの内容を entries で置き換える
+func (d *DenyList) Replace(entries *DenyListEntries) {
+	tokenIDs := make(map[string]struct{}, len(entries.TokenIDs))
+	for _, jti := range entries.TokenIDs {
+		tokenIDs[jti] = struct{}{}
+	}
+	subjects := make(map[string]struct{}, len(entries.Subjects))
+	for _, sub := range entries.Subjects {
+		subjects[sub] = struct{}{}
+	}
+	issuedBefore := make(map[string]time.Time, len(entries.IssuedBefore))
+	for sub, t := range entries.IssuedBefore {
+		issuedBefore[sub] = t
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tokenIDs = tokenIDs
+	d.subjects = subjects
+	d.issuedBefore = issuedBefore
+	d.loadedAt = d.now()
+}
+
+// RevokeTokenID は jti のトークンを失効させる
+func (d *DenyList) RevokeTokenID(jti string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tokenIDs[jti] = struct{}{}
+}
+
+// RevokeSubject は sub のすべてのトークンを失効させる
+func (d *DenyList) RevokeSubject(sub string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subjects[sub] = struct{}{}
+}
+
+// RevokeIssuedBefore は sub のトークンのうち t より前に発行されたものを失効させる
+func (d *DenyList) RevokeIssuedBefore(sub string, t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if current, ok := d.issuedBefore[sub]; !ok || t.After(current) {
+		d.issuedBefore[sub] = t
+	}
+}
+
+// Check は claim が失効リストに含まれている場合に ErrTokenRevoked を返す。
+// issued_before が設定された sub で iat のないトークンは発行時刻を確認できないため失効として扱う。
+func (d *DenyList) Check(ctx context.Context, claims jwt.MapClaims) error {
+	d.refresh(ctx)
+
+	jti, _ := claims["jti"].(string)
+	sub, _ := claims["sub"].(string)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if _, ok := d.tokenIDs[jti]; ok && jti != "" {
+		return ErrTokenRevoked
+	}
+	if _, ok := d.subjects[sub]; ok && sub != "" {
+		return ErrTokenRevoked
+	}
+	if before, ok := d.issuedBefore[sub]; ok && sub != "" {
+		iat, err := claims.GetIssuedAt()
+		if err != nil || iat == nil || iat.Before(before) {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+func (d *DenyList) refresh(ctx context.Context) {
+	if d.loader == nil {
+		return
+	}
+	d.mu.RLock()
+	stale := d.now().Sub(d.loadedAt) >= d.refreshInterval
+	d.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	entries, err := d.loader.LoadDenyList(ctx)
+	if err != nil {
+		// 読み込みに失敗した場合は直前の内容を使い、次の間隔で再試行する
+		log.Printf("失効リストの再読み込みに失敗しました: %v", err)
+		d.mu.Lock()
+		d.loadedAt = d.now()
+		d.mu.Unlock()
+		return
+	}
+	d.Replace(entries)
+}
+
+// RevocationValidator は Validator の検証に成功したトークンを失効リストと照合する
+type RevocationValidator struct {
+	validator TokenValidator
+	denyList  *DenyList
+}
+
+// NewRevocationValidator は validator の検証後に denyList を確認する RevocationValidator を生成する
+func NewRevocationValidator(validator TokenValidator, denyList *DenyList) *RevocationValidator {
+	return &RevocationValidator{validator: validator, denyList: denyList}
+}
+
+func (v *RevocationValidator) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	claims, err := v.validator.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.denyList.Check(context.Background(), claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// AllowsRoute は内側の Validator が RouteRestrictor を実装していればその判定を返す
+func (v *RevocationValidator) AllowsRoute(issuer, path string) bool {
+	if rr, ok := v.validator.(RouteRestrictor); ok {
+		return rr.AllowsRoute(issuer, path)
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// テスト用の S3ObjectGetter
+type fakeS3 struct {
+	objects map[string][]byte
+	err     error
+	calls   int
+}
+
+func (f *fakeS3) GetObject(_ context.Context, bucket, key string) ([]byte, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	data, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+	return data, nil
+}
+
+func TestDenyList_Check(t *testing.T) {
+	cutoff := time.Unix(1760000000, 0)
+	d := NewDenyList()
+	d.RevokeTokenID("stolen-jti")
+	d.RevokeSubject("disabled-user")
+	d.RevokeIssuedBefore("password-reset-user", cutoff)
+
+	tests := []struct {
+		name        string
+		claims      jwt.MapClaims
+		wantRevoked bool
+	}{
+		{name: "正常系: 失効していないトークン", claims: jwt.MapClaims{"sub": "user-1", "jti": "jti-1"}},
+		{name: "正常系: 失効時刻より後に発行", claims: jwt.MapClaims{"sub": "password-reset-user", "iat": float64(cutoff.Add(time.Second).Unix())}},
+		{name: "失効: jti", claims: jwt.MapClaims{"sub": "user-1", "jti": "stolen-jti"}, wantRevoked: true},
+		{name: "失効: sub", claims: jwt.MapClaims{"sub": "disabled-user"}, wantRevoked: true},
+		{name: "失効: 失効時刻より前に発行", claims: jwt.MapClaims{"sub": "password-reset-user", "iat": float64(cutoff.Add(-time.Second).Unix())}, wantRevoked: true},
+		{name: "失効: iat がなく発行時刻を確認できない", claims: jwt.MapClaims{"sub": "password-reset-user"}, wantRevoked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.Check(context.Background(), tt.claims)
+			if tt.wantRevoked != errors.Is(err, ErrTokenRevoked) {
+				t.Errorf("Check() エラー = %v, 失効の期待値 = %v", err, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func TestDenyList_RefreshFromS3(t *testing.T) {
+	s3 := &fakeS3{objects: map[string][]byte{"security/deny-list.json": []byte(`{"jti": ["jti-1"]}`)}}
+	loader := &S3DenyListLoader{Client: s3, Bucket: "security", Key: "deny-list.json"}
+	d, err := NewDenyListFromLoader(context.Background(), loader, time.Minute)
+	if err != nil {
+		t.Fatalf("NewDenyListFromLoader() エラー = %v", err)
+	}
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	if err := d.Check(context.Background(), jwt.MapClaims{"jti": "jti-1"}); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Check(jti-1) エラー = %v, 期待値 = ErrTokenRevoked", err)
+	}
+
+	// 間隔が経過するまでは読み込み直さない
+	s3.objects["security/deny-list.json"] = []byte(`{"jti": ["jti-2"]}`)
+	if err := d.Check(context.Background(), jwt.MapClaims{"jti": "jti-2"}); err != nil {
+		t.Errorf("再読み込み前の Check(jti-2) エラー = %v, 期待値 = nil", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := d.Check(context.Background(), jwt.MapClaims{"jti": "jti-2"}); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("再読み込み後の Check(jti-2) エラー = %v, 期待値 = ErrTokenRevoked", err)
+	}
+
+	// 読み込みに失敗した場合は直前の内容を使い続ける
+	s3.err = errors.New("AccessDenied")
+	now = now.Add(time.Minute)
+	if err := d.Check(context.Background(), jwt.MapClaims{"jti": "jti-2"}); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("読み込み失敗後の Check(jti-2) エラー = %v, 期待値 = ErrTokenRevoked", err)
+	}
+	if s3.calls != 3 {
+		t.Errorf("GetObject の呼び出し回数 = %d, 期待値 = 3", s3.calls)
+	}
+}
+
+func TestNewDenyListFromEnv(t *testing.T) {
+	t.Setenv("REVOCATION_LIST_FILE", "")
+	if d, err := NewDenyListFromEnv(context.Background()); d != nil || err != nil {
+		t.Errorf("NewDenyListFromEnv() 未設定 = %v, %v, 期待値 = nil, nil", d, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "deny-list.json")
+	if err := os.WriteFile(path, []byte(`{"sub": ["disabled-user"], "issued_before": {"user-1": "2026-10-01T00:00:00Z"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REVOCATION_LIST_FILE", path)
+	d, err := NewDenyListFromEnv(context.Background())
+	if err != nil {
+		t.Fatalf("NewDenyListFromEnv() エラー = %v", err)
+	}
+	if err := d.Check(context.Background(), jwt.MapClaims{"sub": "disabled-user"}); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Check(disabled-user) エラー = %v, 期待値 = ErrTokenRevoked", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`not json`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDenyListFromEnv(context.Background()); err == nil {
+		t.Errorf("NewDenyListFromEnv() 不正な JSON で error = nil")
+	}
+}
+
+func TestRevocationValidator_ValidateToken(t *testing.T) {
+	inner := &restrictedValidator{
+		fakeValidator: fakeValidator{tokens: map[string]jwt.MapClaims{
+			"valid":   {"sub": "user-1", "jti": "jti-1"},
+			"revoked": {"sub": "user-1", "jti": "stolen-jti"},
+		}, err: errors.New("トークンが無効です")},
+		allowed: map[string]bool{" /api/customers/account": true},
+	}
+	d := NewDenyList()
+	d.RevokeTokenID("stolen-jti")
+	v := NewRevocationValidator(inner, d)
+
+	if _, err := v.ValidateToken("valid"); err != nil {
+		t.Errorf("ValidateToken(valid) エラー = %v, 期待値 = nil", err)
+	}
+	if _, err := v.ValidateToken("revoked"); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("ValidateToken(revoked) エラー = %v, 期待値 = ErrTokenRevoked", err)
+	}
+	if _, err := v.ValidateToken("unknown"); err == nil || errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("ValidateToken(unknown) エラー = %v, 期待値 = 内側の検証エラー", err)
+	}
+	if v.AllowsRoute("", "/api/customers/balance") {
+		t.Errorf("AllowsRoute() = true, 内側の RouteRestrictor の判定を使うべき")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/router"
+)
+
+// ルートで認証方式を指定しない場合に試す順序
+var defaultAuthMethods = []string{auth.MethodJWT, auth.MethodDPoP, auth.MethodAPIKey}
+
+// 環境変数に応じて Bearer トークン (jwt)、DPoP トークン (dpop) と API キー (api_key) の認証方式を生成する。
+// API キーだけを設定した場合はトークンを受け付けない。
+// クライアント証明書 (mtls) はルーティング設定で指定したルートでのみ使う。
+func newAuthenticators(ctx context.Context) (map[string]auth.Authenticator, error) {
+	tokenConfigured := os.Getenv("AUTH_ISSUERS") != "" || os.Getenv("AUTH0_DOMAIN") != "" ||
+		os.Getenv("AUTH0_AUDIENCE") != "" || os.Getenv("INTROSPECTION_ENDPOINT") != ""
+	apiKeyConfigured := os.Getenv("API_KEYS") != "" || os.Getenv("API_KEYS_FILE") != ""
+
+	authenticators := make(map[string]auth.Authenticator)
+	if tokenConfigured || !apiKeyConfigured {
+		// IdP の一時的な障害で初期化に失敗してもコンテナを使い続けられるよう、失敗した場合はリクエスト時に再試行する
+		v := auth.NewLazyValidator(newTokenValidator)
+		if err := v.Init(ctx); err != nil {
+			log.Printf("トークンの検証方式の初回の初期化に失敗しました。リクエスト時に再試行します: %v", err)
+		}
+		authenticators[auth.MethodJWT] = &auth.BearerAuthenticator{Validator: v}
+
+		verifier, err := auth.NewDPoPVerifierFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		authenticators[auth.MethodDPoP] = &auth.DPoPAuthenticator{Validator: v, Verifier: verifier}
+	}
+	if apiKeyConfigured {
+		a, err := auth.NewAPIKeyAuthenticatorFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		authenticators[auth.MethodAPIKey] = a
+	}
+
+	m, err := auth.NewMTLSAuthenticatorFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	authenticators[auth.MethodMTLS] = m
+	return authenticators, nil
+}
+
+// 環境変数に応じて Auth0 (JWT) の検証とトークンイントロスペクションを組み合わせる。
+// 両方が設定されている場合は JWT として検証できないトークンをイントロスペクションに回す。
+// 失効リストが設定されている場合は、検証に成功したトークンを失効リストと照合する。
+func newTokenValidator(ctx context.Context) (auth.TokenValidator, error) {
+	jwtConfigured := os.Getenv("AUTH_ISSUERS") != "" || os.Getenv("AUTH0_DOMAIN") != "" || os.Getenv("AUTH0_AUDIENCE") != ""
+	introspectionConfigured := os.Getenv("INTROSPECTION_ENDPOINT") != ""
+
+	var validators []auth.TokenValidator
+	var jwtValidator *auth.Validator
+	if jwtConfigured || !introspectionConfigured {
+		v, err := auth.NewValidator(ctx)
+		if err != nil {
+			return nil, err
+		}
+		jwtValidator = v
+		validators = append(validators, v)
+	}
+	// 以降の初期化に失敗した場合は、LazyValidator が再試行するたびに JWKS の取得が増えないよう止める
+	fail := func(err error) (auth.TokenValidator, error) {
+		if jwtValidator != nil {
+			jwtValidator.Close()
+		}
+		return nil, err
+	}
+	if introspectionConfigured {
+		v, err := auth.NewIntrospectionValidatorFromEnv()
+		if err != nil {
+			return fail(err)
+		}
+		validators = append(validators, v)
+	}
+
+	var validator auth.TokenValidator
+	switch len(validators) {
+	case 0:
+		return nil, errors.New("トークンの検証方式が設定されていません")
+	case 1:
+		validator = validators[0]
+	default:
+		validator = auth.NewChainValidator(validators...)
+	}
+
+	denyList, err := auth.NewDenyListFromEnv(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	if denyList != nil {
+		validator = auth.NewRevocationValidator(validator, denyList)
+	}
+	return validator, nil
+}
+
+// 全ルート共通の認証方式を既定の順序で組み合わせる
+func defaultAuthenticator(authenticators map[string]auth.Authenticator) auth.Authenticator {
+	var chain []auth.Authenticator
+	for _, m := range defaultAuthMethods {
+		chain = append(chain, authenticators[m])
+	}
+	return auth.NewChainAuthenticator(chain...)
+}
+
+// ルーティング設定で認証方式を指定したルートごとに Authenticator を生成する。
+// 指定のないルートは含まないため、呼び出し側で defaultAuthenticator を使う。
+func newRouteAuthenticators(routes []router.Route, authenticators map[string]auth.Authenticator) (map[*router.Route]auth.Authenticator, error) {
+	result := make(map[*router.Route]auth.Authenticator)
+	for i := range routes {
+		route := &routes[i]
+		if len(route.Auth) == 0 {
+			continue
+		}
+
+		chain := make([]auth.Authenticator, 0, len(route.Auth))
+		for _, entry := range route.Auth {
+			methods := strings.Split(entry, auth.MethodSeparator)
+			all := make([]auth.Authenticator, 0, len(methods))
+			for _, m := range methods {
+				a, err := routeAuthenticator(route, m, authenticators)
+				if err != nil {
+					return nil, err
+				}
+				all = append(all, a)
+			}
+			if len(all) == 1 {
+				chain = append(chain, all[0])
+			} else {
+				chain = append(chain, auth.NewAllAuthenticator(all...))
+			}
+		}
+		result[route] = auth.NewChainAuthenticator(chain...)
+	}
+	return result, nil
+}
+
+func routeAuthenticator(route *router.Route, method string, authenticators map[string]auth.Authenticator) (auth.Authenticator, error) {
+	if method == auth.MethodHMAC {
+		a, err := auth.NewHMACAuthenticator(*route.HMAC)
+		if err != nil {
+			return nil, fmt.Errorf("ルート %s の HMAC 認証の初期化に失敗しました: %w", route.Path, err)
+		}
+		return a, nil
+	}
+	a, ok := authenticators[method]
+	if !ok {
+		return nil, fmt.Errorf("ルート %s の認証方式 %q が設定されていません", route.Path, method)
+	}
+	return a, nil
+}
@@ -3,13 +3,26 @@ module github.com/aki80204/go-gateway
 go 1.23.2
 
 require (
+	github.com/MicahParks/jwkset v0.5.18
 	github.com/MicahParks/keyfunc/v3 v3.3.3
 	github.com/aws/aws-lambda-go v1.47.0
+	github.com/getkin/kin-openapi v0.133.0
 	github.com/golang-jwt/jwt/v5 v5.2.0
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.2
+	golang.org/x/time v0.5.0
 )
 
 require (
-	github.com/MicahParks/jwkset v0.5.18 // indirect
+	github.com/go-openapi/jsonpointer v0.21.0 // indirect
+	github.com/go-openapi/swag v0.23.0 // indirect
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/mailru/easyjson v0.7.7 // indirect
+	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
+	github.com/oasdiff/yaml v0.0.0-20250309154309-f31be36b4037 // indirect
+	github.com/oasdiff/yaml3 v0.0.0-20250309153720-d2182401db90 // indirect
+	github.com/perimeterx/marshmallow v1.1.5 // indirect
 	github.com/stretchr/testify v1.11.1 // indirect
-	golang.org/x/time v0.5.0 // indirect
+	github.com/woodsbury/decimal128 v1.3.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
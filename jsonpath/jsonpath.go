@@ -0,0 +1,180 @@
+// Package jsonpath は JSONPath (RFC 9535) のうち、値の位置を指定する構文で JSON の値を参照・変更する。
+//
+// 対応する構文は $ (ルート)、.name と ['name'] (メンバー)、[0] と [-1] (配列の要素)、.* と [*] (すべての子)、
+// ..name / ..* / ..[0] (子孫) に限る。フィルター式やスライスには対応しない。
+// 値は encoding/json で any に解析した map[string]any と []any を対象とする。
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type selectorKind int
+
+const (
+	selectName selectorKind = iota
+	selectIndex
+	selectWildcard
+)
+
+type segment struct {
+	kind       selectorKind
+	name       string
+	index      int
+	descendant bool
+}
+
+// Path はコンパイル済みの JSONPath
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+// Compile は JSONPath の式を解析する
+func Compile(expr string) (*Path, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("JSONPath は $ で始めてください: %q", expr)
+	}
+	p := &Path{expr: expr}
+	rest := expr[1:]
+	for rest != "" {
+		var seg segment
+		var err error
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				seg, rest, err = parseBracket(rest)
+			} else {
+				seg, rest, err = parseDotted(rest)
+			}
+			seg.descendant = true
+		case strings.HasPrefix(rest, "."):
+			seg, rest, err = parseDotted(rest[1:])
+		case strings.HasPrefix(rest, "["):
+			seg, rest, err = parseBracket(rest)
+		default:
+			err = fmt.Errorf("解析できない文字があります: %q", rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JSONPath の形式が不正です (%s): %w", expr, err)
+		}
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// .name または .* を解析する
+func parseDotted(s string) (segment, string, error) {
+	if strings.HasPrefix(s, "*") {
+		return segment{kind: selectWildcard}, s[1:], nil
+	}
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	if end == 0 {
+		return segment{}, "", fmt.Errorf("メンバー名がありません: %q", s)
+	}
+	return segment{kind: selectName, name: s[:end]}, s[end:], nil
+}
+
+// ['name']、[0]、[*] を解析する
+func parseBracket(s string) (segment, string, error) {
+	s = s[1:]
+	if len(s) > 0 && (s[0] == '\'' || s[0] == '"') {
+		quote := s[0]
+		var name strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				if i+1 < len(s) {
+					i++
+					name.WriteByte(s[i])
+				}
+			case quote:
+				if !strings.HasPrefix(s[i+1:], "]") {
+					return segment{}, "", fmt.Errorf("] がありません: %q", s)
+				}
+				return segment{kind: selectName, name: name.String()}, s[i+2:], nil
+			default:
+				name.WriteByte(s[i])
+			}
+		}
+		return segment{}, "", fmt.Errorf("引用符が閉じていません: %q", s)
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return segment{}, "", fmt.Errorf("] がありません: %q", s)
+	}
+	inner := s[:end]
+	if inner == "*" {
+		return segment{kind: selectWildcard}, s[end+1:], nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return segment{}, "", fmt.Errorf("配列の添字が不正です: %q", inner)
+	}
+	return segment{kind: selectIndex, index: index}, s[end+1:], nil
+}
+
+// String は JSONPath の式を返す
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Modify は v のうち p に一致する値を fn の戻り値で置き換え、置き換えた後の v を返す。
+// map[string]any と []any はその場で書き換える。p が $ の場合は fn(v) を返す
+func (p *Path) Modify(v any, fn func(any) any) any {
+	return modify(v, p.segments, fn)
+}
+
+func modify(v any, segments []segment, fn func(any) any) any {
+	if len(segments) == 0 {
+		return fn(v)
+	}
+	seg, rest := segments[0], segments[1:]
+	if seg.descendant {
+		// 子孫を先に処理し、置き換えた値の中を再び照合しないようにする
+		eachChild(v, func(child any) any { return modify(child, segments, fn) })
+	}
+
+	switch seg.kind {
+	case selectWildcard:
+		eachChild(v, func(child any) any { return modify(child, rest, fn) })
+	case selectName:
+		if m, ok := v.(map[string]any); ok {
+			if child, ok := m[seg.name]; ok {
+				m[seg.name] = modify(child, rest, fn)
+			}
+		}
+	case selectIndex:
+		if a, ok := v.([]any); ok {
+			i := seg.index
+			if i < 0 {
+				i += len(a)
+			}
+			if i >= 0 && i < len(a) {
+				a[i] = modify(a[i], rest, fn)
+			}
+		}
+	}
+	return v
+}
+
+// オブジェクトのメンバーと配列の要素を fn の戻り値で置き換える
+func eachChild(v any, fn func(any) any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = fn(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = fn(child)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPath_Modify(t *testing.T) {
+	const doc = `{"accountNumber": "1234567890", "owners": [{"name": "山田", "nationalId": "A1"}, {"name": "佐藤", "nationalId": "B2"}],
+		"meta": {"owner.name": "x", "nested": {"nationalId": "C3"}}}`
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "$.accountNumber", want: `{"accountNumber":"#","meta":{"nested":{"nationalId":"C3"},"owner.name":"x"},"owners":[{"name":"山田","nationalId":"A1"},{"name":"佐藤","nationalId":"B2"}]}`},
+		{expr: "$.owners[*].nationalId", want: `{"accountNumber":"1234567890","meta":{"nested":{"nationalId":"C3"},"owner.name":"x"},"owners":[{"name":"山田","nationalId":"#"},{"name":"佐藤","nationalId":"#"}]}`},
+		{expr: "$.owners[-1]['name']", want: `{"accountNumber":"1234567890","meta":{"nested":{"nationalId":"C3"},"owner.name":"x"},"owners":[{"name":"山田","nationalId":"A1"},{"name":"#","nationalId":"B2"}]}`},
+		{expr: "$..nationalId", want: `{"accountNumber":"1234567890","meta":{"nested":{"nationalId":"#"},"owner.name":"x"},"owners":[{"name":"山田","nationalId":"#"},{"name":"佐藤","nationalId":"#"}]}`},
+		{expr: `$.meta["owner.name"]`, want: `{"accountNumber":"1234567890","meta":{"nested":{"nationalId":"C3"},"owner.name":"#"},"owners":[{"name":"山田","nationalId":"A1"},{"name":"佐藤","nationalId":"B2"}]}`},
+		{expr: "$.missing.value", want: `{"accountNumber":"1234567890","meta":{"nested":{"nationalId":"C3"},"owner.name":"x"},"owners":[{"name":"山田","nationalId":"A1"},{"name":"佐藤","nationalId":"B2"}]}`},
+		{expr: "$", want: `"#"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			var v any
+			if err := json.Unmarshal([]byte(doc), &v); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(p.Modify(v, func(any) any { return "#" }))
+			if string(got) != tt.want {
+				t.Errorf("Modify() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for _, expr := range []string{"accountNumber", "$.", "$[abc]", "$['name'", "$[0", "$name", "$.owners[?(@.x)]"} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("Compile(%q) error = nil, want error", expr)
+		}
+	}
+}
@@ -2,51 +2,193 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 
+	"github.com/aki80204/go-gateway/adapter"
 	"github.com/aki80204/go-gateway/auth"
 	"github.com/aki80204/go-gateway/proxy"
 	"github.com/aki80204/go-gateway/router"
 	"github.com/aki80204/go-gateway/utils"
 )
 
-var validator *auth.Validator
+var authenticator auth.Authenticator
+var routeAuthenticators map[*router.Route]auth.Authenticator
+var claimForwarder *auth.ClaimForwarder
 var gatewayRouter *router.Router
 
-// 起動時にAuth0のvalidatorとRouterを初期化する
+// 起動時に認証方式とRouterを初期化する
 func init() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	v, err := auth.NewValidator(ctx)
+	// claim の転送設定が不正な場合は validator を初期化せず、全リクエストを 500 にする
+	cf, err := auth.NewClaimForwarderFromEnv()
+	if err != nil {
+		log.Printf("claim 転送設定の初期化に失敗しました: %v", err)
+		return
+	}
+	claimForwarder = cf
+
+	routes, err := router.LoadRoutesFromEnv()
+	if err != nil {
+		log.Printf("ルーティング設定の読み込みに失敗しました: %v", err)
+		return
+	}
+
+	authenticators, err := newAuthenticators(ctx)
 	if err != nil {
 		log.Printf("auth validator の初期化に失敗しました: %v", err)
 		return
 	}
-	validator = v
-	gatewayRouter = router.NewRouter(proxy.ProxyRequest)
+	ra, err := newRouteAuthenticators(routes, authenticators)
+	if err != nil {
+		log.Printf("auth validator の初期化に失敗しました: %v", err)
+		return
+	}
+
+	authenticator = defaultAuthenticator(authenticators)
+	routeAuthenticators = ra
+	gatewayRouter = router.NewRouterWithRoutes(proxy.ProxyRequest, routes)
 }
 
-// APIGatewayから呼び出されるLambda関数
-func Handler(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+// ルートで認証方式が指定されていればそれを、なければ全ルート共通の認証方式を返す
+func authenticatorFor(route *router.Route) auth.Authenticator {
+	if a, ok := routeAuthenticators[route]; ok {
+		return a
+	}
+	return authenticator
+}
+
+// ゲートウェイの本体。認証・ルーティングを行い、バックエンドへのプロキシでレスポンスをストリーミングする。
+// Lambda ではイベントのアダプター経由で、serve モードでは net/http のサーバーから直接呼び出す
+func serveGateway(w http.ResponseWriter, r *http.Request) {
 	// validatorが初期化されていない場合はエラーを返す
-	if validator == nil {
-		log.Printf("auth validator が初期化されていません。環境変数 AUTH0_DOMAIN/AUTH0_AUDIENCE を確認してください。")
-		return utils.ErrorResponse(500, "Internal Server Error"), nil
+	if authenticator == nil || gatewayRouter == nil {
+		log.Printf("auth validator が初期化されていません。環境変数 AUTH0_DOMAIN/AUTH0_AUDIENCE、AUTH_ISSUERS、INTROSPECTION_ENDPOINT、API_KEYS を確認してください。")
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	route, ok := gatewayRouter.Match(r)
+	if !ok {
+		utils.WriteError(w, http.StatusNotFound, "Not Found")
+		return
 	}
 
-	sub, err := auth.CheckAuth(*validator, request)
+	// ボディの大きさなどの制限は、ボディを読む認証方式 (HMAC) より前に確認する
+	if err := route.CheckLimits(r); err != nil {
+		var limitErr *router.LimitError
+		if errors.As(err, &limitErr) {
+			utils.WriteError(w, limitErr.Status, limitErr.Message)
+			return
+		}
+		utils.WriteError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	principal, err := authenticatorFor(route).Authenticate(r)
+	if errors.Is(err, auth.ErrValidatorUnavailable) {
+		utils.WriteError(w, http.StatusServiceUnavailable, "Service Unavailable")
+		return
+	}
+	if errors.Is(err, auth.ErrTokenRevoked) {
+		utils.WriteUnauthorized(w, "invalid_token", "The access token has been revoked")
+		return
+	}
 	if err != nil {
-		return utils.ErrorResponse(401, "Unauthorized"), nil
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// ルートの scopes (OpenAPI 定義の security) を満たさない場合は 403
+	if !route.AllowsScopes(principal) {
+		utils.WriteInsufficientScope(w, strings.Join(route.Scopes[0], " "))
+		return
+	}
+
+	// 検証済みの claim をバックエンド向けのヘッダーとして付与する
+	headers, err := claimForwarder.Apply(r.Header, principal.Claims)
+	if err != nil {
+		log.Printf("claim ヘッダーの生成に失敗しました: %v", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	r.Header = headers
+
+	// スキーマに適合しないリクエストはバックエンドへ転送しない
+	violations, err := route.ValidateRequest(r)
+	if err != nil {
+		log.Printf("リクエストボディの読み取りに失敗しました: %v", err)
+		utils.WriteError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+	if len(violations) > 0 {
+		utils.WriteProblem(w, utils.Problem{
+			Status: http.StatusBadRequest,
+			Detail: "The request does not conform to the schema",
+			Errors: violations,
+		})
+		return
 	}
 
-	return gatewayRouter.Route(request, sub)
+	gatewayRouter.Forward(w, r, route, principal)
+}
+
+// APIGatewayから呼び出されるLambda関数
+func Handler(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	return adapter.APIGatewayV2(http.HandlerFunc(serveGateway))(ctx, request)
+}
+
+// LAMBDA_EVENT_SOURCE に応じて Lambda のイベントを変換するハンドラを返す。
+// 既定 (auto) ではイベントの形式から API Gateway v1/v2、ALB、Function URL を判別する
+func lambdaHandler() (interface{}, error) {
+	h := http.HandlerFunc(serveGateway)
+	switch source := os.Getenv("LAMBDA_EVENT_SOURCE"); source {
+	case "", "auto":
+		return adapter.Handler(h), nil
+	case "apigw_v2":
+		return adapter.APIGatewayV2(h), nil
+	case "apigw_v1":
+		return adapter.APIGatewayV1(h), nil
+	case "alb":
+		return adapter.ALB(h), nil
+	case "function_url":
+		return adapter.FunctionURL(h), nil
+	case "function_url_stream":
+		return adapter.FunctionURLStreaming(h), nil
+	default:
+		return nil, fmt.Errorf("LAMBDA_EVENT_SOURCE の値が不正です: %s", source)
+	}
 }
 
 func main() {
-	lambda.Start(Handler)
+	serveFlag := flag.Bool("serve", false, "Lambda ではなく HTTP サーバーとして起動する (GATEWAY_MODE=serve と同じ)")
+	addrFlag := flag.String("addr", "", "serve モードで待ち受けるアドレス (既定: LISTEN_ADDR または :8080)")
+	flag.Parse()
+
+	if serveMode(*serveFlag) {
+		tlsConfig, err := tlsConfigFromEnv()
+		if err != nil {
+			log.Fatalf("TLS の設定に失敗しました: %v", err)
+		}
+		if err := serve(listenAddr(*addrFlag), http.HandlerFunc(serveGateway), tlsConfig); err != nil {
+			log.Fatalf("HTTP サーバーが異常終了しました: %v", err)
+		}
+		return
+	}
+	handler, err := lambdaHandler()
+	if err != nil {
+		log.Fatalf("Lambda ハンドラの初期化に失敗しました: %v", err)
+	}
+	lambda.Start(handler)
 }
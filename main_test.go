@@ -0,0 +1,668 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/router"
+)
+
+// テスト用の TokenValidator。tokens に登録されたトークンだけを有効とする
+type fakeValidator struct {
+	tokens map[string]jwt.MapClaims
+}
+
+func (f fakeValidator) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	if claims, ok := f.tokens[tokenString]; ok {
+		return claims, nil
+	}
+	return nil, errors.New("トークンが無効です")
+}
+
+// ルート制限付きの TokenValidator
+type restrictedValidator struct {
+	fakeValidator
+	routes map[string]string
+}
+
+func (r restrictedValidator) AllowsRoute(issuer, path string) bool {
+	allowed, ok := r.routes[issuer]
+	return !ok || allowed == path
+}
+
+// proxy に渡された値を記録する
+type capturedProxy struct {
+	called    bool
+	targetURL string
+	principal *auth.Principal
+	headers   http.Header
+	rawQuery  string
+}
+
+// Handler が参照するグローバル変数をテスト用に差し替え、終了時に元に戻す
+func setupHandler(t *testing.T, a auth.Authenticator, cf *auth.ClaimForwarder) *capturedProxy {
+	return setupRoutedHandler(t, a, cf, router.DefaultRoutes(), nil)
+}
+
+// setupHandler に加えてルーティング設定とルートごとの認証方式を差し替える
+func setupRoutedHandler(t *testing.T, a auth.Authenticator, cf *auth.ClaimForwarder, routes []router.Route, authenticators map[string]auth.Authenticator) *capturedProxy {
+	t.Helper()
+	origAuthenticator, origRouteAuthenticators, origForwarder, origRouter := authenticator, routeAuthenticators, claimForwarder, gatewayRouter
+	t.Cleanup(func() {
+		authenticator, routeAuthenticators, claimForwarder, gatewayRouter = origAuthenticator, origRouteAuthenticators, origForwarder, origRouter
+	})
+
+	captured := &capturedProxy{}
+	authenticator = a
+	claimForwarder = cf
+	gatewayRouter = router.NewRouterWithRoutes(func(w http.ResponseWriter, r *http.Request, targetBaseURL string, principal *auth.Principal) {
+		captured.called = true
+		captured.targetURL = targetBaseURL
+		captured.principal = principal
+		captured.headers = r.Header
+		captured.rawQuery = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+	}, routes)
+
+	ra, err := newRouteAuthenticators(gatewayRouter.Routes(), authenticators)
+	if err != nil {
+		t.Fatalf("newRouteAuthenticators() error = %v", err)
+	}
+	routeAuthenticators = ra
+	return captured
+}
+
+func makeRequest(path, method, authorization string) events.APIGatewayV2HTTPRequest {
+	headers := map[string]string{}
+	if authorization != "" {
+		headers["authorization"] = authorization
+	}
+	return events.APIGatewayV2HTTPRequest{
+		RawPath: path,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: method},
+		},
+		Headers: headers,
+	}
+}
+
+func withHeader(request events.APIGatewayV2HTTPRequest, key, value string) events.APIGatewayV2HTTPRequest {
+	request.Headers[key] = value
+	return request
+}
+
+func TestHandler(t *testing.T) {
+	t.Setenv("ACCOUNT_SERVICE_URL", "https://account.example.com")
+	t.Setenv("BALANCE_SERVICE_URL", "https://balance.example.com")
+
+	validator := restrictedValidator{
+		fakeValidator: fakeValidator{tokens: map[string]jwt.MapClaims{
+			"valid-token":   {"sub": "user-123", "iss": "https://tenant.auth0.com/", "email": "user@example.com"},
+			"machine-token": {"sub": "batch", "iss": "https://machine.example.com/"},
+			"no-sub-token":  {"iss": "https://tenant.auth0.com/"},
+			"int-sub-token": {"sub": float64(1), "iss": "https://tenant.auth0.com/"},
+		}},
+		routes: map[string]string{"https://machine.example.com/": router.BALANCE_SERVICE_PATH},
+	}
+	store, err := auth.NewMemoryAPIKeyStore([]auth.APIKeyRecord{
+		{KeyHash: auth.HashAPIKey("batch-api-key"), APIKey: auth.APIKey{Identity: "batch-job"}},
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryAPIKeyStore() error = %v", err)
+	}
+	authenticators := auth.NewChainAuthenticator(
+		&auth.BearerAuthenticator{Validator: validator},
+		&auth.APIKeyAuthenticator{Store: store},
+	)
+
+	tests := []struct {
+		name           string
+		request        events.APIGatewayV2HTTPRequest
+		wantStatusCode int
+		wantSub        string
+		wantTargetURL  string
+	}{
+		{
+			name:           "正常系: 有効なトークン",
+			request:        makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer valid-token"),
+			wantStatusCode: 200,
+			wantSub:        "user-123",
+			wantTargetURL:  "https://account.example.com",
+		},
+		{
+			name:           "正常系: 許可されたルートでのマシントークン",
+			request:        makeRequest(router.BALANCE_SERVICE_PATH, "POST", "Bearer machine-token"),
+			wantStatusCode: 200,
+			wantSub:        "batch",
+			wantTargetURL:  "https://balance.example.com",
+		},
+		{
+			name:           "異常系: 許可されていないルートでのマシントークン",
+			request:        makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer machine-token"),
+			wantStatusCode: 401,
+		},
+		{
+			name:           "異常系: Authorization ヘッダーなし",
+			request:        makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", ""),
+			wantStatusCode: 401,
+		},
+		{
+			name:           "異常系: 無効なトークン",
+			request:        makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer unknown-token"),
+			wantStatusCode: 401,
+		},
+		{
+			name:           "異常系: sub のないトークン",
+			request:        makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer no-sub-token"),
+			wantStatusCode: 401,
+		},
+		{
+			name:           "異常系: sub が数値のトークン",
+			request:        makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer int-sub-token"),
+			wantStatusCode: 401,
+		},
+		{
+			name:           "正常系: API キー",
+			request:        withHeader(makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", ""), "x-api-key", "batch-api-key"),
+			wantStatusCode: 200,
+			wantSub:        "batch-job",
+			wantTargetURL:  "https://account.example.com",
+		},
+		{
+			name:           "異常系: 未登録の API キー",
+			request:        withHeader(makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", ""), "x-api-key", "unknown"),
+			wantStatusCode: 401,
+		},
+		{
+			name:           "異常系: 未知のパス",
+			request:        makeRequest("/api/unknown", "GET", "Bearer valid-token"),
+			wantStatusCode: 404,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured := setupHandler(t, authenticators, nil)
+
+			resp, err := Handler(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("Handler() error = %v, want nil", err)
+			}
+			if resp.StatusCode != tt.wantStatusCode {
+				t.Errorf("Handler() StatusCode = %d, want %d", resp.StatusCode, tt.wantStatusCode)
+			}
+			if tt.wantSub == "" {
+				if captured.called {
+					t.Errorf("Handler() が proxy を呼び出しました")
+				}
+				return
+			}
+			if captured.principal == nil || captured.principal.Subject != tt.wantSub {
+				t.Errorf("proxy に渡された principal = %+v, want sub %q", captured.principal, tt.wantSub)
+			}
+			if captured.targetURL != tt.wantTargetURL {
+				t.Errorf("proxy に渡された URL = %q, want %q", captured.targetURL, tt.wantTargetURL)
+			}
+		})
+	}
+}
+
+func TestHandler_NotInitialized(t *testing.T) {
+	setupHandler(t, nil, nil)
+
+	resp, err := Handler(context.Background(), makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer valid-token"))
+	if err != nil {
+		t.Fatalf("Handler() error = %v, want nil", err)
+	}
+	if resp.StatusCode != 500 {
+		t.Errorf("Handler() StatusCode = %d, want 500", resp.StatusCode)
+	}
+}
+
+func TestHandler_ForwardsClaims(t *testing.T) {
+	t.Setenv("ACCOUNT_SERVICE_URL", "https://account.example.com")
+
+	cf, err := auth.NewClaimForwarder(auth.ClaimForwarderConfig{
+		Mappings: []auth.ClaimHeaderMapping{{Claim: "email", Header: "X-Auth-Email"}},
+	})
+	if err != nil {
+		t.Fatalf("NewClaimForwarder() error = %v", err)
+	}
+	validator := fakeValidator{tokens: map[string]jwt.MapClaims{
+		"valid-token": {"sub": "user-123", "email": "user@example.com"},
+	}}
+	captured := setupHandler(t, &auth.BearerAuthenticator{Validator: validator}, cf)
+
+	request := makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer valid-token")
+	request.Headers["x-auth-email"] = "spoofed@example.com"
+
+	if _, err := Handler(context.Background(), request); err != nil {
+		t.Fatalf("Handler() error = %v, want nil", err)
+	}
+	if got := captured.headers.Values("X-Auth-Email"); len(got) != 1 || got[0] != "user@example.com" {
+		t.Errorf("X-Auth-Email = %q, want [user@example.com] (クライアント由来の値は転送しない)", got)
+	}
+}
+
+func TestHandler_ChainValidator(t *testing.T) {
+	t.Setenv("ASSET_SERVICE_URL", "https://asset.example.com")
+
+	jwtValidator := fakeValidator{tokens: map[string]jwt.MapClaims{"jwt-token": {"sub": "jwt-user"}}}
+	introspection := fakeValidator{tokens: map[string]jwt.MapClaims{"opaque-token": {"sub": "partner", "active": true}}}
+	captured := setupHandler(t, &auth.BearerAuthenticator{Validator: auth.NewChainValidator(jwtValidator, introspection)}, nil)
+
+	for token, wantSub := range map[string]string{"jwt-token": "jwt-user", "opaque-token": "partner"} {
+		resp, _ := Handler(context.Background(), makeRequest(router.ASSET_SERVICE_PATH, "GET", "Bearer "+token))
+		if resp.StatusCode != 200 {
+			t.Errorf("Handler(%s) StatusCode = %d, want 200", token, resp.StatusCode)
+			continue
+		}
+		if captured.principal.Subject != wantSub {
+			t.Errorf("Handler(%s) sub = %q, want %q", token, captured.principal.Subject, wantSub)
+		}
+	}
+}
+
+func TestHandler_APIKeyQueryParamNotForwarded(t *testing.T) {
+	t.Setenv("BALANCE_SERVICE_URL", "https://balance.example.com")
+
+	store, err := auth.NewMemoryAPIKeyStore([]auth.APIKeyRecord{
+		{KeyHash: auth.HashAPIKey("batch-key"), APIKey: auth.APIKey{Identity: "batch-job"}},
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryAPIKeyStore() error = %v", err)
+	}
+	captured := setupHandler(t, &auth.APIKeyAuthenticator{Store: store, QueryParam: "api_key"}, nil)
+
+	request := makeRequest(router.BALANCE_SERVICE_PATH, "GET", "")
+	request.RawQueryString = "from=2024-01-01&api_key=batch-key"
+	resp, err := Handler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Handler() error = %v, want nil", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Handler() StatusCode = %d, want 200", resp.StatusCode)
+	}
+	if captured.rawQuery != "from=2024-01-01" {
+		t.Errorf("proxy に渡されたクエリ = %q, want %q (API キーは転送しない)", captured.rawQuery, "from=2024-01-01")
+	}
+}
+
+func TestHandler_RouteAuthMethods(t *testing.T) {
+	t.Setenv("PAYMENT_WEBHOOK_SECRET", "whsec")
+
+	bearer := &auth.BearerAuthenticator{Validator: fakeValidator{tokens: map[string]jwt.MapClaims{
+		"valid-token": {"sub": "user-123"},
+	}}}
+	authenticators := map[string]auth.Authenticator{auth.MethodJWT: bearer}
+	routes := []router.Route{
+		{
+			Path: "/api/customers/balance/webhook", Methods: []string{"POST"}, Upstream: "https://balance.internal",
+			Auth: []string{auth.MethodHMAC},
+			HMAC: &auth.HMACConfig{SecretEnv: "PAYMENT_WEBHOOK_SECRET", Identity: "payment-provider"},
+		},
+		{Path: router.BALANCE_SERVICE_PATH, Methods: []string{"GET"}, Upstream: "https://balance.internal"},
+	}
+	captured := setupRoutedHandler(t, defaultAuthenticator(authenticators), nil, routes, authenticators)
+
+	body := `{"event":"balance.updated"}`
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte("whsec"))
+	mac.Write([]byte(ts + "." + body))
+	webhook := makeRequest("/api/customers/balance/webhook", "POST", "")
+	webhook.Body = body
+	webhook.Headers["x-signature"] = hex.EncodeToString(mac.Sum(nil))
+	webhook.Headers["x-signature-timestamp"] = ts
+
+	tests := []struct {
+		name           string
+		request        events.APIGatewayV2HTTPRequest
+		wantStatusCode int
+		wantSub        string
+	}{
+		{name: "正常系: 署名付きの webhook", request: webhook, wantStatusCode: 200, wantSub: "payment-provider"},
+		{name: "異常系: 同じ webhook の再送", request: webhook, wantStatusCode: 401},
+		{name: "異常系: webhook ルートに JWT", request: makeRequest("/api/customers/balance/webhook", "POST", "Bearer valid-token"), wantStatusCode: 401},
+		{name: "正常系: 認証方式の指定がないルートに JWT", request: makeRequest(router.BALANCE_SERVICE_PATH, "GET", "Bearer valid-token"), wantStatusCode: 200, wantSub: "user-123"},
+		{name: "異常系: 未知のパスは認証前に 404", request: makeRequest("/api/unknown", "GET", ""), wantStatusCode: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured.principal = nil
+			resp, err := Handler(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("Handler() error = %v, want nil", err)
+			}
+			if resp.StatusCode != tt.wantStatusCode {
+				t.Errorf("Handler() StatusCode = %d, want %d", resp.StatusCode, tt.wantStatusCode)
+			}
+			if tt.wantSub != "" && (captured.principal == nil || captured.principal.Subject != tt.wantSub) {
+				t.Errorf("proxy に渡された principal = %+v, want sub %q", captured.principal, tt.wantSub)
+			}
+		})
+	}
+}
+
+func TestNewRouteAuthenticators_UnknownMethod(t *testing.T) {
+	routes := []router.Route{{Path: "/api", Methods: []string{"GET"}, Upstream: "https://x", Auth: []string{auth.MethodAPIKey}}}
+	if _, err := newRouteAuthenticators(routes, map[string]auth.Authenticator{}); err == nil {
+		t.Errorf("newRouteAuthenticators() error = nil, want error for unconfigured api_key")
+	}
+}
+
+func TestHandler_RouteRequiresJWTAndMTLS(t *testing.T) {
+	bearer := &auth.BearerAuthenticator{Validator: fakeValidator{tokens: map[string]jwt.MapClaims{
+		"valid-token": {"sub": "user-123"},
+	}}}
+	mtls, err := auth.NewMTLSAuthenticator(auth.MTLSConfig{})
+	if err != nil {
+		t.Fatalf("NewMTLSAuthenticator() error = %v", err)
+	}
+	authenticators := map[string]auth.Authenticator{auth.MethodJWT: bearer, auth.MethodMTLS: mtls}
+	routes := []router.Route{{
+		Path: "/api/payments/transfer", Methods: []string{"POST"}, Upstream: "https://payments.internal",
+		Auth: []string{auth.MethodJWT + auth.MethodSeparator + auth.MethodMTLS},
+	}}
+	captured := setupRoutedHandler(t, defaultAuthenticator(authenticators), nil, routes, authenticators)
+
+	certPEM := newClientCertPEM(t, "payments-batch")
+	withCert := func(r events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPRequest {
+		r.RequestContext.Authentication.ClientCert = events.APIGatewayV2HTTPRequestContextAuthenticationClientCert{
+			ClientCertPem: certPEM,
+			SubjectDN:     "CN=payments-batch",
+		}
+		return r
+	}
+
+	tests := []struct {
+		name           string
+		request        events.APIGatewayV2HTTPRequest
+		wantStatusCode int
+	}{
+		{name: "正常系: JWT とクライアント証明書", request: withCert(makeRequest("/api/payments/transfer", "POST", "Bearer valid-token")), wantStatusCode: 200},
+		{name: "異常系: JWT のみ", request: makeRequest("/api/payments/transfer", "POST", "Bearer valid-token"), wantStatusCode: 401},
+		{name: "異常系: クライアント証明書のみ", request: withCert(makeRequest("/api/payments/transfer", "POST", "")), wantStatusCode: 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured.principal = nil
+			resp, err := Handler(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("Handler() error = %v, want nil", err)
+			}
+			if resp.StatusCode != tt.wantStatusCode {
+				t.Errorf("Handler() StatusCode = %d, want %d", resp.StatusCode, tt.wantStatusCode)
+			}
+			if tt.wantStatusCode == 200 {
+				if p := captured.principal; p == nil || p.Subject != "user-123" || p.ClientCert == nil || p.ClientCert.Identity != "payments-batch" {
+					t.Errorf("proxy に渡された principal = %+v, want sub user-123 と証明書 payments-batch", p)
+				}
+			}
+		})
+	}
+}
+
+// API Gateway の mTLS で渡される形式の自己署名証明書を生成する
+func newClientCertPEM(t *testing.T, commonName string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("鍵の生成に失敗しました: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("証明書の生成に失敗しました: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestHandler_RevokedToken(t *testing.T) {
+	denyList := auth.NewDenyList()
+	denyList.RevokeSubject("disabled-user")
+	validator := auth.NewRevocationValidator(fakeValidator{tokens: map[string]jwt.MapClaims{
+		"valid-token":   {"sub": "user-123"},
+		"revoked-token": {"sub": "disabled-user"},
+	}}, denyList)
+	setupHandler(t, &auth.BearerAuthenticator{Validator: validator}, nil)
+
+	resp, err := Handler(context.Background(), makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer revoked-token"))
+	if err != nil {
+		t.Fatalf("Handler() error = %v, want nil", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("Handler() StatusCode = %d, want 401", resp.StatusCode)
+	}
+	if got := resp.Headers["Www-Authenticate"]; !strings.Contains(got, `error="invalid_token"`) {
+		t.Errorf("WWW-Authenticate = %q, want error=\"invalid_token\"", got)
+	}
+
+	resp, err = Handler(context.Background(), makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer valid-token"))
+	if err != nil || resp.StatusCode != 200 {
+		t.Errorf("Handler() = %d, %v, want 200", resp.StatusCode, err)
+	}
+}
+
+func TestHandler_ValidatorUnavailable(t *testing.T) {
+	validator := auth.NewLazyValidator(func(context.Context) (auth.TokenValidator, error) {
+		return nil, errors.New("JWKS の取得に失敗しました")
+	})
+	setupHandler(t, &auth.BearerAuthenticator{Validator: validator}, nil)
+
+	resp, err := Handler(context.Background(), makeRequest(router.ACCOUNT_SERVICE_PATH, "GET", "Bearer valid-token"))
+	if err != nil {
+		t.Fatalf("Handler() error = %v, want nil", err)
+	}
+	if resp.StatusCode != 503 {
+		t.Errorf("Handler() StatusCode = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestLambdaHandler_EventSource(t *testing.T) {
+	for _, source := range []string{"", "auto", "apigw_v2", "apigw_v1", "alb", "function_url", "function_url_stream"} {
+		t.Setenv("LAMBDA_EVENT_SOURCE", source)
+		if h, err := lambdaHandler(); err != nil || h == nil {
+			t.Errorf("lambdaHandler(%q) = %v, %v, want handler", source, h, err)
+		}
+	}
+
+	t.Setenv("LAMBDA_EVENT_SOURCE", "sqs")
+	if _, err := lambdaHandler(); err == nil {
+		t.Error("lambdaHandler(sqs) error = nil, want error")
+	}
+}
+
+func TestHandler_RouteLimits(t *testing.T) {
+	bearer := &auth.BearerAuthenticator{Validator: fakeValidator{tokens: map[string]jwt.MapClaims{
+		"valid-token": {"sub": "user-123"},
+	}}}
+	routes := []router.Route{{
+		Path: "/api/customers/account", Methods: []string{"GET", "POST"}, Upstream: "https://account.internal",
+		Limits: &router.Limits{MaxBodyBytes: 16, ContentTypes: []string{"application/json"}, RejectBodyMethods: []string{"GET"}},
+	}}
+	captured := setupRoutedHandler(t, bearer, nil, routes, nil)
+
+	request := func(method, contentType, body string) events.APIGatewayV2HTTPRequest {
+		r := makeRequest("/api/customers/account", method, "Bearer valid-token")
+		if contentType != "" {
+			r.Headers["content-type"] = contentType
+		}
+		r.Body = body
+		return r
+	}
+	large := request("POST", "application/json", `{"name":"`+strings.Repeat("a", 16)+`"}`)
+	large.Headers["authorization"] = "Bearer unknown-token"
+
+	tests := []struct {
+		name           string
+		request        events.APIGatewayV2HTTPRequest
+		wantStatusCode int
+	}{
+		{name: "正常系: 制限内の JSON", request: request("POST", "application/json", `{"a":1}`), wantStatusCode: 200},
+		{name: "異常系: 上限を超えるボディは認証より前に 413", request: large, wantStatusCode: 413},
+		{name: "異常系: 許可されていない Content-Type", request: request("POST", "text/plain", "a"), wantStatusCode: 415},
+		{name: "異常系: ボディのある GET", request: request("GET", "application/json", "{}"), wantStatusCode: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured.called = false
+			resp, err := Handler(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("Handler() error = %v, want nil", err)
+			}
+			if resp.StatusCode != tt.wantStatusCode {
+				t.Errorf("Handler() StatusCode = %d, want %d", resp.StatusCode, tt.wantStatusCode)
+			}
+			if captured.called != (tt.wantStatusCode == 200) {
+				t.Errorf("proxy called = %v", captured.called)
+			}
+		})
+	}
+}
+
+func TestHandler_SchemaValidation(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"routes.json": `{"routes": [{"path": "/api/payments/transfer", "methods": ["POST"], "upstream": "https://payments.internal",
+			"validation": {"body_schema": "transfer.json"}}]}`,
+		"transfer.json": `{"type": "object", "required": ["amount", "to"], "properties": {"amount": {"type": "integer", "minimum": 1}}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	routes, err := router.LoadRoutes(dir)
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	bearer := &auth.BearerAuthenticator{Validator: fakeValidator{tokens: map[string]jwt.MapClaims{
+		"valid-token": {"sub": "user-123"},
+	}}}
+	captured := setupRoutedHandler(t, bearer, nil, routes, nil)
+
+	request := makeRequest("/api/payments/transfer", "POST", "Bearer valid-token")
+	request.Body = `{"amount": 0}`
+	resp, err := Handler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Handler() error = %v, want nil", err)
+	}
+	if resp.StatusCode != 400 || resp.Headers["Content-Type"] != "application/problem+json" || captured.called {
+		t.Fatalf("Handler() = %d %v, proxy called = %v, want 400 problem+json", resp.StatusCode, resp.Headers, captured.called)
+	}
+	var problem struct {
+		Status int `json:"status"`
+		Errors []struct {
+			In      string `json:"in"`
+			Pointer string `json:"pointer"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &problem); err != nil {
+		t.Fatalf("problem+json の解析に失敗しました: %v", err)
+	}
+	pointers := map[string]bool{}
+	for _, e := range problem.Errors {
+		pointers[e.In+":"+e.Pointer] = true
+	}
+	if problem.Status != 400 || !pointers["body:"] || !pointers["body:/amount"] {
+		t.Errorf("problem = %+v, want body の / と /amount の違反", problem)
+	}
+
+	request.Body = `{"amount": 100, "to": "1234567"}`
+	if resp, _ := Handler(context.Background(), request); resp.StatusCode != 200 || !captured.called {
+		t.Errorf("適合するリクエストの StatusCode = %d, proxy called = %v", resp.StatusCode, captured.called)
+	}
+}
+
+func TestHandler_OpenAPIScopes(t *testing.T) {
+	dir := t.TempDir()
+	spec := `
+openapi: 3.0.3
+info: {title: accounts, version: "1.0"}
+components:
+  securitySchemes:
+    auth0: {type: http, scheme: bearer}
+paths:
+  /api/accounts/{accountId}:
+    x-gateway-upstream: https://account.internal
+    get:
+      parameters:
+        - {name: accountId, in: path, required: true, schema: {type: string, pattern: "^[0-9]{7}$"}}
+      security:
+        - auth0: [read:accounts]
+      responses: {"200": {description: 口座}}
+`
+	if err := os.WriteFile(filepath.Join(dir, "openapi.yaml"), []byte(spec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := router.LoadRoutes(dir)
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	bearer := &auth.BearerAuthenticator{Validator: fakeValidator{tokens: map[string]jwt.MapClaims{
+		"reader-token": {"sub": "user-123", "scope": "read:accounts"},
+		"other-token":  {"sub": "user-456", "scope": "read:profile"},
+	}}}
+	captured := setupRoutedHandler(t, bearer, nil, routes, nil)
+
+	tests := []struct {
+		name           string
+		path           string
+		authorization  string
+		wantStatusCode int
+	}{
+		{name: "正常系: スコープを持つ", path: "/api/accounts/1234567", authorization: "Bearer reader-token", wantStatusCode: 200},
+		{name: "異常系: スコープが足りない", path: "/api/accounts/1234567", authorization: "Bearer other-token", wantStatusCode: 403},
+		{name: "異常系: パスパラメータの違反", path: "/api/accounts/abc", authorization: "Bearer reader-token", wantStatusCode: 400},
+		{name: "異常系: 定義にないパス", path: "/api/accounts", authorization: "Bearer reader-token", wantStatusCode: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured.called = false
+			resp, err := Handler(context.Background(), makeRequest(tt.path, "GET", tt.authorization))
+			if err != nil {
+				t.Fatalf("Handler() error = %v, want nil", err)
+			}
+			if resp.StatusCode != tt.wantStatusCode {
+				t.Errorf("Handler() StatusCode = %d, want %d: %s", resp.StatusCode, tt.wantStatusCode, resp.Body)
+			}
+			if captured.called != (tt.wantStatusCode == 200) {
+				t.Errorf("proxy called = %v", captured.called)
+			}
+			if captured.called && captured.targetURL != "https://account.internal" {
+				t.Errorf("targetURL = %q, want x-gateway-upstream の値", captured.targetURL)
+			}
+			if tt.wantStatusCode == 403 && resp.Headers["Www-Authenticate"] != `Bearer error="insufficient_scope", scope="read:accounts"` {
+				t.Errorf("WWW-Authenticate = %q", resp.Headers["Www-Authenticate"])
+			}
+		})
+	}
+}
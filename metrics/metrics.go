@@ -0,0 +1,68 @@
+// Package metrics は CloudWatch Embedded Metric Format (EMF) でメトリクスを出力する。
+// Lambda の標準出力に書き出したログを CloudWatch Logs がメトリクスとして取り込むため、SDK や API 呼び出しは不要。
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CloudWatch のメトリクスの単位
+const (
+	UnitCount        = "Count"
+	UnitMilliseconds = "Milliseconds"
+	UnitNone         = "None"
+)
+
+// METRICS_NAMESPACE が未設定の場合の名前空間
+const defaultNamespace = "GoGateway"
+
+var (
+	mu     sync.Mutex
+	output io.Writer = os.Stdout
+	now              = time.Now
+)
+
+// Emit はメトリクスを 1 件、EMF の JSON 1 行として出力する。dimensions はメトリクスのディメンションになる
+func Emit(name string, value float64, unit string, dimensions map[string]string) {
+	namespace := os.Getenv("METRICS_NAMESPACE")
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	keys := make([]string, 0, len(dimensions))
+	for k := range dimensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	doc := map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": now().UnixMilli(),
+			"CloudWatchMetrics": []map[string]interface{}{{
+				"Namespace":  namespace,
+				"Dimensions": [][]string{keys},
+				"Metrics":    []map[string]string{{"Name": name, "Unit": unit}},
+			}},
+		},
+	}
+	for k, v := range dimensions {
+		doc[k] = v
+	}
+	doc[name] = value
+
+	line, err := json.Marshal(doc)
+	if err != nil {
+		log.Printf("メトリクスの出力に失敗しました (%s): %v", name, err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	_, _ = output.Write(append(line, '\n'))
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEmit(t *testing.T) {
+	var buf bytes.Buffer
+	origOutput, origNow := output, now
+	t.Cleanup(func() { output, now = origOutput, origNow })
+	output = &buf
+	now = func() time.Time { return time.UnixMilli(1760000000123) }
+	t.Setenv("METRICS_NAMESPACE", "TestGateway")
+
+	Emit("JWKSHealthy", 1, UnitNone, map[string]string{"Issuer": "https://idp.example.com/", "Stage": "dev"})
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("出力が JSON ではありません: %v (%s)", err, buf.String())
+	}
+	want := map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": float64(1760000000123),
+			"CloudWatchMetrics": []interface{}{map[string]interface{}{
+				"Namespace":  "TestGateway",
+				"Dimensions": []interface{}{[]interface{}{"Issuer", "Stage"}},
+				"Metrics":    []interface{}{map[string]interface{}{"Name": "JWKSHealthy", "Unit": "None"}},
+			}},
+		},
+		"Issuer":      "https://idp.example.com/",
+		"Stage":       "dev",
+		"JWKSHealthy": float64(1),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Emit() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,294 @@
+// Package openapi は OpenAPI 3 の定義からゲートウェイのルートを導出し、定義に沿ってリクエストとレスポンスを検証する。
+//
+// ゲートウェイ固有の設定は拡張プロパティで指定する。
+//   - x-gateway-upstream: 転送先のバックエンドの URL。"${ACCOUNT_SERVICE_URL}" の形式ではリクエストごとに環境変数から読み取る。
+//     operation、パス、ドキュメントの順に探す
+//   - x-gateway-auth: operation で受け付ける認証方式 (ルーティング設定の auth と同じ)
+//   - x-gateway-masking: operation のレスポンスの値をマスクする規則 (ルーティング設定の masking と同じ)
+//   - x-gateway-transform: operation のリクエストとレスポンスのボディの変換 (ルーティング設定の transform と同じ)
+//   - x-gateway-response-validation: レスポンスを responses の定義で検証するモード (report / enforce / filter)。
+//     operation、パス、ドキュメントの順に探す
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+
+	"github.com/aki80204/go-gateway/schema"
+)
+
+const (
+	extUpstream  = "x-gateway-upstream"
+	extAuth      = "x-gateway-auth"
+	extMasking   = "x-gateway-masking"
+	extTransform = "x-gateway-transform"
+
+	extResponseValidation = "x-gateway-response-validation"
+)
+
+// ${NAME} 形式の環境変数の参照
+var upstreamEnvPattern = regexp.MustCompile(`^\$\{(\w+)\}$`)
+
+// Operation は OpenAPI の operation (パスと HTTP メソッドの組) 1 件
+type Operation struct {
+	// Path は OpenAPI のパス。{id} のようなパスパラメータを含む
+	Path   string
+	Method string
+	// Upstream と UpstreamEnv は x-gateway-upstream の値
+	Upstream    string
+	UpstreamEnv string
+	Auth        []string
+	// Scopes は security から導いた必要なスコープ。いずれかの組のスコープをすべて持つ場合に許可する。空の場合は制限しない
+	Scopes [][]string
+	// ResponseValidation は x-gateway-response-validation の値
+	ResponseValidation string
+	// Masking と Transform は x-gateway-masking と x-gateway-transform の値を JSON に変換したもの
+	Masking   json.RawMessage
+	Transform json.RawMessage
+	// Validator は operation のパラメータとボディ、レスポンスを検証する
+	Validator *Validator
+}
+
+// Load は OpenAPI 3 の定義ファイル (YAML / JSON) を読み込み、operation の一覧を返す。
+// $ref で参照できるのはローカルのファイルだけで、http(s) の URL を参照する定義はエラーにする。
+// パスは具体的なものから順に並べるため、先頭から照合すればよい
+func Load(path string) ([]Operation, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	// $ref はファイルからの相対パスだけを許可し、起動時にネットワークから定義を取得しない。
+	// 既定の読み込みはプロセス全体でファイルをキャッシュするため、定義を読み直せるよう読み込みごとにキャッシュする
+	loader.ReadFromURIFunc = openapi3.URIMapCache(openapi3.ReadFromFile)
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAPI 定義の読み込みに失敗しました (%s): %w", path, err)
+	}
+	return operations(loader.Context, doc, path)
+}
+
+// LoadData は OpenAPI 3 の定義を data から読み込む
+func LoadData(data []byte) ([]Operation, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(data)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAPI 定義の読み込みに失敗しました: %w", err)
+	}
+	return operations(loader.Context, doc, "")
+}
+
+func operations(ctx context.Context, doc *openapi3.T, location string) ([]Operation, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return nil, fmt.Errorf("OpenAPI 定義が不正です (%s): %w", location, err)
+	}
+
+	var ops []Operation
+	for _, path := range doc.Paths.InMatchingOrder() {
+		item := doc.Paths.Value(path)
+		methods := make([]string, 0, len(item.Operations()))
+		for method := range item.Operations() {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			operation := item.GetOperation(method)
+			op := Operation{
+				Path:   path,
+				Method: method,
+				Validator: &Validator{route: &routers.Route{
+					Spec: doc, Path: path, PathItem: item, Method: method, Operation: operation,
+				}},
+			}
+
+			upstream, err := firstString(extUpstream, operation.Extensions, item.Extensions, doc.Extensions)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			if m := upstreamEnvPattern.FindStringSubmatch(upstream); m != nil {
+				op.UpstreamEnv = m[1]
+			} else {
+				op.Upstream = upstream
+			}
+
+			op.ResponseValidation, err = firstString(extResponseValidation, operation.Extensions, item.Extensions, doc.Extensions)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+
+			if op.Auth, err = stringList(extAuth, operation.Extensions[extAuth]); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			if op.Masking, err = rawExtension(extMasking, operation.Extensions); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			if op.Transform, err = rawExtension(extTransform, operation.Extensions); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+
+			security := operation.Security
+			if security == nil {
+				security = &doc.Security
+			}
+			op.Scopes = requiredScopes(*security)
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// security の要件をスコープの組に変換する。スコープのない要件を含む場合は制限しない
+func requiredScopes(security openapi3.SecurityRequirements) [][]string {
+	var alternatives [][]string
+	for _, requirement := range security {
+		var scopes []string
+		for _, s := range requirement {
+			scopes = append(scopes, s...)
+		}
+		if len(scopes) == 0 {
+			return nil
+		}
+		sort.Strings(scopes)
+		alternatives = append(alternatives, scopes)
+	}
+	return alternatives
+}
+
+// extensions の順に name の文字列を探す
+func firstString(name string, extensions ...map[string]any) (string, error) {
+	for _, ext := range extensions {
+		v, ok := ext[name]
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("%s は文字列で指定してください", name)
+		}
+		return s, nil
+	}
+	return "", nil
+}
+
+// extensions の name の値を JSON に変換する。ない場合は nil を返す
+func rawExtension(name string, extensions map[string]any) (json.RawMessage, error) {
+	v, ok := extensions[name]
+	if !ok {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s の形式が不正です: %w", name, err)
+	}
+	return data, nil
+}
+
+func stringList(name string, v any) ([]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s は文字列の配列で指定してください", name)
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s は文字列の配列で指定してください", name)
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// Validator は operation の定義でパラメータとボディ、レスポンスを検証する
+type Validator struct {
+	route *routers.Route
+}
+
+// 認証はゲートウェイの認証方式で行い、security はスコープの確認にだけ使う
+var validationOptions = &openapi3filter.Options{
+	MultiError:         true,
+	AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+}
+
+// ValidateRequest はパスパラメータ、クエリパラメータ、ヘッダー、ボディを検証し、違反した箇所を返す。
+// ボディは読み込んだ後に r.Body へ戻される
+func (v *Validator) ValidateRequest(r *http.Request, pathParams map[string]string) []schema.Violation {
+	err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      v.route,
+		Options:    validationOptions,
+	})
+	if err == nil {
+		return nil
+	}
+	return violations(err)
+}
+
+// kin-openapi のエラーを Violation に変換する
+func violations(err error) []schema.Violation {
+	// RequestError も内側の MultiError に Unwrap されるため、errors.As ではなく型で判定する
+	if multi, ok := err.(openapi3.MultiError); ok {
+		var result []schema.Violation
+		for _, e := range multi {
+			result = append(result, violations(e)...)
+		}
+		return result
+	}
+
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return []schema.Violation{{In: schema.InBody, Pointer: "", Detail: err.Error()}}
+	}
+
+	in, prefix := schema.InBody, ""
+	if p := requestErr.Parameter; p != nil {
+		in, prefix = p.In, "/"+escapePointer(p.Name)
+	}
+
+	if requestErr.Err != nil {
+		var inner openapi3.MultiError
+		if errors.As(requestErr.Err, &inner) {
+			var result []schema.Violation
+			for _, e := range inner {
+				result = append(result, schemaViolation(in, prefix, e, requestErr.Reason))
+			}
+			return result
+		}
+	}
+	return []schema.Violation{schemaViolation(in, prefix, requestErr.Err, requestErr.Reason)}
+}
+
+func schemaViolation(in, prefix string, err error, reason string) schema.Violation {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		pointer := prefix
+		for _, token := range schemaErr.JSONPointer() {
+			pointer += "/" + escapePointer(token)
+		}
+		return schema.Violation{In: in, Pointer: pointer, Detail: schemaErr.Reason}
+	}
+	if err != nil && reason == "" {
+		reason = err.Error()
+	}
+	return schema.Violation{In: in, Pointer: prefix, Detail: reason}
+}
+
+// JSON Pointer (RFC 6901) のトークンをエスケープする
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
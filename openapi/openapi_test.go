@@ -0,0 +1,300 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testSpec = `
+openapi: 3.0.3
+info: {title: payments, version: "1.0"}
+x-gateway-upstream: https://payments.internal
+security:
+  - auth0: [read:accounts]
+components:
+  securitySchemes:
+    auth0:
+      type: oauth2
+      flows:
+        clientCredentials:
+          tokenUrl: https://tenant.auth0.com/oauth/token
+          scopes: {read:accounts: 口座の参照, write:transfers: 振込の登録}
+paths:
+  /api/accounts/{accountId}:
+    x-gateway-upstream: ${ACCOUNT_SERVICE_URL}
+    parameters:
+      - {name: accountId, in: path, required: true, schema: {type: string, pattern: "^[0-9]{7}$"}}
+    get:
+      parameters:
+        - {name: fields, in: query, schema: {type: string, enum: [summary, detail]}}
+      responses: {"200": {description: 口座}}
+  /api/transfers:
+    post:
+      x-gateway-auth: [jwt+mtls]
+      security:
+        - auth0: [write:transfers]
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [amount]
+              properties:
+                amount: {type: integer, minimum: 1}
+      responses: {"201": {description: 登録済み}}
+  /api/health:
+    get:
+      security: []
+      responses: {"200": {description: 正常}}
+`
+
+func TestLoadData(t *testing.T) {
+	ops, err := LoadData([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("LoadData() error = %v", err)
+	}
+	byKey := map[string]Operation{}
+	for _, op := range ops {
+		byKey[op.Method+" "+op.Path] = op
+	}
+	if len(byKey) != 3 {
+		t.Fatalf("LoadData() = %d 件, want 3", len(byKey))
+	}
+
+	account := byKey["GET /api/accounts/{accountId}"]
+	if account.UpstreamEnv != "ACCOUNT_SERVICE_URL" || account.Upstream != "" {
+		t.Errorf("パスの x-gateway-upstream = %q / %q", account.Upstream, account.UpstreamEnv)
+	}
+	if len(account.Scopes) != 1 || account.Scopes[0][0] != "read:accounts" {
+		t.Errorf("ドキュメントの security から導いた Scopes = %v", account.Scopes)
+	}
+
+	transfer := byKey["POST /api/transfers"]
+	if transfer.Upstream != "https://payments.internal" {
+		t.Errorf("ドキュメントの x-gateway-upstream = %q", transfer.Upstream)
+	}
+	if len(transfer.Auth) != 1 || transfer.Auth[0] != "jwt+mtls" {
+		t.Errorf("x-gateway-auth = %v", transfer.Auth)
+	}
+	if len(transfer.Scopes) != 1 || transfer.Scopes[0][0] != "write:transfers" {
+		t.Errorf("operation の security から導いた Scopes = %v", transfer.Scopes)
+	}
+
+	if health := byKey["GET /api/health"]; health.Scopes != nil {
+		t.Errorf("security: [] の Scopes = %v, want nil", health.Scopes)
+	}
+}
+
+func TestLoadData_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{name: "YAML が壊れている", spec: "openapi: ["},
+		{name: "paths の定義が不正", spec: `{"openapi": "3.0.3", "info": {"title": "x", "version": "1"}, "paths": {"/a": {"get": {}}}}`},
+		{name: "x-gateway-auth が配列でない", spec: `{"openapi": "3.0.3", "info": {"title": "x", "version": "1"},
+			"paths": {"/a": {"get": {"x-gateway-auth": "jwt", "responses": {"200": {"description": "ok"}}}}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadData([]byte(tt.spec)); err == nil {
+				t.Error("LoadData() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestLoad_Refs(t *testing.T) {
+	var fetched atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Add(1)
+		w.Write([]byte(`{"type": "object"}`))
+	}))
+	defer server.Close()
+
+	const specTemplate = `
+openapi: 3.0.3
+info: {title: payments, version: "1.0"}
+x-gateway-upstream: https://payments.internal
+paths:
+  /api/transfers:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "%s"}
+      responses: {"201": {description: 登録済み}}
+`
+	tests := []struct {
+		name    string
+		ref     string
+		wantErr bool
+	}{
+		{name: "ファイルからの相対パス", ref: "schemas/transfer.json"},
+		{name: "エラー: リモートの URL", ref: server.URL + "/transfer.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(dir, "schemas"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "schemas", "transfer.json"), []byte(`{"type": "object"}`), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "openapi.yaml")
+			if err := os.WriteFile(path, []byte(strings.Replace(specTemplate, "%s", tt.ref, 1)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n := fetched.Load(); n != 0 {
+				t.Errorf("リモートの $ref を %d 回取得した, want 0", n)
+			}
+		})
+	}
+}
+
+func TestValidator_ValidateRequest(t *testing.T) {
+	ops, err := LoadData([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("LoadData() error = %v", err)
+	}
+	validators := map[string]*Validator{}
+	for _, op := range ops {
+		validators[op.Method+" "+op.Path] = op.Validator
+	}
+
+	tests := []struct {
+		name        string
+		operation   string
+		target      string
+		body        string
+		pathParams  map[string]string
+		wantPointer []string
+	}{
+		{
+			name: "正常系: パスとクエリが適合する", operation: "GET /api/accounts/{accountId}",
+			target: "/api/accounts/1234567?fields=summary", pathParams: map[string]string{"accountId": "1234567"},
+		},
+		{
+			name: "異常系: パスとクエリの違反", operation: "GET /api/accounts/{accountId}",
+			target: "/api/accounts/abc?fields=all", pathParams: map[string]string{"accountId": "abc"},
+			wantPointer: []string{"path:/accountId", "query:/fields"},
+		},
+		{name: "正常系: ボディが適合する", operation: "POST /api/transfers", target: "/api/transfers", body: `{"amount": 100}`},
+		{
+			name: "異常系: ボディの違反", operation: "POST /api/transfers", target: "/api/transfers", body: `{"amount": 0}`,
+			wantPointer: []string{"body:/amount"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := strings.Fields(tt.operation)[0]
+			r := httptest.NewRequest(method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			violations := validators[tt.operation].ValidateRequest(r, tt.pathParams)
+
+			got := map[string]bool{}
+			for _, v := range violations {
+				got[v.In+":"+v.Pointer] = true
+			}
+			if len(violations) != len(tt.wantPointer) {
+				t.Fatalf("ValidateRequest() = %+v, want %v", violations, tt.wantPointer)
+			}
+			for _, want := range tt.wantPointer {
+				if !got[want] {
+					t.Errorf("ValidateRequest() = %+v, want %s", violations, want)
+				}
+			}
+		})
+	}
+}
+
+const responseSpec = `
+openapi: 3.0.3
+info: {title: accounts, version: "1.0"}
+x-gateway-response-validation: report
+paths:
+  /api/accounts/{accountId}:
+    get:
+      x-gateway-response-validation: filter
+      parameters:
+        - {name: accountId, in: path, required: true, schema: {type: string}}
+      responses:
+        "200":
+          description: 口座
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [accountId]
+                properties:
+                  accountId: {type: string}
+                  owner:
+                    type: object
+                    properties:
+                      name: {type: string}
+        "404":
+          description: 口座がない
+`
+
+func TestValidator_Response(t *testing.T) {
+	ops, err := LoadData([]byte(responseSpec))
+	if err != nil {
+		t.Fatalf("LoadData() error = %v", err)
+	}
+	op := ops[0]
+	if op.ResponseValidation != "filter" {
+		t.Errorf("ResponseValidation = %q, want operation の x-gateway-response-validation", op.ResponseValidation)
+	}
+
+	header := map[string][]string{"Content-Type": {"application/json"}}
+	r := httptest.NewRequest("GET", "/api/accounts/1234567", nil)
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantPointer []string
+	}{
+		{name: "正常系: 定義に適合する", status: 200, body: `{"accountId": "1234567"}`},
+		{name: "異常系: 型の違反", status: 200, body: `{"accountId": 1234567}`, wantPointer: []string{"body:/accountId"}},
+		{name: "異常系: 定義にないステータス", status: 500, body: `{}`, wantPointer: []string{"response:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := op.Validator.ValidateResponse(r, tt.status, header, []byte(tt.body))
+			if len(violations) != len(tt.wantPointer) {
+				t.Fatalf("ValidateResponse() = %+v, want %v", violations, tt.wantPointer)
+			}
+			for i, want := range tt.wantPointer {
+				if got := violations[i].In + ":" + violations[i].Pointer; got != want {
+					t.Errorf("ValidateResponse() = %+v, want %s", violations, want)
+				}
+			}
+		})
+	}
+
+	body := map[string]any{"accountId": "1234567", "internal": true, "owner": map[string]any{"name": "山田", "nationalId": "x"}}
+	filtered := op.Validator.FilterResponse(200, "application/json; charset=utf-8", body).(map[string]any)
+	if _, ok := filtered["internal"]; ok {
+		t.Errorf("FilterResponse() = %v, want internal を取り除く", filtered)
+	}
+	if owner := filtered["owner"].(map[string]any); len(owner) != 1 || owner["name"] != "山田" {
+		t.Errorf("FilterResponse() owner = %v, want name だけ残す", owner)
+	}
+	if got := op.Validator.FilterResponse(404, "application/json", map[string]any{"error": "x"}).(map[string]any); len(got) != 1 {
+		t.Errorf("スキーマのないレスポンスの FilterResponse() = %v, want そのまま", got)
+	}
+}
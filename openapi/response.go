@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+
+	"github.com/aki80204/go-gateway/schema"
+)
+
+// 定義にないステータスコードのレスポンスも違反とする
+var responseValidationOptions = &openapi3filter.Options{
+	MultiError:            true,
+	IncludeResponseStatus: true,
+	AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+}
+
+// ValidateResponse はバックエンドのレスポンスを operation の responses の定義で検証し、違反した箇所を返す
+func (v *Validator) ValidateResponse(r *http.Request, status int, header http.Header, body []byte) []schema.Violation {
+	err := openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{Request: r, Route: v.route, Options: responseValidationOptions},
+		Status:                 status,
+		Header:                 header,
+		Body:                   io.NopCloser(bytes.NewReader(body)),
+		Options:                responseValidationOptions,
+	})
+	if err == nil {
+		return nil
+	}
+	return responseViolations(err)
+}
+
+func responseViolations(err error) []schema.Violation {
+	if multi, ok := err.(openapi3.MultiError); ok {
+		var result []schema.Violation
+		for _, e := range multi {
+			result = append(result, responseViolations(e)...)
+		}
+		return result
+	}
+
+	var responseErr *openapi3filter.ResponseError
+	if !errors.As(err, &responseErr) {
+		return []schema.Violation{{In: schema.InResponse, Pointer: "", Detail: err.Error()}}
+	}
+	if responseErr.Err == nil {
+		return []schema.Violation{{In: schema.InResponse, Pointer: "", Detail: responseErr.Reason}}
+	}
+	var inner openapi3.MultiError
+	if errors.As(responseErr.Err, &inner) {
+		var result []schema.Violation
+		for _, e := range inner {
+			result = append(result, schemaViolation(schema.InBody, "", e, responseErr.Reason))
+		}
+		return result
+	}
+	return []schema.Violation{schemaViolation(schema.InBody, "", responseErr.Err, responseErr.Reason)}
+}
+
+// FilterResponse は body のオブジェクトから、status と contentType に対応するレスポンスのスキーマで宣言されていないプロパティを取り除く。
+// 対応するスキーマがない場合は body をそのまま返す
+func (v *Validator) FilterResponse(status int, contentType string, body any) any {
+	responses := v.route.Operation.Responses
+	if responses == nil {
+		return body
+	}
+	response := responses.Status(status)
+	if response == nil {
+		response = responses.Default()
+	}
+	if response == nil || response.Value == nil {
+		return body
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return body
+	}
+	content := response.Value.Content.Get(mediaType)
+	if content == nil || content.Schema == nil {
+		return body
+	}
+	return filter([]*openapi3.SchemaRef{content.Schema}, body)
+}
+
+// schema.Schema.Filter と同じ規則で、OpenAPI のスキーマで宣言されていないプロパティを取り除く
+func filter(refs []*openapi3.SchemaRef, v any) any {
+	schemas := expand(refs)
+	switch v := v.(type) {
+	case map[string]any:
+		declared, additional := false, false
+		var additionalSchemas []*openapi3.SchemaRef
+		for _, s := range schemas {
+			if len(s.Properties) > 0 || s.AdditionalProperties.Has != nil || s.AdditionalProperties.Schema != nil {
+				declared = true
+			}
+			if has := s.AdditionalProperties.Has; has != nil && *has {
+				additional = true
+			}
+			if a := s.AdditionalProperties.Schema; a != nil {
+				additional = true
+				additionalSchemas = append(additionalSchemas, a)
+			}
+		}
+		for name, value := range v {
+			var props []*openapi3.SchemaRef
+			for _, s := range schemas {
+				if p, ok := s.Properties[name]; ok {
+					props = append(props, p)
+				}
+			}
+			if len(props) == 0 {
+				props = additionalSchemas
+			}
+			if len(props) == 0 {
+				if declared && !additional {
+					delete(v, name)
+				}
+				continue
+			}
+			v[name] = filter(props, value)
+		}
+	case []any:
+		var items []*openapi3.SchemaRef
+		for _, s := range schemas {
+			if s.Items != nil {
+				items = append(items, s.Items)
+			}
+		}
+		if len(items) > 0 {
+			for i, item := range v {
+				v[i] = filter(items, item)
+			}
+		}
+	}
+	return v
+}
+
+// allOf / anyOf / oneOf をたどり、値に適用されうるスキーマを列挙する
+func expand(refs []*openapi3.SchemaRef) []*openapi3.Schema {
+	var result []*openapi3.Schema
+	seen := make(map[*openapi3.Schema]bool)
+	var walk func(ref *openapi3.SchemaRef)
+	walk = func(ref *openapi3.SchemaRef) {
+		if ref == nil || ref.Value == nil || seen[ref.Value] {
+			return
+		}
+		s := ref.Value
+		seen[s] = true
+		result = append(result, s)
+		for _, list := range []openapi3.SchemaRefs{s.AllOf, s.AnyOf, s.OneOf} {
+			for _, sub := range list {
+				walk(sub)
+			}
+		}
+	}
+	for _, ref := range refs {
+		walk(ref)
+	}
+	return result
+}
@@ -1,49 +1,91 @@
 package proxy
 
 import (
-	"io"
+	"log"
 	"net/http"
-	"strings"
+	"net/http/httputil"
+	"net/url"
 	"time"
 
+	"github.com/aki80204/go-gateway/auth"
 	"github.com/aki80204/go-gateway/utils"
-	"github.com/aws/aws-lambda-go/events"
 )
 
-func ProxyRequest(request events.APIGatewayV2HTTPRequest, targetBaseURL string, sub string) (events.APIGatewayProxyResponse, error) {
-	if targetBaseURL == "" {
-		return utils.ErrorResponse(500, "Backend service URL not configured"), nil
-	}
+// バックエンドがレスポンスヘッダーを返すまでの待ち時間。ボディはストリーミングするため全体の時間は制限しない
+const responseHeaderTimeout = 60 * time.Second
+
+// ゲートウェイが設定する認証情報のヘッダー。クライアントからの同名ヘッダーは転送しない
+var authHeaders = []string{
+	"X-Auth-User-ID",
+	"X-Auth-Client-Cert-Identity",
+	"X-Auth-Client-Cert-Subject",
+	"X-Auth-Client-Cert-Issuer",
+	"X-Auth-Client-Cert-Serial",
+}
+
+// API Gateway や ALB が付与した値をそのまま転送するヘッダー。
+// httputil.ReverseProxy は既定で X-Forwarded-* を取り除くため、Rewrite で戻す
+var forwardedHeaders = []string{
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Port",
+	"X-Forwarded-Proto",
+}
+
+var transport http.RoundTripper = newTransport()
 
-	// リクエストの組み立て
-	targetURL := targetBaseURL + request.RawPath
-	if request.RawQueryString != "" {
-		targetURL += "?" + request.RawQueryString
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = responseHeaderTimeout
+	return t
+}
+
+// ProxyRequest はリクエストを targetBaseURL のバックエンドへ転送し、レスポンスをストリーミングで w に書き込む。
+// パスとクエリは targetBaseURL に連結し、認証済みの principal を X-Auth-* ヘッダーで付与する。
+func ProxyRequest(w http.ResponseWriter, r *http.Request, targetBaseURL string, principal *auth.Principal) {
+	if targetBaseURL == "" {
+		utils.WriteError(w, http.StatusInternalServerError, "Backend service URL not configured")
+		return
 	}
-	req, err := http.NewRequest(request.RequestContext.HTTP.Method, targetURL, strings.NewReader(request.Body))
+	target, err := url.Parse(targetBaseURL)
 	if err != nil {
-		return utils.ErrorResponse(500, "Internal Proxy Error"), nil
+		log.Printf("バックエンドの URL が不正です: %v", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Proxy Error")
+		return
 	}
 
-	// ヘッダーの移送と認証情報の付与
-	for k, v := range request.Headers {
-		req.Header.Set(k, v)
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			for _, h := range forwardedHeaders {
+				if v, ok := pr.In.Header[h]; ok {
+					pr.Out.Header[h] = v
+				}
+			}
+			setAuthHeaders(pr.Out.Header, principal)
+		},
+		Transport: transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("バックエンドへの転送に失敗しました: %v", err)
+			utils.WriteError(w, http.StatusBadGateway, "Bad Gateway")
+		},
 	}
-	req.Header.Set("X-Auth-User-ID", sub)
+	rp.ServeHTTP(w, r)
+}
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return utils.ErrorResponse(502, "Bad Gateway"), nil
+// クライアントが送ってきた認証情報のヘッダーを取り除き、principal の値を設定する
+func setAuthHeaders(h http.Header, principal *auth.Principal) {
+	for _, name := range authHeaders {
+		h.Del(name)
+	}
+	if principal == nil {
+		return
+	}
+	h.Set("X-Auth-User-ID", principal.Subject)
+	if cert := principal.ClientCert; cert != nil {
+		h.Set("X-Auth-Client-Cert-Identity", cert.Identity)
+		h.Set("X-Auth-Client-Cert-Subject", cert.SubjectDN)
+		h.Set("X-Auth-Client-Cert-Issuer", cert.IssuerDN)
+		h.Set("X-Auth-Client-Cert-Serial", cert.SerialNumber)
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	body, _ := io.ReadAll(resp.Body)
-	return events.APIGatewayProxyResponse{
-		StatusCode: resp.StatusCode,
-		Headers:    map[string]string{"Content-Type": "application/json"},
-		Body:       string(body),
-	}, nil
 }
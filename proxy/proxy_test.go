@@ -4,39 +4,37 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
-	"github.com/aws/aws-lambda-go/events"
+	"github.com/aki80204/go-gateway/auth"
 )
 
-func makeRequest(path, method, body string, headers map[string]string) events.APIGatewayV2HTTPRequest {
-	if headers == nil {
-		headers = map[string]string{}
-	}
-	return events.APIGatewayV2HTTPRequest{
-		RawPath: path,
-		RequestContext: events.APIGatewayV2HTTPRequestContext{
-			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
-				Method: method,
-			},
-		},
-		Body:    body,
-		Headers: headers,
+func makeRequest(path, method, body string, headers map[string]string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range headers {
+		r.Header.Set(k, v)
 	}
+	return r
+}
+
+// ProxyRequest を呼び出し、書き込まれたレスポンスを返す
+func doProxy(req *http.Request, targetBaseURL string, principal *auth.Principal) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	ProxyRequest(rec, req, targetBaseURL, principal)
+	return rec
 }
 
 func TestProxyRequest_EmptyBaseURL(t *testing.T) {
 	req := makeRequest("/api/test", "GET", "", nil)
-	resp, err := ProxyRequest(req, "", "user-123")
+	resp := doProxy(req, "", &auth.Principal{Subject: "user-123"})
 
-	if err != nil {
-		t.Errorf("ProxyRequest() error = %v, want nil", err)
+	if resp.Code != 500 {
+		t.Errorf("ProxyRequest() StatusCode = %d, want 500", resp.Code)
 	}
-	if resp.StatusCode != 500 {
-		t.Errorf("ProxyRequest() StatusCode = %d, want 500", resp.StatusCode)
-	}
-	if resp.Body != `{"error":"Backend service URL not configured"}` {
-		t.Errorf("ProxyRequest() Body = %q, want error message", resp.Body)
+	if resp.Body.String() != `{"error":"Backend service URL not configured"}` {
+		t.Errorf("ProxyRequest() Body = %q, want error message", resp.Body.String())
 	}
 }
 
@@ -50,16 +48,13 @@ func TestProxyRequest_Success(t *testing.T) {
 	defer server.Close()
 
 	req := makeRequest("/api/customers/account", "GET", "", nil)
-	resp, err := ProxyRequest(req, server.URL, "sub-123")
+	resp := doProxy(req, server.URL, &auth.Principal{Subject: "sub-123"})
 
-	if err != nil {
-		t.Errorf("ProxyRequest() error = %v, want nil", err)
+	if resp.Code != 200 {
+		t.Errorf("ProxyRequest() StatusCode = %d, want 200", resp.Code)
 	}
-	if resp.StatusCode != 200 {
-		t.Errorf("ProxyRequest() StatusCode = %d, want 200", resp.StatusCode)
-	}
-	if resp.Body != `{"id":1,"name":"test"}` {
-		t.Errorf("ProxyRequest() Body = %q, want %q", resp.Body, `{"id":1,"name":"test"}`)
+	if resp.Body.String() != `{"id":1,"name":"test"}` {
+		t.Errorf("ProxyRequest() Body = %q, want %q", resp.Body.String(), `{"id":1,"name":"test"}`)
 	}
 }
 
@@ -72,16 +67,13 @@ func TestProxyRequest_ReturnsBackendStatusCode(t *testing.T) {
 	defer server.Close()
 
 	req := makeRequest("/api/unknown", "GET", "", nil)
-	resp, err := ProxyRequest(req, server.URL, "user-456")
+	resp := doProxy(req, server.URL, &auth.Principal{Subject: "user-456"})
 
-	if err != nil {
-		t.Errorf("ProxyRequest() error = %v, want nil", err)
+	if resp.Code != 404 {
+		t.Errorf("ProxyRequest() StatusCode = %d, want 404", resp.Code)
 	}
-	if resp.StatusCode != 404 {
-		t.Errorf("ProxyRequest() StatusCode = %d, want 404", resp.StatusCode)
-	}
-	if resp.Body != `{"error":"not found"}` {
-		t.Errorf("ProxyRequest() Body = %q", resp.Body)
+	if resp.Body.String() != `{"error":"not found"}` {
+		t.Errorf("ProxyRequest() Body = %q", resp.Body.String())
 	}
 }
 
@@ -105,13 +97,10 @@ func TestProxyRequest_ForwardsHeadersAndSetsXAuthUserID(t *testing.T) {
 		"Content-Type": "application/json",
 		"X-Custom-Header": "custom-value",
 	})
-	resp, err := ProxyRequest(req, server.URL, "auth-user-789")
+	resp := doProxy(req, server.URL, &auth.Principal{Subject: "auth-user-789"})
 
-	if err != nil {
-		t.Errorf("ProxyRequest() error = %v, want nil", err)
-	}
-	if resp.StatusCode != 200 {
-		t.Errorf("ProxyRequest() StatusCode = %d, want 200", resp.StatusCode)
+	if resp.Code != 200 {
+		t.Errorf("ProxyRequest() StatusCode = %d, want 200", resp.Code)
 	}
 	if capturedPath != "/api/customers/account" {
 		t.Errorf("バックエンドへのパス = %q, want /api/customers/account", capturedPath)
@@ -141,11 +130,8 @@ func TestProxyRequest_ForwardsBody(t *testing.T) {
 
 	reqBody := `{"accountId":"acc-123"}`
 	req := makeRequest("/api/customers/account", "POST", reqBody, nil)
-	_, err := ProxyRequest(req, server.URL, "user-1")
+	doProxy(req, server.URL, &auth.Principal{Subject: "user-1"})
 
-	if err != nil {
-		t.Errorf("ProxyRequest() error = %v, want nil", err)
-	}
 	if capturedBody != reqBody {
 		t.Errorf("バックエンドへのBody = %q, want %q", capturedBody, reqBody)
 	}
@@ -156,15 +142,140 @@ func TestProxyRequest_Returns502OnConnectionFailure(t *testing.T) {
 	invalidURL := "http://127.0.0.1:19999"
 	req := makeRequest("/api/test", "GET", "", nil)
 
-	resp, err := ProxyRequest(req, invalidURL, "user-1")
+	resp := doProxy(req, invalidURL, &auth.Principal{Subject: "user-1"})
 
+	if resp.Code != 502 {
+		t.Errorf("ProxyRequest() StatusCode = %d, want 502 (Bad Gateway)", resp.Code)
+	}
+	if resp.Body.String() != `{"error":"Bad Gateway"}` {
+		t.Errorf("ProxyRequest() Body = %q, want Bad Gateway error", resp.Body.String())
+	}
+}
+
+func TestProxyRequest_ForwardsClientCertificate(t *testing.T) {
+	var captured http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	principal := &auth.Principal{Subject: "user-1", ClientCert: &auth.ClientCertificate{
+		Identity: "batch", SubjectDN: "CN=batch,O=Example", IssuerDN: "CN=Example CA", SerialNumber: "4096",
+	}}
+	req := makeRequest("/api/customers/account", "GET", "", map[string]string{"x-auth-client-cert-identity": "spoofed"})
+	doProxy(req, server.URL, principal)
+
+	want := map[string]string{
+		"X-Auth-Client-Cert-Identity": "batch",
+		"X-Auth-Client-Cert-Subject":  "CN=batch,O=Example",
+		"X-Auth-Client-Cert-Issuer":   "CN=Example CA",
+		"X-Auth-Client-Cert-Serial":   "4096",
+	}
+	for k, v := range want {
+		if got := captured.Values(k); len(got) != 1 || got[0] != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestProxyRequest_StripsSpoofedClientCertificate(t *testing.T) {
+	var captured string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = r.Header.Get("X-Auth-Client-Cert-Identity")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req := makeRequest("/api/customers/account", "GET", "", map[string]string{"X-Auth-Client-Cert-Identity": "spoofed"})
+	doProxy(req, server.URL, &auth.Principal{Subject: "user-1"})
+	if captured != "" {
+		t.Errorf("X-Auth-Client-Cert-Identity = %q, want empty", captured)
+	}
+}
+
+func TestProxyRequest_ForwardsQueryAndResponseHeaders(t *testing.T) {
+	var capturedPath, capturedForwardedFor string
+	var capturedQuery url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedPath = r.URL.Path
+		capturedQuery = r.URL.Query()
+		capturedForwardedFor = r.Header.Get("X-Forwarded-For")
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="statement.csv"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("date,amount\n"))
+	}))
+	defer server.Close()
+
+	req := makeRequest("/api/customers/account/export?from=2026-01-01&format=csv", "GET", "", map[string]string{"X-Forwarded-For": "203.0.113.7"})
+	resp := doProxy(req, server.URL+"/v1", &auth.Principal{Subject: "user-1"})
+
+	if capturedPath != "/v1/api/customers/account/export" {
+		t.Errorf("バックエンドへのパス = %q", capturedPath)
+	}
+	if capturedQuery.Get("from") != "2026-01-01" || capturedQuery.Get("format") != "csv" {
+		t.Errorf("バックエンドへのクエリ = %v", capturedQuery)
+	}
+	if capturedForwardedFor != "203.0.113.7" {
+		t.Errorf("X-Forwarded-For = %q, want 203.0.113.7", capturedForwardedFor)
+	}
+	if resp.Header().Get("Content-Type") != "text/csv" || resp.Header().Get("Content-Disposition") == "" {
+		t.Errorf("レスポンスヘッダー = %v, want バックエンドのヘッダー", resp.Header())
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "date,amount\n" {
+		t.Errorf("ProxyRequest() Body = %q", body)
+	}
+}
+
+// 認証に使った API キーはヘッダーでもクエリパラメータでもバックエンドに転送しない
+func TestProxyRequest_DoesNotForwardAPIKey(t *testing.T) {
+	var capturedQuery url.Values
+	var capturedHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedQuery = r.URL.Query()
+		capturedHeader = r.Header
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store, err := auth.NewMemoryAPIKeyStore([]auth.APIKeyRecord{
+		{KeyHash: auth.HashAPIKey("batch-key"), APIKey: auth.APIKey{Identity: "batch-job"}},
+	})
 	if err != nil {
-		t.Errorf("ProxyRequest() error = %v, want nil (always returns nil)", err)
+		t.Fatalf("NewMemoryAPIKeyStore() error = %v", err)
 	}
-	if resp.StatusCode != 502 {
-		t.Errorf("ProxyRequest() StatusCode = %d, want 502 (Bad Gateway)", resp.StatusCode)
+	a := &auth.APIKeyAuthenticator{Store: store, QueryParam: "api_key"}
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+	}{
+		{name: "ヘッダー", path: "/api/customers/balance?from=2026-01-01", headers: map[string]string{"X-API-Key": "batch-key"}},
+		{name: "クエリパラメータ", path: "/api/customers/balance?from=2026-01-01&api_key=batch-key"},
 	}
-	if resp.Body != `{"error":"Bad Gateway"}` {
-		t.Errorf("ProxyRequest() Body = %q, want Bad Gateway error", resp.Body)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := makeRequest(tt.path, "GET", "", tt.headers)
+			principal, err := a.Authenticate(req)
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			resp := doProxy(req, server.URL, principal)
+
+			if resp.Code != http.StatusOK {
+				t.Fatalf("ProxyRequest() StatusCode = %d, want 200", resp.Code)
+			}
+			if capturedQuery.Has("api_key") || capturedQuery.Get("from") != "2026-01-01" {
+				t.Errorf("バックエンドへのクエリ = %v, want api_key を含まない", capturedQuery)
+			}
+			if v := capturedHeader.Get("X-API-Key"); v != "" {
+				t.Errorf("バックエンドへの X-API-Key = %q, want 転送しない", v)
+			}
+			if capturedHeader.Get("X-Auth-User-ID") != "batch-job" {
+				t.Errorf("X-Auth-User-ID = %q, want batch-job", capturedHeader.Get("X-Auth-User-ID"))
+			}
+		})
 	}
 }
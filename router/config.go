@@ -0,0 +1,178 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/openapi"
+	"github.com/aki80204/go-gateway/schema"
+)
+
+// ルーティング設定ディレクトリ内の設定ファイル名
+const routesFileName = "routes.json"
+
+// Route はルーティング設定の 1 件
+type Route struct {
+	// Path はリクエストパスと完全一致で比較する。{id} のようなセグメントは任意の 1 セグメントに一致する
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+	// Upstream はバックエンドの URL。省略した場合は UpstreamEnv の環境変数から読み取る
+	Upstream    string `json:"upstream"`
+	UpstreamEnv string `json:"upstream_env"`
+	// Auth はこのルートで受け付ける認証方式 (auth.MethodJWT, auth.MethodDPoP, auth.MethodAPIKey, auth.MethodHMAC, auth.MethodMTLS)。
+	// "jwt+mtls" のように + で連結した場合はすべての認証方式を要求する。
+	// 省略した場合はゲートウェイ全体で設定された認証方式をすべて受け付ける
+	Auth []string `json:"auth"`
+	// HMAC は Auth に "hmac" を含むルートの署名検証設定
+	HMAC *auth.HMACConfig `json:"hmac"`
+	// Stream に StreamSSE を指定したルートは Server-Sent Events としてチャンクごとに中継する
+	Stream string `json:"stream"`
+	// Limits はバックエンドへ転送する前に確認するリクエストの制限
+	Limits *Limits `json:"limits"`
+	// Validation はボディとクエリパラメータを検証する JSON Schema
+	Validation *Validation `json:"validation"`
+	// ResponseValidation はバックエンドの JSON レスポンスの検証設定
+	ResponseValidation *ResponseValidation `json:"response_validation"`
+	// Masking はバックエンドの JSON レスポンスの値をマスクする規則
+	Masking []MaskRule `json:"masking"`
+	// Transform はリクエストとバックエンドのレスポンスの JSON ボディの変換
+	Transform *Transform `json:"transform"`
+	// Scopes は必要なスコープ。いずれかの組のスコープをすべて持つ場合に許可する。OpenAPI 定義では security から導く
+	Scopes [][]string `json:"scopes"`
+
+	// openAPI は OpenAPI 定義から導いたルートの operation の検証
+	openAPI *openapi.Validator
+}
+
+type routesFile struct {
+	Routes []Route `json:"routes"`
+}
+
+// LoadRoutes はルーティング設定ディレクトリの routes.json と OpenAPI 定義 (openapi.yaml など) を読み込む。
+// 両方がある場合は routes.json のルートを先に照合する
+//
+//	{"routes": [{"path": "/api/customers/account", "methods": ["GET"], "upstream_env": "ACCOUNT_SERVICE_URL"}]}
+func LoadRoutes(dir string) ([]Route, error) {
+	routes, err := loadRoutesFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	openAPIRoutes, err := loadOpenAPIRoutes(dir)
+	if err != nil {
+		return nil, err
+	}
+	routes = append(routes, openAPIRoutes...)
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("ルーティング設定の読み込みに失敗しました: %s に %s または openapi.yaml がありません", dir, routesFileName)
+	}
+	return routes, nil
+}
+
+// routes.json を読み込む。ファイルがない場合は nil を返す
+func loadRoutesFile(dir string) ([]Route, error) {
+	path := filepath.Join(dir, routesFileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ルーティング設定の読み込みに失敗しました: %w", err)
+	}
+
+	var file routesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("ルーティング設定の形式が不正です (%s): %w", path, err)
+	}
+	if len(file.Routes) == 0 {
+		return nil, fmt.Errorf("ルーティング設定にルートがありません (%s)", path)
+	}
+
+	compiler := schema.NewCompiler(dir)
+	for i := range file.Routes {
+		if err := file.Routes[i].validate(); err != nil {
+			return nil, fmt.Errorf("ルーティング設定が不正です (%s, routes[%d]): %w", path, i, err)
+		}
+		if v := file.Routes[i].Validation; v != nil {
+			if err := v.compile(compiler); err != nil {
+				return nil, fmt.Errorf("ルーティング設定が不正です (%s, routes[%d]): %w", path, i, err)
+			}
+		}
+		if v := file.Routes[i].ResponseValidation; v != nil {
+			if err := v.compile(compiler); err != nil {
+				return nil, fmt.Errorf("ルーティング設定が不正です (%s, routes[%d]): %w", path, i, err)
+			}
+		}
+	}
+	return file.Routes, nil
+}
+
+// LoadRoutesFromEnv は ROUTE_CONFIG_DIR が設定されていればそのルーティング設定を、未設定なら DefaultRoutes を返す
+func LoadRoutesFromEnv() ([]Route, error) {
+	dir := os.Getenv("ROUTE_CONFIG_DIR")
+	if dir == "" {
+		return DefaultRoutes(), nil
+	}
+	return LoadRoutes(dir)
+}
+
+func (route *Route) validate() error {
+	if !strings.HasPrefix(route.Path, "/") {
+		return fmt.Errorf("path は / で始めてください: %q", route.Path)
+	}
+	if len(route.Methods) == 0 {
+		return errors.New("methods は必須です")
+	}
+	for i, m := range route.Methods {
+		route.Methods[i] = strings.ToUpper(m)
+	}
+	if route.Upstream == "" && route.UpstreamEnv == "" {
+		return errors.New("upstream または upstream_env は必須です")
+	}
+	if route.Limits != nil {
+		if err := route.Limits.validate(); err != nil {
+			return err
+		}
+	}
+	if route.ResponseValidation != nil {
+		if err := route.validateResponseValidation(); err != nil {
+			return err
+		}
+	}
+	for i := range route.Masking {
+		if err := route.Masking[i].compile(); err != nil {
+			return err
+		}
+	}
+	if len(route.Masking) > 0 && route.Stream == StreamSSE {
+		return errors.New(`stream: "sse" のルートではレスポンスをマスクできません`)
+	}
+	if route.Transform != nil {
+		if err := route.validateTransform(); err != nil {
+			return err
+		}
+	}
+	for _, scopes := range route.Scopes {
+		if len(scopes) == 0 {
+			return errors.New("scopes の組が空です")
+		}
+	}
+	if route.Stream != "" && route.Stream != StreamSSE {
+		return fmt.Errorf("stream の値が不正です: %q", route.Stream)
+	}
+	for _, a := range route.Auth {
+		for _, m := range strings.Split(a, auth.MethodSeparator) {
+			if m == "" {
+				return fmt.Errorf("auth の形式が不正です: %q", a)
+			}
+			if m == auth.MethodHMAC && route.HMAC == nil {
+				return errors.New(`auth に "hmac" を指定したルートには hmac の設定が必要です`)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeRoutes(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "routes.json"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadRoutes(t *testing.T) {
+	dir := writeRoutes(t, `{"routes": [
+		{"path": "/api/customers/balance/webhook", "methods": ["post"], "upstream_env": "BALANCE_SERVICE_URL",
+		 "auth": ["hmac"], "hmac": {"secret_env": "PAYMENT_WEBHOOK_SECRET"}},
+		{"path": "/api/customers/account", "methods": ["GET"], "upstream": "https://account.internal"}
+	]}`)
+
+	routes, err := LoadRoutes(dir)
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("LoadRoutes() len = %d, want 2", len(routes))
+	}
+	if routes[0].Methods[0] != POST {
+		t.Errorf("methods は大文字に正規化されるべき: %v", routes[0].Methods)
+	}
+	if routes[0].HMAC == nil || routes[0].HMAC.SecretEnv != "PAYMENT_WEBHOOK_SECRET" {
+		t.Errorf("hmac 設定 = %+v", routes[0].HMAC)
+	}
+
+	r := NewRouterWithRoutes(mockProxyRequest, routes)
+	if route, ok := r.Match(makeRequest("/api/customers/account", GET)); !ok || route.upstreamURL() != "https://account.internal" {
+		t.Errorf("Match() = %+v, %v", route, ok)
+	}
+	if _, ok := r.Match(makeRequest("/api/customers/account", POST)); ok {
+		t.Errorf("Match() 設定にないメソッドに一致しました")
+	}
+}
+
+func TestLoadRoutes_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"異常系: JSON ではない", `routes`},
+		{"異常系: ルートがない", `{"routes": []}`},
+		{"異常系: path が / で始まらない", `{"routes": [{"path": "api", "methods": ["GET"], "upstream": "https://x"}]}`},
+		{"異常系: methods がない", `{"routes": [{"path": "/api", "upstream": "https://x"}]}`},
+		{"異常系: upstream がない", `{"routes": [{"path": "/api", "methods": ["GET"]}]}`},
+		{"異常系: hmac の設定がない", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "auth": ["hmac"]}]}`},
+		{"異常系: 連結した認証方式に hmac の設定がない", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "auth": ["mtls+hmac"]}]}`},
+		{"異常系: 認証方式の連結が不正", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "auth": ["jwt+"]}]}`},
+		{"異常系: max_body_bytes が負の値", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "limits": {"max_body_bytes": -1}}]}`},
+		{"異常系: content_types の形式が不正", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "limits": {"content_types": ["json"]}}]}`},
+		{"異常系: stream の値が不正", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "stream": "websocket"}]}`},
+		{"異常系: response_validation の mode が不正", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "response_validation": {"mode": "strict", "schema": "a.json"}}]}`},
+		{"異常系: response_validation の schema がない", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "response_validation": {"mode": "report"}}]}`},
+		{"異常系: response_validation の schema のファイルがない", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "response_validation": {"mode": "report", "schema": "missing.json"}}]}`},
+		{"異常系: masking の JSONPath が不正", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "masking": [{"path": "accountNumber"}]}]}`},
+		{"異常系: masking の keep_last が負の値", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "masking": [{"path": "$.a", "keep_last": -1}]}]}`},
+		{"異常系: transform の op が不正", `{"routes": [{"path": "/api", "methods": ["POST"], "upstream": "https://x", "transform": {"request": [{"op": "rename"}]}}]}`},
+		{"異常系: sse のルートでレスポンスを変換する", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "stream": "sse", "transform": {"response": [{"op": "case", "case": "camelCase"}]}}]}`},
+		{"異常系: scopes の組が空", `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x", "scopes": [[]]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadRoutes(writeRoutes(t, tt.content)); err == nil {
+				t.Errorf("LoadRoutes() error = nil, want error")
+			}
+		})
+	}
+
+	if _, err := LoadRoutes(t.TempDir()); err == nil {
+		t.Errorf("LoadRoutes() routes.json と openapi.yaml がない場合に error = nil")
+	}
+}
+
+func TestLoadRoutesFromEnv(t *testing.T) {
+	t.Setenv("ROUTE_CONFIG_DIR", "")
+	routes, err := LoadRoutesFromEnv()
+	if err != nil || len(routes) != len(DefaultRoutes()) {
+		t.Errorf("LoadRoutesFromEnv() = %v, %v, want DefaultRoutes", routes, err)
+	}
+
+	t.Setenv("ROUTE_CONFIG_DIR", writeRoutes(t, `{"routes": [{"path": "/api", "methods": ["GET"], "upstream": "https://x"}]}`))
+	routes, err = LoadRoutesFromEnv()
+	if err != nil || len(routes) != 1 {
+		t.Errorf("LoadRoutesFromEnv() = %v, %v, want 1 route", routes, err)
+	}
+}
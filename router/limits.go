@@ -0,0 +1,141 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Limits はバックエンドへ転送する前に確認するリクエストの制限
+//
+//	{"max_body_bytes": 1048576, "content_types": ["application/json"], "reject_body_methods": ["GET", "DELETE"]}
+type Limits struct {
+	// MaxBodyBytes はデコード後 (API Gateway などが Base64 で渡した場合はデコードした後) のボディの上限。0 は無制限
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	// ContentTypes はボディのあるリクエストで受け付ける Content-Type。"application/*" のようにサブタイプを省略できる。空の場合は制限しない
+	ContentTypes []string `json:"content_types"`
+	// RejectBodyMethods はボディを受け付けない HTTP メソッド
+	RejectBodyMethods []string `json:"reject_body_methods"`
+}
+
+// LimitError はルートの制限に違反したリクエストのエラー。Status はクライアントに返すステータスコード
+type LimitError struct {
+	Status  int
+	Message string
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// CheckLimits はルートの制限を確認し、違反していれば *LimitError を返す。
+// ボディの長さが分からない場合は上限まで読み込み、r.Body を読み直せる状態に戻す
+func (route *Route) CheckLimits(r *http.Request) error {
+	l := route.Limits
+	if l == nil {
+		return nil
+	}
+
+	hasBody, err := peekBody(r)
+	if err != nil {
+		return &LimitError{Status: http.StatusBadRequest, Message: "Bad Request"}
+	}
+	if !hasBody {
+		return nil
+	}
+
+	for _, m := range l.RejectBodyMethods {
+		if m == r.Method {
+			return &LimitError{Status: http.StatusBadRequest, Message: "Request Body Not Allowed"}
+		}
+	}
+	if len(l.ContentTypes) > 0 && !l.allowsContentType(r.Header.Get("Content-Type")) {
+		return &LimitError{Status: http.StatusUnsupportedMediaType, Message: http.StatusText(http.StatusUnsupportedMediaType)}
+	}
+	if l.MaxBodyBytes > 0 {
+		return checkBodySize(r, l.MaxBodyBytes)
+	}
+	return nil
+}
+
+func (l *Limits) allowsContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range l.ContentTypes {
+		if allowed == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Limits) validate() error {
+	if l.MaxBodyBytes < 0 {
+		return errors.New("limits.max_body_bytes は 0 以上にしてください")
+	}
+	for i, ct := range l.ContentTypes {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || !strings.Contains(mediaType, "/") {
+			return fmt.Errorf("limits.content_types の形式が不正です: %q", ct)
+		}
+		l.ContentTypes[i] = mediaType
+	}
+	for i, m := range l.RejectBodyMethods {
+		l.RejectBodyMethods[i] = strings.ToUpper(m)
+	}
+	return nil
+}
+
+// peekBody はボディがあるかを返す。長さが分からない場合は 1 バイト読み、読んだ分を r.Body に戻す
+func peekBody(r *http.Request) (bool, error) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return false, nil
+	}
+	if r.ContentLength > 0 {
+		return true, nil
+	}
+	buf := make([]byte, 1)
+	n, err := io.ReadFull(r.Body, buf)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf[:n]), r.Body), Closer: r.Body}
+	return n > 0, nil
+}
+
+// ボディが max バイトを超えていれば 413 を返す。長さが分からない場合は max+1 バイトまで読み込んで確認する
+func checkBodySize(r *http.Request, max int64) error {
+	tooLarge := &LimitError{Status: http.StatusRequestEntityTooLarge, Message: http.StatusText(http.StatusRequestEntityTooLarge)}
+	if r.ContentLength > max {
+		return tooLarge
+	}
+	if r.ContentLength >= 0 {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		return &LimitError{Status: http.StatusBadRequest, Message: "Bad Request"}
+	}
+	if int64(len(body)) > max {
+		return tooLarge
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,91 @@
+package router
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckLimits(t *testing.T) {
+	route := &Route{Limits: &Limits{
+		MaxBodyBytes:      10,
+		ContentTypes:      []string{"application/json", "text/*"},
+		RejectBodyMethods: []string{"get", "delete"},
+	}}
+	if err := route.Limits.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	newRequest := func(method, contentType, body string, chunked bool) *http.Request {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest(method, "/api", nil)
+		} else {
+			r = httptest.NewRequest(method, "/api", strings.NewReader(body))
+		}
+		if chunked {
+			r.ContentLength = -1
+		}
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		request    *http.Request
+		wantStatus int
+	}{
+		{name: "正常系: 上限以内の JSON", request: newRequest("POST", "application/json; charset=utf-8", `{"a":1}`, false)},
+		{name: "正常系: サブタイプを省略した Content-Type", request: newRequest("POST", "text/csv", "a,b", false)},
+		{name: "正常系: ボディのない GET", request: newRequest("GET", "", "", false)},
+		{name: "正常系: 長さの分からない上限以内のボディ", request: newRequest("PUT", "application/json", `{"a":1}`, true)},
+		{name: "異常系: 上限を超えるボディ", request: newRequest("POST", "application/json", `{"a":"12345"}`, false), wantStatus: 413},
+		{name: "異常系: 長さの分からない上限を超えるボディ", request: newRequest("POST", "application/json", `{"a":"12345"}`, true), wantStatus: 413},
+		{name: "異常系: 許可されていない Content-Type", request: newRequest("POST", "application/xml", "<a/>", false), wantStatus: 415},
+		{name: "異常系: Content-Type がない", request: newRequest("POST", "", "{}", false), wantStatus: 415},
+		{name: "異常系: ボディのある GET", request: newRequest("GET", "application/json", "{}", false), wantStatus: 400},
+		{name: "異常系: 長さの分からないボディのある DELETE", request: newRequest("DELETE", "application/json", "{}", true), wantStatus: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := route.CheckLimits(tt.request)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Errorf("CheckLimits() error = %v, want nil", err)
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Status != tt.wantStatus {
+				t.Errorf("CheckLimits() error = %v, want status %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// 確認のために読んだボディはバックエンドへ転送できる状態に戻す
+func TestCheckLimits_RestoresBody(t *testing.T) {
+	route := &Route{Limits: &Limits{MaxBodyBytes: 100, RejectBodyMethods: []string{"GET"}}}
+	r := httptest.NewRequest("POST", "/api", strings.NewReader(`{"a":1}`))
+	r.ContentLength = -1
+
+	if err := route.CheckLimits(r); err != nil {
+		t.Fatalf("CheckLimits() error = %v", err)
+	}
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != `{"a":1}` {
+		t.Errorf("Body = %q", body)
+	}
+}
+
+func TestCheckLimits_NoLimits(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api", strings.NewReader("body"))
+	if err := (&Route{}).CheckLimits(r); err != nil {
+		t.Errorf("CheckLimits() error = %v, want nil", err)
+	}
+}
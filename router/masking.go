@@ -0,0 +1,105 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/jsonpath"
+)
+
+const (
+	// マスクした文字の置き換え
+	maskChar = "*"
+	// オブジェクトと配列を置き換えるマスクの文字数
+	maskedContainerLen = 8
+)
+
+// MaskRule はバックエンドの JSON レスポンスの値をマスクする規則
+type MaskRule struct {
+	// Path はマスクする値の JSONPath ($.accountNumber、$.owners[*].nationalId、$..nationalId など)
+	Path string `json:"path"`
+	// KeepLast はマスクせずに残す末尾の文字数。0 の場合はすべてマスクする
+	KeepLast int `json:"keep_last"`
+	// UnmaskScopes のいずれかのスコープ (または permissions) を持つ principal にはマスクしない
+	UnmaskScopes []string `json:"unmask_scopes"`
+
+	path *jsonpath.Path
+}
+
+func (rule *MaskRule) compile() error {
+	if rule.Path == "" {
+		return errors.New("masking の path は必須です")
+	}
+	if rule.KeepLast < 0 {
+		return fmt.Errorf("masking の keep_last は 0 以上を指定してください: %d", rule.KeepLast)
+	}
+	p, err := jsonpath.Compile(rule.Path)
+	if err != nil {
+		return err
+	}
+	rule.path = p
+	return nil
+}
+
+// principal がマスクされていない値を参照できるかを返す
+func (rule *MaskRule) unmasked(principal *auth.Principal) bool {
+	if principal == nil {
+		return false
+	}
+	for _, scope := range rule.UnmaskScopes {
+		if hasAllScopes(principal, []string{scope}) {
+			return true
+		}
+	}
+	return false
+}
+
+// principal のスコープに応じて、レスポンスのボディの値を規則に沿ってマスクする
+func (route *Route) maskResponse(resp *bufferedResponse, principal *auth.Principal) error {
+	var rules []*MaskRule
+	for i := range route.Masking {
+		if rule := &route.Masking[i]; !rule.unmasked(principal) {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	body, err := resp.decodeJSON()
+	if err != nil {
+		// 解析できないボディはマスクできないため、値を漏らさないよう返さない
+		return fmt.Errorf("マスクするレスポンスを JSON として解析できません: %w", err)
+	}
+	for _, rule := range rules {
+		body = rule.path.Modify(body, func(v any) any { return maskValue(v, rule.KeepLast) })
+	}
+	return resp.setJSON(body)
+}
+
+// 文字列と数値を、末尾の keepLast 文字を残してマスクした文字列にする。
+// オブジェクトと配列は中身も大きさも漏らさないよう、keep_last に関係なく固定長のマスクに置き換える。null はそのまま返す
+func maskValue(v any, keepLast int) any {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]any, []any:
+		return strings.Repeat(maskChar, maskedContainerLen)
+	case string:
+		s = v
+	case fmt.Stringer:
+		s = v.String()
+	case bool, float64:
+		s = fmt.Sprint(v)
+	default:
+		return v
+	}
+	runes := []rune(s)
+	if keepLast >= len(runes) {
+		keepLast = 0
+	}
+	return strings.Repeat(maskChar, len(runes)-keepLast) + string(runes[len(runes)-keepLast:])
+}
@@ -0,0 +1,128 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aki80204/go-gateway/auth"
+)
+
+func TestForward_Masking(t *testing.T) {
+	routes := writeRoutesIn(t, t.TempDir(), `{"routes": [{"path": "/api/accounts", "methods": ["GET"], "upstream": "https://account.internal",
+		"masking": [
+			{"path": "$.accountNumber", "keep_last": 4, "unmask_scopes": ["read:pii"]},
+			{"path": "$.owners[*].nationalId", "unmask_scopes": ["read:pii"]},
+			{"path": "$.pin"},
+			{"path": "$.address"},
+			{"path": "$.cards"}
+		]}]}`)
+	const body = `{"accountNumber": "1234567890", "owners": [{"name": "山田", "nationalId": "AB-123"}], "pin": 4321, "memo": null,
+		"address": {"zip": "100-0001", "city": "千代田区"}, "cards": ["4111111111111111"]}`
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		want      string
+	}{
+		{
+			name:      "read:pii がなければマスクする",
+			principal: &auth.Principal{Subject: "user-123", Scopes: []string{"read:accounts"}},
+			want:      `{"accountNumber":"******7890","address":"********","cards":"********","memo":null,"owners":[{"name":"山田","nationalId":"******"}],"pin":"****"}`,
+		},
+		{
+			name:      "read:pii があればスコープ付きの規則はマスクしない",
+			principal: &auth.Principal{Subject: "support-1", Permissions: []string{"read:pii"}},
+			want:      `{"accountNumber":"1234567890","address":"********","cards":"********","memo":null,"owners":[{"name":"山田","nationalId":"AB-123"}],"pin":"****"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewRouterWithRoutes(jsonProxy(200, body), routes).Route(rec, makeRequest("/api/accounts", GET), tt.principal)
+			if rec.Code != 200 || rec.Body.String() != tt.want {
+				t.Errorf("Route() = %d %s, want %s", rec.Code, rec.Body.String(), tt.want)
+			}
+		})
+	}
+
+	// JSON として解析できないボディは値を漏らさないよう返さない
+	rec := httptest.NewRecorder()
+	NewRouterWithRoutes(jsonProxy(200, `{"accountNumber": "1234`), routes).
+		Route(rec, makeRequest("/api/accounts", GET), &auth.Principal{Subject: "user-123"})
+	if rec.Code != 502 {
+		t.Errorf("解析できないボディの StatusCode = %d, want 502", rec.Code)
+	}
+}
+
+// JSON として処理できないボディは、マスクせずに返さないよう 502 とする
+func TestForward_MaskingRejectsNonJSON(t *testing.T) {
+	routes := writeRoutesIn(t, t.TempDir(), `{"routes": [{"path": "/api/accounts", "methods": ["GET"], "upstream": "https://account.internal",
+		"masking": [{"path": "$.accountNumber", "keep_last": 4}]}]}`)
+	tests := []struct {
+		name            string
+		contentType     string
+		contentEncoding string
+		body            string
+		wantStatus      int
+	}{
+		{name: "text/plain", contentType: "text/plain", body: `{"accountNumber": "1234567890"}`, wantStatus: 502},
+		{name: "Content-Type なし", body: `{"accountNumber": "1234567890"}`, wantStatus: 502},
+		{name: "gzip で圧縮された JSON", contentType: "application/json", contentEncoding: "gzip", body: "\x1f\x8b\x08\x00", wantStatus: 502},
+		{name: "ボディなし", contentType: "text/plain", wantStatus: 204},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouterWithRoutes(func(w http.ResponseWriter, req *http.Request, targetBaseURL string, principal *auth.Principal) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				} else {
+					w.Header()["Content-Type"] = nil
+				}
+				if tt.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", tt.contentEncoding)
+				}
+				if tt.body == "" {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				_, _ = w.Write([]byte(tt.body))
+			}, routes)
+
+			rec := httptest.NewRecorder()
+			r.Route(rec, makeRequest("/api/accounts", GET), &auth.Principal{Subject: "user-123"})
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Route() StatusCode = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if strings.Contains(rec.Body.String(), "1234567890") {
+				t.Errorf("Route() = %s, マスクされていない値を返しました", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestForward_MaskingOpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	writeOpenAPI(t, dir, `
+openapi: 3.0.3
+info: {title: accounts, version: "1.0"}
+paths:
+  /api/accounts:
+    get:
+      x-gateway-upstream: https://account.internal
+      x-gateway-masking:
+        - {path: $.accountNumber, keep_last: 4, unmask_scopes: [read:pii]}
+      responses: {"200": {description: 口座}}
+`)
+	routes, err := LoadRoutes(dir)
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	NewRouterWithRoutes(jsonProxy(200, `{"accountNumber": "1234567890"}`), routes).
+		Route(rec, makeRequest("/api/accounts", GET), &auth.Principal{Subject: "user-123"})
+	if want := `{"accountNumber":"******7890"}`; rec.Body.String() != want {
+		t.Errorf("Route() = %s, want %s", rec.Body.String(), want)
+	}
+}
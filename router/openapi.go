@@ -0,0 +1,148 @@
+package router
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/openapi"
+)
+
+// ルーティング設定ディレクトリ内の OpenAPI 定義のファイル名。最初に見つかったものを読み込む
+var openAPIFileNames = []string{"openapi.yaml", "openapi.yml", "openapi.json"}
+
+//go:embed default_openapi.yaml
+var defaultOpenAPI []byte
+
+// ルーティング設定ディレクトリに OpenAPI 定義があれば、operation ごとのルートを返す。定義がなければ nil を返す
+func loadOpenAPIRoutes(dir string) ([]Route, error) {
+	for _, name := range openAPIFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		ops, err := openapi.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		routes, err := routesFromOperations(ops)
+		if err != nil {
+			return nil, fmt.Errorf("OpenAPI 定義が不正です (%s): %w", path, err)
+		}
+		return routes, nil
+	}
+	return nil, nil
+}
+
+func routesFromOperations(ops []openapi.Operation) ([]Route, error) {
+	routes := make([]Route, 0, len(ops))
+	for _, op := range ops {
+		route := Route{
+			Path:        op.Path,
+			Methods:     []string{op.Method},
+			Upstream:    op.Upstream,
+			UpstreamEnv: op.UpstreamEnv,
+			Auth:        op.Auth,
+			Scopes:      op.Scopes,
+			openAPI:     op.Validator,
+		}
+		if op.ResponseValidation != "" {
+			route.ResponseValidation = &ResponseValidation{Mode: op.ResponseValidation}
+		}
+		if op.Masking != nil {
+			if err := json.Unmarshal(op.Masking, &route.Masking); err != nil {
+				return nil, fmt.Errorf("%s %s: x-gateway-masking の形式が不正です: %w", op.Method, op.Path, err)
+			}
+		}
+		if op.Transform != nil {
+			if err := json.Unmarshal(op.Transform, &route.Transform); err != nil {
+				return nil, fmt.Errorf("%s %s: x-gateway-transform の形式が不正です: %w", op.Method, op.Path, err)
+			}
+		}
+		if err := route.validate(); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Method, op.Path, err)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// matchPath は path が route.Path に一致すればパスパラメータを返す。
+// {name} のセグメントは空でない任意のセグメントに一致し、デコードした値をパラメータとする
+func (route *Route) matchPath(path string) (map[string]string, bool) {
+	if !strings.Contains(route.Path, "{") {
+		return nil, route.Path == path
+	}
+
+	want := strings.Split(route.Path, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range want {
+		if name, ok := pathParamName(segment); ok {
+			if got[i] == "" {
+				return nil, false
+			}
+			value, err := url.PathUnescape(got[i])
+			if err != nil {
+				value = got[i]
+			}
+			params[name] = value
+			continue
+		}
+		if segment != got[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func pathParamName(segment string) (string, bool) {
+	if len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// AllowsScopes は principal が route.Scopes のいずれかの組のスコープをすべて持つかを返す。
+// Auth0 の RBAC で付与される permissions もスコープとして扱う
+func (route *Route) AllowsScopes(principal *auth.Principal) bool {
+	if len(route.Scopes) == 0 {
+		return true
+	}
+	if principal == nil {
+		return false
+	}
+	for _, scopes := range route.Scopes {
+		if hasAllScopes(principal, scopes) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAllScopes(principal *auth.Principal, scopes []string) bool {
+	for _, scope := range scopes {
+		if !principal.HasScope(scope) && !contains(principal.Permissions, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package router
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aki80204/go-gateway/auth"
+)
+
+const testOpenAPI = `
+openapi: 3.0.3
+info: {title: accounts, version: "1.0"}
+components:
+  securitySchemes:
+    auth0: {type: http, scheme: bearer}
+paths:
+  /api/accounts/{accountId}:
+    x-gateway-upstream: ${ACCOUNT_SERVICE_URL}
+    parameters:
+      - {name: accountId, in: path, required: true, schema: {type: string, pattern: "^[0-9]{7}$"}}
+    get:
+      security:
+        - auth0: [read:accounts]
+        - auth0: [admin]
+      responses: {"200": {description: 口座}}
+  /api/accounts/me:
+    get:
+      x-gateway-upstream: https://profile.internal
+      responses: {"200": {description: 自分の口座}}
+`
+
+func writeOpenAPI(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "openapi.yaml"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRoutes_OpenAPI(t *testing.T) {
+	t.Setenv("ACCOUNT_SERVICE_URL", "https://account.internal")
+	dir := writeRoutes(t, `{"routes": [{"path": "/api/legacy", "methods": ["GET"], "upstream": "https://legacy.internal"}]}`)
+	writeOpenAPI(t, dir, testOpenAPI)
+
+	routes, err := LoadRoutes(dir)
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	if len(routes) != 3 || routes[0].Path != "/api/legacy" {
+		t.Fatalf("LoadRoutes() = %+v, want routes.json のルートの後に OpenAPI の 2 件", routes)
+	}
+
+	r := NewRouterWithRoutes(mockProxyRequest, routes)
+	tests := []struct {
+		name         string
+		path         string
+		wantUpstream string
+	}{
+		{name: "固定のパスはパスパラメータより優先する", path: "/api/accounts/me", wantUpstream: "https://profile.internal"},
+		{name: "パスパラメータ", path: "/api/accounts/1234567", wantUpstream: "https://account.internal"},
+		{name: "routes.json のルート", path: "/api/legacy", wantUpstream: "https://legacy.internal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, ok := r.Match(makeRequest(tt.path, GET))
+			if !ok || route.upstreamURL() != tt.wantUpstream {
+				t.Errorf("Match(%s) = %+v, %v, want %s", tt.path, route, ok, tt.wantUpstream)
+			}
+		})
+	}
+	for _, path := range []string{"/api/accounts", "/api/accounts/", "/api/accounts/1234567/history"} {
+		if _, ok := r.Match(makeRequest(path, GET)); ok {
+			t.Errorf("Match(%s) が一致しました", path)
+		}
+	}
+	if _, ok := r.Match(makeRequest("/api/accounts/1234567", POST)); ok {
+		t.Error("Match() 定義にないメソッドに一致しました")
+	}
+}
+
+func TestLoadRoutes_OpenAPIOnly(t *testing.T) {
+	dir := t.TempDir()
+	writeOpenAPI(t, dir, testOpenAPI)
+	routes, err := LoadRoutes(dir)
+	if err != nil || len(routes) != 2 {
+		t.Fatalf("LoadRoutes() = %d 件, %v, want 2 件", len(routes), err)
+	}
+
+	writeOpenAPI(t, dir, "openapi: 3.0.3\npaths: {}")
+	if _, err := LoadRoutes(dir); err == nil {
+		t.Error("LoadRoutes() 不正な OpenAPI 定義で error = nil")
+	}
+}
+
+func TestValidateRequest_OpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	writeOpenAPI(t, dir, testOpenAPI)
+	routes, err := LoadRoutes(dir)
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	route := &routes[1]
+
+	violations, err := route.ValidateRequest(httptest.NewRequest(GET, "/api/accounts/1234567", nil))
+	if err != nil || len(violations) != 0 {
+		t.Errorf("ValidateRequest() = %+v, %v, want 違反なし", violations, err)
+	}
+	violations, err = route.ValidateRequest(httptest.NewRequest(GET, "/api/accounts/12%2034", nil))
+	if err != nil || len(violations) != 1 || violations[0].In != "path" || !strings.Contains(violations[0].Detail, "regular expression") {
+		t.Errorf("ValidateRequest() = %+v, %v, want パスパラメータの違反", violations, err)
+	}
+}
+
+func TestRoute_AllowsScopes(t *testing.T) {
+	route := &Route{Scopes: [][]string{{"read:accounts", "read:pii"}, {"admin"}}}
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		want      bool
+	}{
+		{name: "組のスコープをすべて持つ", principal: &auth.Principal{Scopes: []string{"read:accounts", "read:pii"}}, want: true},
+		{name: "別の組のスコープを持つ", principal: &auth.Principal{Scopes: []string{"admin"}}, want: true},
+		{name: "permissions もスコープとして扱う", principal: &auth.Principal{Scopes: []string{"read:accounts"}, Permissions: []string{"read:pii"}}, want: true},
+		{name: "組のスコープが足りない", principal: &auth.Principal{Scopes: []string{"read:accounts"}}},
+		{name: "principal がない", principal: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := route.AllowsScopes(tt.principal); got != tt.want {
+				t.Errorf("AllowsScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !(&Route{}).AllowsScopes(nil) {
+		t.Error("scopes がないルートは AllowsScopes() = true")
+	}
+}
@@ -0,0 +1,177 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/utils"
+)
+
+// errResponseRejected はバックエンドのレスポンスをクライアントへ返さずに 502 とすることを表す
+var errResponseRejected = errors.New("バックエンドのレスポンスを拒否しました")
+
+// processesResponse はバックエンドのレスポンスをバッファして処理するルートかを返す
+func (route *Route) processesResponse() bool {
+	return route.ResponseValidation != nil || len(route.Masking) > 0 || (route.Transform != nil && len(route.Transform.Response) > 0)
+}
+
+// バックエンドのレスポンスをバッファし、JSON のボディを処理してからクライアントへ書き込む
+func (r *Router) forwardBuffered(w http.ResponseWriter, req *http.Request, route *Route, principal *auth.Principal) {
+	// 圧縮されたボディは処理できないため、バックエンドには圧縮しないレスポンスを要求する
+	req.Header.Del("Accept-Encoding")
+
+	resp := newBufferedResponse()
+	r.proxy(resp, req, route.upstreamURL(), principal)
+
+	if !resp.isJSON() {
+		if resp.body.Len() > 0 && route.requiresJSONResponse() {
+			log.Printf("JSON として処理できないレスポンスを拒否しました (%s %s, Content-Type=%q, Content-Encoding=%q)",
+				req.Method, route.Path, resp.header.Get("Content-Type"), resp.header.Get("Content-Encoding"))
+			utils.WriteError(w, http.StatusBadGateway, "Bad Gateway")
+			return
+		}
+		resp.writeTo(w)
+		return
+	}
+
+	if err := route.processResponse(req, resp, principal); err != nil {
+		if !errors.Is(err, errResponseRejected) {
+			log.Printf("レスポンスの処理に失敗しました (%s %s): %v", req.Method, route.Path, err)
+		}
+		utils.WriteError(w, http.StatusBadGateway, "Bad Gateway")
+		return
+	}
+	resp.writeTo(w)
+}
+
+// requiresJSONResponse は JSON として処理できないボディを 502 とするルートかを返す。
+// マスクするルートでは、処理できないボディをそのまま返すとマスクすべき値が漏れる。
+// enforce モードでは、検証できないボディを契約に適合しないものとして扱う
+func (route *Route) requiresJSONResponse() bool {
+	if v := route.ResponseValidation; v != nil && v.Mode == ResponseEnforce {
+		return true
+	}
+	return len(route.Masking) > 0
+}
+
+// ルートの設定に沿って JSON のレスポンスを処理する。
+// 検証とマスクはバックエンドの契約の値に対して行うため、クライアント向けの変換より前に行う
+func (route *Route) processResponse(req *http.Request, resp *bufferedResponse, principal *auth.Principal) error {
+	if route.ResponseValidation != nil {
+		if err := route.validateResponse(req, resp); err != nil {
+			return err
+		}
+	}
+	if len(route.Masking) > 0 {
+		if err := route.maskResponse(resp, principal); err != nil {
+			return err
+		}
+	}
+	if route.Transform != nil && len(route.Transform.Response) > 0 {
+		if err := route.transformResponse(req, resp, principal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bufferedResponse はバックエンドのレスポンスを書き込まずに保持する http.ResponseWriter
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header)}
+}
+
+func (w *bufferedResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponse) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Content-Encoding のない JSON のレスポンスかを返す
+func (w *bufferedResponse) isJSON() bool {
+	if w.header.Get("Content-Encoding") != "" || w.body.Len() == 0 {
+		return false
+	}
+	return isJSONMediaType(w.header.Get("Content-Type"))
+}
+
+// Content-Type が application/json または +json かを返す
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeJSON はボディを解析する。数値は精度を保つため json.Number のまま扱う
+func (w *bufferedResponse) decodeJSON() (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(w.body.Bytes()))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// setJSON はボディを v の JSON で置き換える
+func (w *bufferedResponse) setJSON(v any) error {
+	data, err := encodeJSON(v)
+	if err != nil {
+		return err
+	}
+	w.body.Reset()
+	w.body.Write(data)
+	return nil
+}
+
+// encodeJSON は v を JSON に変換する。バックエンドの値を変えないよう、< や & はエスケープしない
+func encodeJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// 保持したレスポンスを書き込む。ボディを置き換えた場合があるため Content-Length は書き込むボディから設定する
+func (w *bufferedResponse) writeTo(dst http.ResponseWriter) {
+	header := dst.Header()
+	for k, vs := range w.header {
+		header[k] = vs
+	}
+	header.Set("Content-Length", strconv.Itoa(w.body.Len()))
+	dst.WriteHeader(w.statusCode())
+	_, _ = dst.Write(w.body.Bytes())
+}
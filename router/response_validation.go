@@ -0,0 +1,115 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/aki80204/go-gateway/metrics"
+	"github.com/aki80204/go-gateway/schema"
+)
+
+// レスポンス検証のモード (ResponseValidation.Mode)
+const (
+	// ResponseReport は違反をログとメトリクスに出力し、レスポンスはそのまま返す
+	ResponseReport = "report"
+	// ResponseEnforce は違反があれば 502 を返す
+	ResponseEnforce = "enforce"
+	// ResponseFilter はスキーマで宣言されていないプロパティを取り除き、残った違反は report と同じく出力する
+	ResponseFilter = "filter"
+)
+
+// ResponseValidation はバックエンドの JSON レスポンスの検証設定
+type ResponseValidation struct {
+	Mode string `json:"mode"`
+	// Schema は 2xx のレスポンスボディの JSON Schema のパス (ROUTE_CONFIG_DIR からの相対パス)。
+	// OpenAPI 定義から導いたルートでは省略でき、operation の responses の定義で検証する
+	Schema string `json:"schema"`
+
+	schema *schema.Schema
+}
+
+func (v *ResponseValidation) compile(c *schema.Compiler) error {
+	if v.Schema == "" {
+		return nil
+	}
+	s, err := c.Compile(v.Schema)
+	if err != nil {
+		return err
+	}
+	v.schema = s
+	return nil
+}
+
+func (route *Route) validateResponseValidation() error {
+	v := route.ResponseValidation
+	switch v.Mode {
+	case ResponseReport, ResponseEnforce, ResponseFilter:
+	default:
+		return fmt.Errorf("response_validation の mode が不正です: %q", v.Mode)
+	}
+	if v.Schema == "" && route.openAPI == nil {
+		return errors.New("response_validation には schema が必要です")
+	}
+	if route.Stream == StreamSSE {
+		return errors.New(`stream: "sse" のルートではレスポンスを検証できません`)
+	}
+	return nil
+}
+
+// バックエンドの JSON レスポンスをルートのモードに沿って検証する
+func (route *Route) validateResponse(req *http.Request, resp *bufferedResponse) error {
+	v := route.ResponseValidation
+	if v.Mode == ResponseFilter {
+		if err := route.filterResponse(resp); err != nil {
+			return err
+		}
+	}
+
+	violations := route.responseViolations(req, resp)
+	if len(violations) == 0 {
+		return nil
+	}
+	log.Printf("バックエンドのレスポンスがスキーマに適合しません (%s %s, status=%d): %+v", req.Method, route.Path, resp.statusCode(), violations)
+	metrics.Emit("ResponseSchemaViolation", 1, metrics.UnitCount, map[string]string{"Route": route.Path})
+	if v.Mode == ResponseEnforce {
+		return errResponseRejected
+	}
+	return nil
+}
+
+func (route *Route) responseViolations(req *http.Request, resp *bufferedResponse) []schema.Violation {
+	v := route.ResponseValidation
+	if v.schema != nil {
+		if !isSuccess(resp.statusCode()) {
+			return nil
+		}
+		return v.schema.ValidateJSON(schema.InBody, bytes.NewReader(resp.body.Bytes()))
+	}
+	return route.openAPI.ValidateResponse(req, resp.statusCode(), resp.header, resp.body.Bytes())
+}
+
+// スキーマで宣言されていないプロパティをボディから取り除く。JSON として解析できないボディはそのまま残し、検証で違反とする
+func (route *Route) filterResponse(resp *bufferedResponse) error {
+	body, err := resp.decodeJSON()
+	if err != nil {
+		return nil
+	}
+	v := route.ResponseValidation
+	switch {
+	case v.schema != nil:
+		if !isSuccess(resp.statusCode()) {
+			return nil
+		}
+		body = v.schema.Filter(body)
+	default:
+		body = route.openAPI.FilterResponse(resp.statusCode(), resp.header.Get("Content-Type"), body)
+	}
+	return resp.setJSON(body)
+}
+
+func isSuccess(status int) bool {
+	return status >= 200 && status < 300
+}
@@ -0,0 +1,157 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/aki80204/go-gateway/auth"
+)
+
+// body を JSON として返すバックエンドのモック
+func jsonProxy(status int, body string) ProxyFunc {
+	return func(w http.ResponseWriter, r *http.Request, targetBaseURL string, principal *auth.Principal) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "999")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestForward_ResponseValidation(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "account.json"), []byte(`{
+		"type": "object", "required": ["accountId"],
+		"properties": {"accountId": {"type": "string"}, "name": {"type": "string"}}
+	}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		mode       string
+		status     int
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "report: 違反があってもそのまま返す", mode: ResponseReport, status: 200, body: `{"accountId": 1}`, wantStatus: 200, wantBody: `{"accountId": 1}`},
+		{name: "enforce: 適合するレスポンス", mode: ResponseEnforce, status: 200, body: `{"accountId": "1"}`, wantStatus: 200, wantBody: `{"accountId": "1"}`},
+		{name: "enforce: 違反があれば 502", mode: ResponseEnforce, status: 200, body: `{"accountId": 1}`, wantStatus: 502, wantBody: `{"error":"Bad Gateway"}`},
+		{name: "enforce: JSON でなければ 502", mode: ResponseEnforce, status: 200, body: `{"accountId"`, wantStatus: 502, wantBody: `{"error":"Bad Gateway"}`},
+		{name: "enforce: 2xx 以外は検証しない", mode: ResponseEnforce, status: 404, body: `{"error": "Not Found"}`, wantStatus: 404, wantBody: `{"error": "Not Found"}`},
+		{
+			name: "filter: 宣言されていないプロパティを取り除く", mode: ResponseFilter, status: 200,
+			body: `{"accountId": "1", "name": "<山田>", "nationalId": "123-45-6789"}`, wantStatus: 200, wantBody: `{"accountId":"1","name":"<山田>"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := writeRoutesIn(t, dir, `{"routes": [{"path": "/api/accounts", "methods": ["GET"], "upstream": "https://account.internal",
+				"response_validation": {"mode": "`+tt.mode+`", "schema": "account.json"}}]}`)
+			r := NewRouterWithRoutes(jsonProxy(tt.status, tt.body), routes)
+
+			rec := httptest.NewRecorder()
+			r.Route(rec, makeRequest("/api/accounts", GET), &auth.Principal{Subject: "user-123"})
+
+			if rec.Code != tt.wantStatus || rec.Body.String() != tt.wantBody {
+				t.Errorf("Route() = %d %s, want %d %s", rec.Code, rec.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+			if got := rec.Header().Get("Content-Length"); got != "" && got != strconv.Itoa(rec.Body.Len()) {
+				t.Errorf("Content-Length = %s, want %d", got, rec.Body.Len())
+			}
+		})
+	}
+}
+
+func TestForward_ResponseValidationOpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	writeOpenAPI(t, dir, `
+openapi: 3.0.3
+info: {title: accounts, version: "1.0"}
+paths:
+  /api/accounts:
+    get:
+      x-gateway-upstream: https://account.internal
+      x-gateway-response-validation: enforce
+      responses:
+        "200":
+          description: 口座
+          content:
+            application/json:
+              schema: {type: object, required: [accountId], properties: {accountId: {type: string}}}
+`)
+	routes, err := LoadRoutes(dir)
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+
+	for status, want := range map[int]int{200: 200, 500: 502} {
+		rec := httptest.NewRecorder()
+		NewRouterWithRoutes(jsonProxy(status, `{"accountId": "1"}`), routes).
+			Route(rec, makeRequest("/api/accounts", GET), &auth.Principal{Subject: "user-123"})
+		if rec.Code != want {
+			t.Errorf("バックエンドが %d の場合の StatusCode = %d, want %d", status, rec.Code, want)
+		}
+	}
+}
+
+func TestForward_ResponseValidationSkipsNonJSON(t *testing.T) {
+	routes := []Route{{
+		Path: "/api/accounts", Methods: []string{GET}, Upstream: "https://account.internal",
+		ResponseValidation: &ResponseValidation{Mode: ResponseReport},
+	}}
+	r := NewRouterWithRoutes(func(w http.ResponseWriter, req *http.Request, targetBaseURL string, principal *auth.Principal) {
+		if req.Header.Get("Accept-Encoding") != "" {
+			t.Errorf("Accept-Encoding = %q, want 削除", req.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		_, _ = w.Write([]byte("a,b"))
+	}, routes)
+
+	req := makeRequest("/api/accounts", GET)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	r.Route(rec, req, &auth.Principal{Subject: "user-123"})
+	if rec.Code != 200 || rec.Body.String() != "a,b" {
+		t.Errorf("Route() = %d %s, want JSON 以外はそのまま", rec.Code, rec.Body.String())
+	}
+}
+
+// enforce モードでは検証できない JSON 以外のボディを返さない
+func TestForward_ResponseValidationEnforceRejectsNonJSON(t *testing.T) {
+	routes := []Route{{
+		Path: "/api/accounts", Methods: []string{GET}, Upstream: "https://account.internal",
+		ResponseValidation: &ResponseValidation{Mode: ResponseEnforce},
+	}}
+	for _, contentType := range []string{"text/csv", ""} {
+		r := NewRouterWithRoutes(func(w http.ResponseWriter, req *http.Request, targetBaseURL string, principal *auth.Principal) {
+			w.Header()["Content-Type"] = nil
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			_, _ = w.Write([]byte("a,b"))
+		}, routes)
+
+		rec := httptest.NewRecorder()
+		r.Route(rec, makeRequest("/api/accounts", GET), &auth.Principal{Subject: "user-123"})
+		if rec.Code != 502 {
+			t.Errorf("Content-Type %q の StatusCode = %d, want 502", contentType, rec.Code)
+		}
+	}
+}
+
+func writeRoutesIn(t *testing.T, dir, content string) []Route {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "routes.json"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := LoadRoutes(dir)
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	return routes
+}
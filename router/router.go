@@ -1,26 +1,39 @@
 package router
 
 import (
+	"fmt"
+	"log"
+	"net/http"
 	"os"
 
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/openapi"
 	"github.com/aki80204/go-gateway/proxy"
 	"github.com/aki80204/go-gateway/utils"
-	"github.com/aws/aws-lambda-go/events"
 )
 
-type ProxyFunc func(events.APIGatewayV2HTTPRequest, string, string) (events.APIGatewayProxyResponse, error)
+// ProxyFunc はリクエストを targetBaseURL のバックエンドへ転送し、レスポンスを w に書き込む
+type ProxyFunc func(w http.ResponseWriter, r *http.Request, targetBaseURL string, principal *auth.Principal)
 
 type Router struct {
-	proxy ProxyFunc
+	proxy  ProxyFunc
+	routes []Route
 }
 
+// NewRouter は既定のルーティング設定 (DefaultRoutes) で Router を生成する
 func NewRouter(pf ProxyFunc) *Router {
+	return NewRouterWithRoutes(pf, DefaultRoutes())
+}
+
+// NewRouterWithRoutes は routes の設定で Router を生成する
+func NewRouterWithRoutes(pf ProxyFunc, routes []Route) *Router {
 	if pf == nil {
 		pf = proxy.ProxyRequest
 	}
-	return &Router{proxy: pf}
+	return &Router{proxy: pf, routes: routes}
 }
 
+// 既定のルーティング設定 (default_openapi.yaml) のパス
 const (
 	ACCOUNT_SERVICE_PATH = "/api/customers/account"
 	ASSET_SERVICE_PATH   = "/api/customers/asset"
@@ -31,46 +44,77 @@ const (
 	PUT                  = "PUT"
 )
 
+// DefaultRoutes は顧客管理・資産管理・残高管理サービスへの既定のルーティング設定を返す。
+// ルートは埋め込みの OpenAPI 定義 (default_openapi.yaml) から導く
+func DefaultRoutes() []Route {
+	ops, err := openapi.LoadData(defaultOpenAPI)
+	if err != nil {
+		panic(fmt.Sprintf("既定の OpenAPI 定義が不正です: %v", err))
+	}
+	routes, err := routesFromOperations(ops)
+	if err != nil {
+		panic(fmt.Sprintf("既定の OpenAPI 定義が不正です: %v", err))
+	}
+	return routes
+}
+
+// Routes はルーティング設定を返す
+func (r *Router) Routes() []Route {
+	return r.routes
+}
+
+// Match は path と HTTP メソッドに一致するルートを返す。{id} のようなパスパラメータを含むルートは任意のセグメントに一致する
+func (r *Router) Match(req *http.Request) (*Route, bool) {
+	path := req.URL.EscapedPath()
+	for i := range r.routes {
+		route := &r.routes[i]
+		if _, ok := route.matchPath(path); ok && route.allowsMethod(req.Method) {
+			return route, true
+		}
+	}
+	return nil, false
+}
+
 // Route は path 毎、HTTP メソッドごとのルーティング処理を行う
-func (r *Router) Route(request events.APIGatewayV2HTTPRequest, sub string) (events.APIGatewayProxyResponse, error) {
-	switch request.RawPath {
-	case ACCOUNT_SERVICE_PATH:
-		return r.accountServiceRouter(request, sub)
-	case ASSET_SERVICE_PATH:
-		return r.assetServiceRouter(request, sub)
-	case BALANCE_SERVICE_PATH:
-		return r.balanceServiceRouter(request, sub)
-	default:
-		return utils.ErrorResponse(404, "Not Found"), nil
+func (r *Router) Route(w http.ResponseWriter, req *http.Request, principal *auth.Principal) {
+	route, ok := r.Match(req)
+	if !ok {
+		utils.WriteError(w, http.StatusNotFound, "Not Found")
+		return
 	}
+	r.Forward(w, req, route, principal)
 }
 
-// 顧客管理サービスへのルーティング処理
-func (r *Router) accountServiceRouter(request events.APIGatewayV2HTTPRequest, sub string) (events.APIGatewayProxyResponse, error) {
-	switch request.RequestContext.HTTP.Method {
-	case GET, PUT, DELETE, POST:
-		return r.proxy(request, os.Getenv("ACCOUNT_SERVICE_URL"), sub)
-	default:
-		return utils.ErrorResponse(404, "Not Found"), nil
+// Forward は Match で得たルートのバックエンドへリクエストを転送する
+func (r *Router) Forward(w http.ResponseWriter, req *http.Request, route *Route, principal *auth.Principal) {
+	if err := route.transformRequest(req, principal); err != nil {
+		log.Printf("リクエストの変換に失敗しました (%s %s): %v", req.Method, route.Path, err)
+		utils.WriteError(w, http.StatusBadRequest, "Bad Request")
+		return
+	}
+	if route.Stream == StreamSSE {
+		r.forwardSSE(w, req, route, principal)
+		return
+	}
+	if route.processesResponse() {
+		r.forwardBuffered(w, req, route, principal)
+		return
 	}
+	r.proxy(w, req, route.upstreamURL(), principal)
 }
 
-// 資産管理サービスへのルーティング処理
-func (r *Router) assetServiceRouter(request events.APIGatewayV2HTTPRequest, sub string) (events.APIGatewayProxyResponse, error) {
-	switch request.RequestContext.HTTP.Method {
-	case GET, PUT, DELETE, POST:
-		return r.proxy(request, os.Getenv("ASSET_SERVICE_URL"), sub)
-	default:
-		return utils.ErrorResponse(404, "Not Found"), nil
+func (route *Route) upstreamURL() string {
+	if route.Upstream != "" {
+		return route.Upstream
 	}
+	return os.Getenv(route.UpstreamEnv)
 }
 
-// 残高管理サービスへのルーティング処理
-func (r *Router) balanceServiceRouter(request events.APIGatewayV2HTTPRequest, sub string) (events.APIGatewayProxyResponse, error) {
-	switch request.RequestContext.HTTP.Method {
-	case GET, PUT, DELETE, POST:
-		return r.proxy(request, os.Getenv("BALANCE_SERVICE_URL"), sub)
-	default:
-		return utils.ErrorResponse(404, "Not Found"), nil
+func (route *Route) allowsMethod(method string) bool {
+	for _, m := range route.Methods {
+		if m == method {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,54 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/utils"
+)
+
+// StreamSSE は Server-Sent Events を中継するルートの Stream の値
+const StreamSSE = "sse"
+
+// Lambda の実行期限の何秒前に SSE のストリームを終了するか。
+// 期限で強制終了されるとクライアントにはエラーとして見えるため、余裕を持ってストリームを閉じる
+const sseDeadlineMargin = 2 * time.Second
+
+// SSE のルートをバックエンドへ転送する。
+// チャンクを受け取るたびにフラッシュし、ボディの変換は行わない。
+// レスポンスをバッファする構成 (API Gateway、ALB、バッファモードの Function URL) では中継できないため 501 を返す
+func (r *Router) forwardSSE(w http.ResponseWriter, req *http.Request, route *Route, principal *auth.Principal) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.WriteError(w, http.StatusNotImplemented, "Streaming Not Supported")
+		return
+	}
+
+	// Lambda では実行期限より前にバックエンドへのリクエストを打ち切り、ストリームを正常に終了する
+	ctx := req.Context()
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-sseDeadlineMargin))
+		defer cancel()
+	}
+
+	r.proxy(&flushWriter{ResponseWriter: w, flusher: flusher}, req.WithContext(ctx), route.upstreamURL(), principal)
+}
+
+// flushWriter は書き込みのたびにフラッシュする
+type flushWriter struct {
+	http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (w *flushWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.flusher.Flush()
+	return n, err
+}
+
+func (w *flushWriter) Flush() {
+	w.flusher.Flush()
+}
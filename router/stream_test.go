@@ -0,0 +1,116 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aki80204/go-gateway/auth"
+)
+
+// フラッシュのたびにその時点のボディを通知するレコーダー
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed chan string
+}
+
+func (r *flushRecorder) Flush() {
+	select {
+	case r.flushed <- r.Body.String():
+	default:
+	}
+}
+
+// http.Flusher を実装しない ResponseWriter
+type bufferedWriter struct {
+	header http.Header
+	code   int
+}
+
+func (w *bufferedWriter) Header() http.Header         { return w.header }
+func (w *bufferedWriter) WriteHeader(code int)        { w.code = code }
+func (w *bufferedWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func sseRouter(upstream string) (*Router, *Route) {
+	r := NewRouterWithRoutes(nil, []Route{{Path: "/api/notifications/stream", Methods: []string{GET}, Upstream: upstream, Stream: StreamSSE}})
+	return r, &r.Routes()[0]
+}
+
+func TestForward_SSEFlushesEachChunk(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Content-Type に関係なく、ルートの設定でフラッシュする
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = w.Write([]byte("data: 2\n\n"))
+	}))
+	defer backend.Close()
+
+	r, route := sseRouter(backend.URL)
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan string, 16)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Forward(rec, makeRequest("/api/notifications/stream", GET), route, nil)
+	}()
+
+	timeout := time.After(5 * time.Second)
+	for received := false; !received; {
+		select {
+		case body := <-rec.flushed:
+			received = strings.Contains(body, "data: 1")
+		case <-timeout:
+			t.Fatal("最初のイベントがフラッシュされませんでした")
+		}
+	}
+	close(release)
+	<-done
+
+	if rec.Body.String() != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("Body = %q", rec.Body.String())
+	}
+}
+
+func TestForward_SSEClosesBeforeDeadline(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer backend.Close()
+
+	r, route := sseRouter(backend.URL)
+	deadline := time.Now().Add(sseDeadlineMargin + 300*time.Millisecond)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	rec := httptest.NewRecorder()
+
+	r.Forward(rec, makeRequest("/api/notifications/stream", GET).WithContext(ctx), route, nil)
+
+	if !time.Now().Before(deadline) {
+		t.Errorf("実行期限までにストリームを終了しませんでした")
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "data: 1\n\n" {
+		t.Errorf("response = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestForward_SSERequiresStreaming(t *testing.T) {
+	called := false
+	r := NewRouterWithRoutes(func(w http.ResponseWriter, _ *http.Request, _ string, _ *auth.Principal) {
+		called = true
+	}, []Route{{Path: "/api/notifications/stream", Methods: []string{GET}, Upstream: "https://x", Stream: StreamSSE}})
+	w := &bufferedWriter{header: http.Header{}}
+
+	r.Forward(w, makeRequest("/api/notifications/stream", GET), &r.Routes()[0], nil)
+
+	if w.code != http.StatusNotImplemented || called {
+		t.Errorf("StatusCode = %d, proxy called = %v, want 501 と proxy を呼ばない", w.code, called)
+	}
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/transform"
+)
+
+// Transform はリクエストとバックエンドのレスポンスの JSON ボディの変換
+type Transform struct {
+	// Request はバックエンドへ転送する前にリクエストのボディへ適用する
+	Request transform.Pipeline `json:"request"`
+	// Response はクライアントへ返す前にレスポンスのボディへ適用する
+	Response transform.Pipeline `json:"response"`
+}
+
+func (route *Route) validateTransform() error {
+	t := route.Transform
+	if err := t.Request.Compile(); err != nil {
+		return err
+	}
+	if err := t.Response.Compile(); err != nil {
+		return err
+	}
+	if len(t.Response) > 0 && route.Stream == StreamSSE {
+		return errors.New(`stream: "sse" のルートではレスポンスを変換できません`)
+	}
+	return nil
+}
+
+// JSON のリクエストボディを変換し、r.Body を変換後のボディに置き換える。ボディがない場合は何もしない
+func (route *Route) transformRequest(req *http.Request, principal *auth.Principal) error {
+	if route.Transform == nil || len(route.Transform.Request) == 0 || !isJSONMediaType(req.Header.Get("Content-Type")) {
+		return nil
+	}
+	data, err := readBody(req)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var body any
+	if err := decoder.Decode(&body); err != nil {
+		return err
+	}
+	body, err = route.Transform.Request.Apply(&transform.Context{Request: req, Principal: principal}, body)
+	if err != nil {
+		return err
+	}
+	data, err = encodeJSON(body)
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return nil
+}
+
+// レスポンスのボディを変換する
+func (route *Route) transformResponse(req *http.Request, resp *bufferedResponse, principal *auth.Principal) error {
+	body, err := resp.decodeJSON()
+	if err != nil {
+		// 変換できないボディは、バックエンドの契約のままクライアントへ返さない
+		return err
+	}
+	body, err = route.Transform.Response.Apply(&transform.Context{
+		Request: req, Principal: principal, StatusCode: resp.statusCode(), Header: resp.header,
+	}, body)
+	if err != nil {
+		return err
+	}
+	return resp.setJSON(body)
+}
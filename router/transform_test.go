@@ -0,0 +1,78 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/transform"
+)
+
+func TestForward_Transform(t *testing.T) {
+	routes := writeRoutesIn(t, t.TempDir(), `{"routes": [{"path": "/api/transfers", "methods": ["POST"], "upstream": "https://payments.internal",
+		"masking": [{"path": "$.data.account_number", "keep_last": 4}],
+		"transform": {
+			"request": [
+				{"op": "case", "case": "snake_case"},
+				{"op": "add", "path": "/requested_by", "value": "{sub}"},
+				{"op": "wrap", "path": "/transfer"}
+			],
+			"response": [
+				{"op": "unwrap", "path": "/data"},
+				{"op": "case", "case": "camelCase"}
+			]
+		}}]}`)
+
+	var gotBody string
+	var gotLength int64
+	r := NewRouterWithRoutes(func(w http.ResponseWriter, req *http.Request, targetBaseURL string, principal *auth.Principal) {
+		data, _ := io.ReadAll(req.Body)
+		gotBody, gotLength = string(data), req.ContentLength
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"transfer_id": "t-1", "account_number": "1234567890"}}`))
+	}, routes)
+
+	req := httptest.NewRequest(POST, "/api/transfers", strings.NewReader(`{"amount": 100, "toAccount": "7654321"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.Route(rec, req, &auth.Principal{Subject: "user-123"})
+
+	if want := `{"transfer":{"amount":100,"requested_by":"user-123","to_account":"7654321"}}`; gotBody != want || gotLength != int64(len(want)) {
+		t.Errorf("バックエンドへのボディ = %s (%d), want %s", gotBody, gotLength, want)
+	}
+	// マスクはバックエンドの契約の名前で指定し、クライアント向けの変換より前に行う
+	if want := `{"accountNumber":"******7890","transferId":"t-1"}`; rec.Code != 200 || rec.Body.String() != want {
+		t.Errorf("Route() = %d %s, want %s", rec.Code, rec.Body.String(), want)
+	}
+}
+
+func TestForward_TransformRequestInvalidJSON(t *testing.T) {
+	routes := []Route{{
+		Path: "/api/transfers", Methods: []string{POST}, Upstream: "https://payments.internal",
+		Transform: &Transform{Request: transform.Pipeline{{Op: transform.OpRemove, Path: "/internal"}}},
+	}}
+	if err := routes[0].validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	r := NewRouterWithRoutes(mockProxyRequest, routes)
+
+	req := httptest.NewRequest(POST, "/api/transfers", strings.NewReader(`{"amount"`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.Route(rec, req, &auth.Principal{Subject: "user-123"})
+	if rec.Code != 400 {
+		t.Errorf("Route() StatusCode = %d, want 400", rec.Code)
+	}
+
+	// JSON 以外のボディは変換しない
+	req = httptest.NewRequest(POST, "/api/transfers", strings.NewReader(`amount=100`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	r.Route(rec, req, &auth.Principal{Subject: "user-123"})
+	if rec.Code != 200 {
+		t.Errorf("Route() StatusCode = %d, want 200", rec.Code)
+	}
+}
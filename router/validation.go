@@ -0,0 +1,82 @@
+package router
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/aki80204/go-gateway/schema"
+)
+
+// Validation はリクエストを検証する JSON Schema (draft 2020-12) の設定。
+// パスはルーティング設定ディレクトリからの相対パスで、起動時に一度だけコンパイルする
+//
+//	{"body_schema": "schemas/transfer.json", "query_schema": "schemas/transfer-query.json"}
+type Validation struct {
+	BodySchema  string `json:"body_schema"`
+	QuerySchema string `json:"query_schema"`
+
+	body  *schema.Schema
+	query *schema.Schema
+}
+
+func (v *Validation) compile(c *schema.Compiler) error {
+	var err error
+	if v.BodySchema != "" {
+		if v.body, err = c.Compile(v.BodySchema); err != nil {
+			return err
+		}
+	}
+	if v.QuerySchema != "" {
+		if v.query, err = c.Compile(v.QuerySchema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateRequest はボディとクエリパラメータをルートのスキーマで検証し、違反した箇所を返す。
+// OpenAPI 定義から導いたルートは operation の定義でパラメータとボディも検証する。
+// ボディは読み込んだ後に r.Body へ戻すため、そのままバックエンドへ転送できる
+func (route *Route) ValidateRequest(r *http.Request) ([]schema.Violation, error) {
+	var violations []schema.Violation
+	if route.openAPI != nil {
+		params, _ := route.matchPath(r.URL.EscapedPath())
+		violations = append(violations, route.openAPI.ValidateRequest(r, params)...)
+	}
+
+	v := route.Validation
+	if v == nil {
+		return violations, nil
+	}
+	if v.query != nil {
+		violations = append(violations, v.query.Validate(schema.InQuery, v.query.QueryValue(r.URL.Query()))...)
+	}
+	if v.body != nil {
+		body, err := readBody(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(body) == 0 {
+			violations = append(violations, schema.Violation{In: schema.InBody, Pointer: "", Detail: "request body is required"})
+		} else {
+			violations = append(violations, v.body.ValidateJSON(schema.InBody, bytes.NewReader(body))...)
+		}
+	}
+	return violations, nil
+}
+
+// ボディを読み込み、r.Body を読み直せる状態に戻す
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return body, nil
+}
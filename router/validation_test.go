@@ -0,0 +1,68 @@
+package router
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateRequest(t *testing.T) {
+	dir := writeRoutes(t, `{"routes": [
+		{"path": "/api/payments/transfer", "methods": ["POST"], "upstream": "https://payments.internal",
+		 "validation": {"body_schema": "schemas/transfer.json", "query_schema": "schemas/transfer-query.json"}}
+	]}`)
+	if err := os.MkdirAll(filepath.Join(dir, "schemas"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"transfer.json":       `{"type": "object", "required": ["amount"], "properties": {"amount": {"type": "integer", "minimum": 1}}}`,
+		"transfer-query.json": `{"type": "object", "properties": {"dry_run": {"enum": ["true", "false"]}}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "schemas", name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	routes, err := LoadRoutes(dir)
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	route := &routes[0]
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		want   int
+	}{
+		{name: "正常系: 適合する", target: "/api/payments/transfer?dry_run=true", body: `{"amount": 100}`},
+		{name: "異常系: ボディとクエリの違反", target: "/api/payments/transfer?dry_run=yes", body: `{"amount": 0}`, want: 2},
+		{name: "異常系: ボディがない", target: "/api/payments/transfer", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.target, strings.NewReader(tt.body))
+			violations, err := route.ValidateRequest(r)
+			if err != nil {
+				t.Fatalf("ValidateRequest() error = %v", err)
+			}
+			if len(violations) != tt.want {
+				t.Errorf("ValidateRequest() = %+v, want %d 件", violations, tt.want)
+			}
+			if body, _ := io.ReadAll(r.Body); string(body) != tt.body {
+				t.Errorf("検証後の Body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestLoadRoutes_InvalidSchema(t *testing.T) {
+	dir := writeRoutes(t, `{"routes": [
+		{"path": "/api", "methods": ["POST"], "upstream": "https://x", "validation": {"body_schema": "missing.json"}}
+	]}`)
+	if _, err := LoadRoutes(dir); err == nil {
+		t.Errorf("LoadRoutes() スキーマがない場合に error = nil")
+	}
+}
@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Filter は v のオブジェクトからスキーマで宣言されていないプロパティを取り除く。
+// properties / patternProperties を宣言していないオブジェクトや、additionalProperties でプロパティの追加を許可したオブジェクトはそのまま残す。
+// allOf / anyOf / oneOf はいずれかで宣言されたプロパティを残す。v は取り除いた後の値で置き換えられる
+func (s *Schema) Filter(v any) any {
+	return filter([]*jsonschema.Schema{s.schema}, v)
+}
+
+func filter(schemas []*jsonschema.Schema, v any) any {
+	schemas = expand(schemas)
+	switch v := v.(type) {
+	case map[string]any:
+		declared, additional := false, false
+		var additionalSchemas []*jsonschema.Schema
+		for _, s := range schemas {
+			if len(s.Properties) > 0 || len(s.PatternProperties) > 0 || s.AdditionalProperties != nil {
+				declared = true
+			}
+			if allowsAdditional(s.AdditionalProperties) {
+				additional = true
+			}
+			if a, ok := s.AdditionalProperties.(*jsonschema.Schema); ok && allowsAdditional(a) {
+				additionalSchemas = append(additionalSchemas, a)
+			}
+		}
+		for name, value := range v {
+			props := propertySchemas(schemas, name)
+			if len(props) == 0 {
+				props = additionalSchemas
+			}
+			if len(props) == 0 {
+				if declared && !additional {
+					delete(v, name)
+				}
+				continue
+			}
+			v[name] = filter(props, value)
+		}
+	case []any:
+		for i, item := range v {
+			if items := itemSchemas(schemas, i); len(items) > 0 {
+				v[i] = filter(items, item)
+			}
+		}
+	}
+	return v
+}
+
+// $ref と allOf / anyOf / oneOf / then / else をたどり、値に適用されうるスキーマを列挙する
+func expand(schemas []*jsonschema.Schema) []*jsonschema.Schema {
+	var result []*jsonschema.Schema
+	seen := make(map[*jsonschema.Schema]bool)
+	var walk func(s *jsonschema.Schema)
+	walk = func(s *jsonschema.Schema) {
+		if s == nil || seen[s] {
+			return
+		}
+		seen[s] = true
+		result = append(result, s)
+		walk(s.Ref)
+		walk(s.Then)
+		walk(s.Else)
+		for _, list := range [][]*jsonschema.Schema{s.AllOf, s.AnyOf, s.OneOf} {
+			for _, sub := range list {
+				walk(sub)
+			}
+		}
+	}
+	for _, s := range schemas {
+		walk(s)
+	}
+	return result
+}
+
+// additionalProperties が true またはスキーマの場合は宣言していないプロパティも許可する
+func allowsAdditional(additional any) bool {
+	switch a := additional.(type) {
+	case bool:
+		return a
+	case *jsonschema.Schema:
+		return a.Bool == nil || *a.Bool
+	}
+	return false
+}
+
+func propertySchemas(schemas []*jsonschema.Schema, name string) []*jsonschema.Schema {
+	var result []*jsonschema.Schema
+	for _, s := range schemas {
+		if p, ok := s.Properties[name]; ok {
+			result = append(result, p)
+		}
+		for pattern, p := range s.PatternProperties {
+			if pattern.MatchString(name) {
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
+
+func itemSchemas(schemas []*jsonschema.Schema, index int) []*jsonschema.Schema {
+	var result []*jsonschema.Schema
+	for _, s := range schemas {
+		switch {
+		case index < len(s.PrefixItems):
+			result = append(result, s.PrefixItems[index])
+		case s.Items2020 != nil:
+			result = append(result, s.Items2020)
+		}
+		switch items := s.Items.(type) {
+		case *jsonschema.Schema:
+			result = append(result, items)
+		case []*jsonschema.Schema:
+			if index < len(items) {
+				result = append(result, items[index])
+			}
+		}
+	}
+	return result
+}
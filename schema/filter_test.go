@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+func TestSchema_Filter(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"account.json": `{
+			"type": "object",
+			"properties": {
+				"accountId": {"type": "string"},
+				"owner": {"$ref": "owner.json"},
+				"balances": {"type": "array", "items": {"type": "object", "properties": {"currency": {"type": "string"}}}},
+				"metadata": {"type": "object"},
+				"labels": {"type": "object", "additionalProperties": {"type": "object", "properties": {"value": {}}}}
+			},
+			"patternProperties": {"^x-": {}},
+			"allOf": [{"properties": {"status": {"type": "string"}}}]
+		}`,
+		"owner.json": `{"type": "object", "properties": {"name": {"type": "string"}}, "additionalProperties": false}`,
+	})
+	s, err := NewCompiler(dir).Compile("account.json")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	v, err := jsonschema.UnmarshalJSON(strings.NewReader(`{
+		"accountId": "1234567",
+		"status": "active",
+		"x-trace": "abc",
+		"internalNote": "削除される",
+		"owner": {"name": "山田", "nationalId": "削除される"},
+		"balances": [{"currency": "JPY", "raw": "削除される"}],
+		"metadata": {"any": "宣言がないオブジェクトは残す"},
+		"labels": {"tier": {"value": "gold", "debug": "削除される"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(s.Filter(v))
+
+	want := `{"accountId":"1234567","balances":[{"currency":"JPY"}],"labels":{"tier":{"value":"gold"}},` +
+		`"metadata":{"any":"宣言がないオブジェクトは残す"},"owner":{"name":"山田"},"status":"active","x-trace":"abc"}`
+	if string(got) != want {
+		t.Errorf("Filter() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+// Package schema は JSON Schema (draft 2020-12) でリクエストとバックエンドのレスポンスを検証する。
+// スキーマはルーティング設定ディレクトリから起動時に読み込んでコンパイルし、リクエストごとには検証だけを行う。
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// 検証する値の場所 (Violation.In)
+const (
+	InBody  = "body"
+	InQuery = "query"
+	// InResponse はステータスコードや Content-Type などレスポンスそのものの違反
+	InResponse = "response"
+)
+
+// Violation はスキーマに違反した箇所。Pointer は違反した値の JSON Pointer (RFC 6901)
+type Violation struct {
+	In      string `json:"in"`
+	Pointer string `json:"pointer"`
+	Detail  string `json:"detail"`
+}
+
+// Compiler はディレクトリ内のスキーマファイルをコンパイルする。
+// 同じ Compiler でコンパイルしたスキーマは $ref で参照するファイルを共有する
+type Compiler struct {
+	dir      string
+	compiler *jsonschema.Compiler
+}
+
+// NewCompiler は dir を基準にスキーマファイルを読み込む Compiler を生成する。$schema のないスキーマは draft 2020-12 として扱う
+func NewCompiler(dir string) *Compiler {
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	return &Compiler{dir: dir, compiler: c}
+}
+
+// Compile は dir からの相対パス file のスキーマをコンパイルする
+func (c *Compiler) Compile(file string) (*Schema, error) {
+	path, err := filepath.Abs(filepath.Join(c.dir, file))
+	if err != nil {
+		return nil, fmt.Errorf("スキーマのパスが不正です (%s): %w", file, err)
+	}
+	s, err := c.compiler.Compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("スキーマのコンパイルに失敗しました (%s): %w", file, err)
+	}
+	return &Schema{schema: s}, nil
+}
+
+// Schema はコンパイル済みのスキーマ
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Validate は v を検証し、違反した箇所を in の Violation として返す。適合する場合は nil を返す
+func (s *Schema) Validate(in string, v any) []Violation {
+	err := s.schema.Validate(v)
+	if err == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []Violation{{In: in, Pointer: "", Detail: err.Error()}}
+	}
+
+	var violations []Violation
+	for _, unit := range validationErr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		violations = append(violations, Violation{In: in, Pointer: unit.InstanceLocation, Detail: unit.Error.String()})
+	}
+	return violations
+}
+
+// ValidateJSON は JSON のボディを検証する。JSON として解析できない場合も Violation として返す
+func (s *Schema) ValidateJSON(in string, body io.Reader) []Violation {
+	v, err := jsonschema.UnmarshalJSON(body)
+	if err != nil {
+		return []Violation{{In: in, Pointer: "", Detail: "invalid JSON"}}
+	}
+	return s.Validate(in, v)
+}
+
+// QueryValue はクエリパラメータを検証用の値に変換する。
+// 1 つだけのパラメータは値、複数あるパラメータは配列にする。値は文字列として扱うが、
+// スキーマのプロパティが integer / number / boolean を宣言している場合はその型に変換し、
+// array を宣言している場合は 1 つだけでも配列にする
+func (s *Schema) QueryValue(values url.Values) map[string]any {
+	query := make(map[string]any, len(values))
+	for k, vs := range values {
+		prop := property(s.schema, k)
+		if len(vs) == 1 && !declares(prop, "array") {
+			query[k] = coerce(prop, vs[0])
+			continue
+		}
+		item := itemSchema(prop)
+		items := make([]any, len(vs))
+		for i, v := range vs {
+			items[i] = coerce(item, v)
+		}
+		query[k] = items
+	}
+	return query
+}
+
+// JSON の数値の表記
+var numberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// 文字列の値を s が宣言する型に変換する。string を許す場合や変換できない場合は文字列のまま返し、型の違反として検出させる
+func coerce(s *jsonschema.Schema, v string) any {
+	if s == nil || s.Types == nil || declares(s, "string") {
+		return v
+	}
+	if (declares(s, "integer") || declares(s, "number")) && numberPattern.MatchString(v) {
+		return json.Number(v)
+	}
+	if declares(s, "boolean") && (v == "true" || v == "false") {
+		return v == "true"
+	}
+	return v
+}
+
+func declares(s *jsonschema.Schema, typ string) bool {
+	return s != nil && s.Types != nil && slices.Contains(s.Types.ToStrings(), typ)
+}
+
+// $ref をたどった先のスキーマで name のプロパティを探す
+func property(s *jsonschema.Schema, name string) *jsonschema.Schema {
+	for ; s != nil; s = s.Ref {
+		if p, ok := s.Properties[name]; ok {
+			return resolve(p)
+		}
+	}
+	return nil
+}
+
+// 配列の要素のスキーマ。draft 2020-12 の items と、それ以前の単一スキーマの items に対応する
+func itemSchema(s *jsonschema.Schema) *jsonschema.Schema {
+	if s == nil {
+		return nil
+	}
+	if s.Items2020 != nil {
+		return resolve(s.Items2020)
+	}
+	if items, ok := s.Items.(*jsonschema.Schema); ok {
+		return resolve(items)
+	}
+	return nil
+}
+
+// 型の宣言のない $ref だけのスキーマは参照先をたどる
+func resolve(s *jsonschema.Schema) *jsonschema.Schema {
+	for s != nil && s.Types == nil && s.Ref != nil {
+		s = s.Ref
+	}
+	return s
+}
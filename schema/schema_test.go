@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSchemas(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSchema_ValidateJSON(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"schemas/transfer.json": `{
+			"type": "object",
+			"required": ["amount", "to"],
+			"properties": {"amount": {"type": "integer", "minimum": 1}, "to": {"$ref": "account.json"}},
+			"additionalProperties": false
+		}`,
+		"schemas/account.json": `{"type": "string", "pattern": "^[0-9]{7}$"}`,
+	})
+	s, err := NewCompiler(dir).Compile("schemas/transfer.json")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantPointers []string
+	}{
+		{name: "正常系: 適合する", body: `{"amount": 100, "to": "1234567"}`},
+		{name: "異常系: 複数の違反", body: `{"amount": 0, "to": "12", "memo": "x"}`, wantPointers: []string{"", "/amount", "/to"}},
+		{name: "異常系: 必須の項目がない", body: `{"amount": 1}`, wantPointers: []string{""}},
+		{name: "異常系: JSON ではない", body: `amount=1`, wantPointers: []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := s.ValidateJSON(InBody, strings.NewReader(tt.body))
+			if len(violations) != len(tt.wantPointers) {
+				t.Fatalf("ValidateJSON() = %+v, want %d 件", violations, len(tt.wantPointers))
+			}
+			got := map[string]bool{}
+			for _, v := range violations {
+				if v.In != InBody || v.Detail == "" {
+					t.Errorf("Violation = %+v", v)
+				}
+				got[v.Pointer] = true
+			}
+			for _, p := range tt.wantPointers {
+				if !got[p] {
+					t.Errorf("ValidateJSON() に %q の違反がありません: %+v", p, violations)
+				}
+			}
+		})
+	}
+}
+
+func TestSchema_ValidateQuery(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"query.json": `{
+			"type": "object",
+			"required": ["from"],
+			"properties": {
+				"from": {"type": "string", "format": "date", "pattern": "^\\d{4}-\\d{2}-\\d{2}$"},
+				"status": {"type": "array", "items": {"enum": ["posted", "pending"]}}
+			}
+		}`,
+	})
+	s, err := NewCompiler(dir).Compile("query.json")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	valid, _ := url.ParseQuery("from=2026-01-01&status=posted&status=pending")
+	if violations := s.Validate(InQuery, s.QueryValue(valid)); violations != nil {
+		t.Errorf("Validate() = %+v, want nil", violations)
+	}
+	invalid, _ := url.ParseQuery("from=yesterday&status=posted&status=deleted")
+	violations := s.Validate(InQuery, s.QueryValue(invalid))
+	if len(violations) != 2 || violations[0].In != InQuery {
+		t.Errorf("Validate() = %+v, want /from と /status/1 の違反", violations)
+	}
+}
+
+func TestSchema_QueryValue(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"query.json": `{
+			"type": "object",
+			"properties": {
+				"limit": {"type": "integer", "maximum": 100},
+				"ratio": {"type": "number"},
+				"detail": {"type": "boolean"},
+				"ids": {"type": "array", "items": {"$ref": "#/$defs/id"}},
+				"q": {"type": ["string", "null"]}
+			},
+			"$defs": {"id": {"type": "integer"}}
+		}`,
+	})
+	s, err := NewCompiler(dir).Compile("query.json")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		wantViolations int
+	}{
+		{name: "integer", query: "limit=10"},
+		{name: "number と boolean", query: "ratio=0.5&detail=true"},
+		{name: "1 つだけの配列の要素", query: "ids=7"},
+		{name: "複数の配列の要素", query: "ids=7&ids=8"},
+		{name: "string を許すプロパティは変換しない", query: "q=10"},
+		{name: "宣言のないパラメータは文字列のまま", query: "other=10"},
+		{name: "違反: 整数でない", query: "limit=ten", wantViolations: 1},
+		{name: "違反: 上限を超える", query: "limit=101", wantViolations: 1},
+		{name: "違反: 真偽値でない", query: "detail=yes", wantViolations: 1},
+		{name: "違反: 配列の要素が整数でない", query: "ids=7&ids=x", wantViolations: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			violations := s.Validate(InQuery, s.QueryValue(values))
+			if len(violations) != tt.wantViolations {
+				t.Errorf("Validate(%s) = %+v, want %d 件の違反", tt.query, violations, tt.wantViolations)
+			}
+		})
+	}
+}
+
+func TestCompiler_Invalid(t *testing.T) {
+	dir := writeSchemas(t, map[string]string{
+		"broken.json":  `{"type": `,
+		"invalid.json": `{"type": "objekt"}`,
+	})
+	c := NewCompiler(dir)
+	for _, file := range []string{"broken.json", "invalid.json", "missing.json"} {
+		if _, err := c.Compile(file); err == nil {
+			t.Errorf("Compile(%s) error = nil, want error", file)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultListenAddr = ":8080"
+	shutdownTimeout   = 10 * time.Second
+)
+
+// serveMode は -serve フラグまたは GATEWAY_MODE=serve が指定されていれば true を返す
+func serveMode(serveFlag bool) bool {
+	return serveFlag || os.Getenv("GATEWAY_MODE") == "serve"
+}
+
+// listenAddr は待ち受けるアドレスを -addr フラグ、LISTEN_ADDR の順に決める (既定: ":8080")
+func listenAddr(addrFlag string) string {
+	if addrFlag != "" {
+		return addrFlag
+	}
+	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
+		return addr
+	}
+	return defaultListenAddr
+}
+
+// tlsConfigFromEnv は serve モードで TLS を終端する設定を環境変数から読み込む。TLS_CERT_FILE が未設定の場合は nil を返す
+//   - TLS_CERT_FILE / TLS_KEY_FILE  サーバー証明書と秘密鍵 (PEM)
+//   - TLS_CLIENT_CA_FILE           クライアント証明書を検証する CA 証明書 (PEM)。設定した場合のみクライアント証明書を受け取る
+//
+// クライアント証明書は提示された場合のみ検証するため、mtls を指定していないルートには証明書なしで接続できる。
+func tlsConfigFromEnv() (*tls.Config, error) {
+	certFile := os.Getenv("TLS_CERT_FILE")
+	keyFile := os.Getenv("TLS_KEY_FILE")
+	if certFile == "" && keyFile == "" {
+		if os.Getenv("TLS_CLIENT_CA_FILE") != "" {
+			return nil, errors.New("TLS_CLIENT_CA_FILE を使うには TLS_CERT_FILE と TLS_KEY_FILE を設定してください")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("TLS_CERT_FILE と TLS_KEY_FILE は両方設定してください")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("サーバー証明書の読み込みに失敗しました: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile := os.Getenv("TLS_CLIENT_CA_FILE"); caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("クライアント証明書の CA の読み込みに失敗しました: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("クライアント証明書の CA に証明書がありません (%s)", caFile)
+		}
+		// mtls の認証は検証済みの証明書を前提とするため、検証しない RequestClientCert は使わない
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		cfg.ClientCAs = pool
+	}
+	return cfg, nil
+}
+
+// serve は Lambda の外 (ECS やローカル) で HTTP サーバーとしてゲートウェイを起動する。
+// Lambda と同じ handler を使うため、認証・ルーティング・プロキシは Lambda と同じ処理になる。
+// tlsConfig を指定した場合は HTTPS で待ち受け、検証したクライアント証明書を mtls の認証に使う。
+// SIGINT / SIGTERM を受け取ると処理中のリクエストを待ってから終了する。
+func serve(addr string, handler http.Handler, tlsConfig *tls.Config) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			log.Printf("HTTPS サーバーを起動しました: %s", addr)
+			// 証明書は TLSConfig に設定済み
+			errCh <- server.ListenAndServeTLS("", "")
+			return
+		}
+		log.Printf("HTTP サーバーを起動しました: %s", addr)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("HTTP サーバーを停止します")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
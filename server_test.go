@@ -0,0 +1,299 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aki80204/go-gateway/auth"
+	"github.com/aki80204/go-gateway/router"
+)
+
+// serve モードでも Lambda と同じ認証・ルーティングを通る
+func TestServeGateway_HTTPServer(t *testing.T) {
+	t.Setenv("ACCOUNT_SERVICE_URL", "https://account.example.com")
+	validator := fakeValidator{tokens: map[string]jwt.MapClaims{
+		"valid-token": {"sub": "user-123", "iss": "https://tenant.auth0.com/"},
+	}}
+	captured := setupHandler(t, &auth.BearerAuthenticator{Validator: validator}, nil)
+	server := httptest.NewServer(http.HandlerFunc(serveGateway))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		path           string
+		authorization  string
+		wantStatusCode int
+		wantCalled     bool
+	}{
+		{name: "有効なトークン", path: "/api/customers/account", authorization: "Bearer valid-token", wantStatusCode: 200, wantCalled: true},
+		{name: "無効なトークン", path: "/api/customers/account", authorization: "Bearer invalid-token", wantStatusCode: 401},
+		{name: "未定義のパス", path: "/unknown", authorization: "Bearer valid-token", wantStatusCode: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured.called = false
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			req.Header.Set("Authorization", tt.authorization)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("リクエストに失敗しました: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatusCode {
+				t.Errorf("StatusCode = %d, want %d", resp.StatusCode, tt.wantStatusCode)
+			}
+			if captured.called != tt.wantCalled {
+				t.Errorf("proxy called = %v, want %v", captured.called, tt.wantCalled)
+			}
+			if tt.wantCalled && captured.principal.Subject != "user-123" {
+				t.Errorf("principal.Subject = %q", captured.principal.Subject)
+			}
+		})
+	}
+}
+
+func TestServeModeAndListenAddr(t *testing.T) {
+	t.Setenv("GATEWAY_MODE", "")
+	t.Setenv("LISTEN_ADDR", "")
+	if serveMode(false) {
+		t.Error("serveMode(false) = true, want false")
+	}
+	if !serveMode(true) {
+		t.Error("serveMode(true) = false, want true")
+	}
+	if got := listenAddr(""); got != ":8080" {
+		t.Errorf("listenAddr() = %q, want :8080", got)
+	}
+
+	t.Setenv("GATEWAY_MODE", "serve")
+	t.Setenv("LISTEN_ADDR", ":9000")
+	if !serveMode(false) {
+		t.Error("GATEWAY_MODE=serve で serveMode() = false")
+	}
+	if got := listenAddr(""); got != ":9000" {
+		t.Errorf("listenAddr() = %q, want :9000", got)
+	}
+	if got := listenAddr(":7000"); got != ":7000" {
+		t.Errorf("listenAddr(:7000) = %q, want :7000", got)
+	}
+}
+
+// テスト用の証明書と秘密鍵。parent が nil の場合は自己署名の CA 証明書を生成する
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, commonName string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("鍵の生成に失敗しました: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	issuer, signer := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		template.ExtKeyUsage = nil
+	} else {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("証明書の生成に失敗しました: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("秘密鍵の変換に失敗しました: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM(), c.keyPEM(t))
+	if err != nil {
+		t.Fatalf("証明書の読み込みに失敗しました: %v", err)
+	}
+	return cert
+}
+
+func writeTestFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serve モードで TLS を終端し、検証したクライアント証明書で mtls のルートを認証する
+func TestServeGateway_TLSClientCertificate(t *testing.T) {
+	ca := newTestCert(t, "gateway-test-ca", nil, 0)
+	serverCert := newTestCert(t, "127.0.0.1", ca, x509.ExtKeyUsageServerAuth)
+	clientCert := newTestCert(t, "payments-batch", ca, x509.ExtKeyUsageClientAuth)
+	rogueCA := newTestCert(t, "rogue-ca", nil, 0)
+	rogueCert := newTestCert(t, "payments-batch", rogueCA, x509.ExtKeyUsageClientAuth)
+
+	dir := t.TempDir()
+	t.Setenv("TLS_CERT_FILE", writeTestFile(t, dir, "server.pem", serverCert.certPEM()))
+	t.Setenv("TLS_KEY_FILE", writeTestFile(t, dir, "server-key.pem", serverCert.keyPEM(t)))
+	t.Setenv("TLS_CLIENT_CA_FILE", writeTestFile(t, dir, "client-ca.pem", ca.certPEM()))
+	tlsConfig, err := tlsConfigFromEnv()
+	if err != nil {
+		t.Fatalf("tlsConfigFromEnv() error = %v", err)
+	}
+
+	mtls, err := auth.NewMTLSAuthenticator(auth.MTLSConfig{})
+	if err != nil {
+		t.Fatalf("NewMTLSAuthenticator() error = %v", err)
+	}
+	authenticators := map[string]auth.Authenticator{auth.MethodMTLS: mtls}
+	routes := []router.Route{{
+		Path: "/api/payments/transfer", Methods: []string{"POST"}, Upstream: "https://payments.internal",
+		Auth: []string{auth.MethodMTLS},
+	}}
+	captured := setupRoutedHandler(t, defaultAuthenticator(authenticators), nil, routes, authenticators)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(serveGateway))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	// サーバーが示す CA に関係なく証明書を提示し、サーバー側の検証を確認する
+	clientWith := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs: roots,
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if len(certs) == 0 {
+					return &tls.Certificate{}, nil
+				}
+				return &certs[0], nil
+			},
+		}}}
+	}
+
+	tests := []struct {
+		name           string
+		client         *http.Client
+		wantStatusCode int
+		wantErr        bool
+	}{
+		{name: "正常系: CA が発行したクライアント証明書", client: clientWith(clientCert.tlsCertificate(t)), wantStatusCode: 200},
+		{name: "異常系: クライアント証明書なし", client: clientWith(), wantStatusCode: 401},
+		{name: "異常系: 別の CA が発行したクライアント証明書", client: clientWith(rogueCert.tlsCertificate(t)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured.principal = nil
+			resp, err := tt.client.Post(server.URL+"/api/payments/transfer", "application/json", nil)
+			if tt.wantErr {
+				if err == nil {
+					_ = resp.Body.Close()
+					t.Fatalf("StatusCode = %d, want TLS ハンドシェイクのエラー", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("リクエストに失敗しました: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatusCode {
+				t.Errorf("StatusCode = %d, want %d", resp.StatusCode, tt.wantStatusCode)
+			}
+			if tt.wantStatusCode == 200 {
+				if p := captured.principal; p == nil || p.ClientCert == nil || p.ClientCert.Identity != "payments-batch" {
+					t.Errorf("proxy に渡された principal = %+v, want 証明書 payments-batch", p)
+				}
+			}
+		})
+	}
+}
+
+func TestTLSConfigFromEnv(t *testing.T) {
+	ca := newTestCert(t, "gateway-test-ca", nil, 0)
+	serverCert := newTestCert(t, "127.0.0.1", ca, x509.ExtKeyUsageServerAuth)
+	dir := t.TempDir()
+	certFile := writeTestFile(t, dir, "server.pem", serverCert.certPEM())
+	keyFile := writeTestFile(t, dir, "server-key.pem", serverCert.keyPEM(t))
+	emptyCA := writeTestFile(t, dir, "empty.pem", []byte("not a certificate"))
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantNil    bool
+		wantClient tls.ClientAuthType
+		wantErr    bool
+	}{
+		{name: "未設定の場合は HTTP", env: map[string]string{}, wantNil: true},
+		{name: "クライアント証明書を受け取らない", env: map[string]string{"TLS_CERT_FILE": certFile, "TLS_KEY_FILE": keyFile}, wantClient: tls.NoClientCert},
+		{name: "クライアント証明書を検証する", env: map[string]string{"TLS_CERT_FILE": certFile, "TLS_KEY_FILE": keyFile, "TLS_CLIENT_CA_FILE": certFile}, wantClient: tls.VerifyClientCertIfGiven},
+		{name: "エラー: 秘密鍵がない", env: map[string]string{"TLS_CERT_FILE": certFile}, wantErr: true},
+		{name: "エラー: サーバー証明書なしで CA だけ設定", env: map[string]string{"TLS_CLIENT_CA_FILE": certFile}, wantErr: true},
+		{name: "エラー: CA に証明書がない", env: map[string]string{"TLS_CERT_FILE": certFile, "TLS_KEY_FILE": keyFile, "TLS_CLIENT_CA_FILE": emptyCA}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_CLIENT_CA_FILE"} {
+				t.Setenv(k, tt.env[k])
+			}
+			cfg, err := tlsConfigFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("tlsConfigFromEnv() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tlsConfigFromEnv() error = %v", err)
+			}
+			if tt.wantNil {
+				if cfg != nil {
+					t.Errorf("tlsConfigFromEnv() = %+v, want nil", cfg)
+				}
+				return
+			}
+			if cfg == nil || cfg.ClientAuth != tt.wantClient {
+				t.Errorf("tlsConfigFromEnv() ClientAuth = %v, want %v", cfg, tt.wantClient)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package transform
+
+import (
+	"strings"
+	"unicode"
+)
+
+// キー名の変換 (Op.Case)
+const (
+	CaseSnake = "snake_case"
+	CaseCamel = "camelCase"
+)
+
+// convertKeys はオブジェクトのキーを再帰的に convert で変換する
+func convertKeys(v any, convert func(string) string) any {
+	switch c := v.(type) {
+	case map[string]any:
+		converted := make(map[string]any, len(c))
+		for k, child := range c {
+			converted[convert(k)] = convertKeys(child, convert)
+		}
+		return converted
+	case []any:
+		for i, child := range c {
+			c[i] = convertKeys(child, convert)
+		}
+	}
+	return v
+}
+
+// toSnake は accountNumber や userID を account_number、user_id に変換する
+func toSnake(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && runes[i-1] != '_' {
+				prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// toCamel は account_number を accountNumber に変換する。先頭の _ はそのまま残す
+func toCamel(s string) string {
+	trimmed := strings.TrimLeft(s, "_")
+	prefix := s[:len(s)-len(trimmed)]
+	parts := strings.Split(trimmed, "_")
+	var b strings.Builder
+	b.WriteString(prefix)
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		if i == 0 {
+			b.WriteString(part)
+			continue
+		}
+		runes := []rune(part)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	return b.String()
+}
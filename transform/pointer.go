@@ -0,0 +1,129 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSON Pointer (RFC 6901) をトークンに分解する。"" はボディ全体を指す
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON Pointer は / で始めてください: %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// get は tokens の位置の値を返す
+func get(v any, tokens []string) (any, bool) {
+	for _, token := range tokens {
+		switch c := v.(type) {
+		case map[string]any:
+			child, ok := c[token]
+			if !ok {
+				return nil, false
+			}
+			v = child
+		case []any:
+			i, ok := arrayIndex(token, len(c))
+			if !ok {
+				return nil, false
+			}
+			v = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// set は tokens の位置に value を設定した v を返す。
+// 途中のオブジェクトがない場合は作成する。配列では insert の場合に要素を挿入し、"-" は末尾への追加とする
+func set(v any, tokens []string, value any, insert bool) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+	switch c := v.(type) {
+	case nil:
+		child, err := set(nil, rest, value, insert)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{token: child}, nil
+	case map[string]any:
+		child, err := set(c[token], rest, value, insert)
+		if err != nil {
+			return nil, err
+		}
+		c[token] = child
+		return c, nil
+	case []any:
+		if token == "-" && len(rest) == 0 {
+			return append(c, value), nil
+		}
+		if insert && len(rest) == 0 {
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i > len(c) {
+				return nil, fmt.Errorf("配列の添字が不正です: %q", token)
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+		i, ok := arrayIndex(token, len(c))
+		if !ok {
+			return nil, fmt.Errorf("配列の添字が不正です: %q", token)
+		}
+		child, err := set(c[i], rest, value, insert)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = child
+		return c, nil
+	default:
+		return nil, fmt.Errorf("オブジェクトでも配列でもない値の下には設定できません: %q", token)
+	}
+}
+
+// remove は tokens の位置の値を取り除いた v を返す。値がない場合は v をそのまま返す
+func remove(v any, tokens []string) any {
+	if len(tokens) == 0 {
+		return nil
+	}
+	token, rest := tokens[0], tokens[1:]
+	switch c := v.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			delete(c, token)
+		} else if child, ok := c[token]; ok {
+			c[token] = remove(child, rest)
+		}
+	case []any:
+		i, ok := arrayIndex(token, len(c))
+		if !ok {
+			return v
+		}
+		if len(rest) == 0 {
+			return append(c[:i], c[i+1:]...)
+		}
+		c[i] = remove(c[i], rest)
+	}
+	return v
+}
+
+func arrayIndex(token string, length int) (int, bool) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i >= length {
+		return 0, false
+	}
+	return i, true
+}
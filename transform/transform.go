@@ -0,0 +1,295 @@
+// Package transform はリクエストとレスポンスの JSON ボディを変換する。
+//
+// 変換はルートごとに Op の列 (Pipeline) として宣言し、先頭から順に適用する。
+// 場所は JSON Pointer (RFC 6901) で指定し、add / remove / replace / move / copy は JSON Patch (RFC 6902) と同じ操作を行う。
+// ただしクライアントとバックエンドの契約の差を吸収するため、移動元や削除する値がない場合は何もしない。
+// 宣言できない変換は Plugin として実装し、Register で登録した名前を plugin の操作で指定する。
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aki80204/go-gateway/auth"
+)
+
+// 変換の操作 (Op.Op)
+const (
+	OpAdd      = "add"
+	OpRemove   = "remove"
+	OpReplace  = "replace"
+	OpMove     = "move"
+	OpCopy     = "copy"
+	OpTemplate = "template"
+	OpWrap     = "wrap"
+	OpUnwrap   = "unwrap"
+	OpCase     = "case"
+	OpPlugin   = "plugin"
+)
+
+// Context は変換に渡すリクエストとレスポンスの情報
+type Context struct {
+	Request   *http.Request
+	Principal *auth.Principal
+	// StatusCode と Header はレスポンスの変換でのみ設定する
+	StatusCode int
+	Header     http.Header
+}
+
+// Plugin はコードで実装する変換。body を変換した値を返す。map[string]any と []any はその場で書き換えてよい
+type Plugin interface {
+	Transform(ctx *Context, body any) (any, error)
+}
+
+// PluginFunc は関数を Plugin として扱う
+type PluginFunc func(ctx *Context, body any) (any, error)
+
+func (f PluginFunc) Transform(ctx *Context, body any) (any, error) {
+	return f(ctx, body)
+}
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   = map[string]Plugin{}
+)
+
+// Register は name の Plugin を登録する。ルーティング設定で参照するため、設定を読み込む前 (init など) に登録する
+func Register(name string, p Plugin) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	plugins[name] = p
+}
+
+func lookupPlugin(name string) (Plugin, bool) {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	p, ok := plugins[name]
+	return p, ok
+}
+
+// Op は変換の操作 1 件
+//
+//	{"op": "move", "from": "/user_id", "path": "/userId"}
+//	{"op": "add", "path": "/requestedBy", "value": "{sub}"}
+//	{"op": "template", "value": {"data": "{body:}", "meta": {"tenant": "{claims.tenant_id}"}}}
+//	{"op": "wrap", "path": "/data"}
+//	{"op": "case", "case": "snake_case"}
+//	{"op": "plugin", "name": "legacy-account"}
+type Op struct {
+	Op string `json:"op"`
+	// Path は操作する場所の JSON Pointer。"" はボディ全体を指す
+	Path string `json:"path"`
+	// From は move と copy の移動元・コピー元の JSON Pointer
+	From string `json:"from"`
+	// Value は add、replace、template の値。文字列の {sub}、{client_id}、{claims.NAME}、{body:POINTER} を置き換える。
+	// プレースホルダーだけの文字列は置き換える値の型 (数値やオブジェクト) のまま設定する
+	Value json.RawMessage `json:"value"`
+	// Case は case の変換先 (CaseSnake、CaseCamel)。Path 以下のオブジェクトのキーをすべて変換する
+	Case string `json:"case"`
+	// Name は plugin で呼び出す Register の名前
+	Name string `json:"name"`
+
+	path   []string
+	from   []string
+	value  any
+	plugin Plugin
+}
+
+// Pipeline は先頭から順に適用する変換
+type Pipeline []Op
+
+// Compile は各操作の設定を確認し、JSON Pointer と値を解析する
+func (p Pipeline) Compile() error {
+	for i := range p {
+		if err := p[i].compile(); err != nil {
+			return fmt.Errorf("transform[%d] (%s): %w", i, p[i].Op, err)
+		}
+	}
+	return nil
+}
+
+func (op *Op) compile() error {
+	var err error
+	if op.path, err = parsePointer(op.Path); err != nil {
+		return err
+	}
+	if op.from, err = parsePointer(op.From); err != nil {
+		return err
+	}
+	if len(op.Value) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(op.Value))
+		decoder.UseNumber()
+		if err := decoder.Decode(&op.value); err != nil {
+			return fmt.Errorf("value の形式が不正です: %w", err)
+		}
+	}
+
+	switch op.Op {
+	case OpAdd, OpReplace, OpTemplate:
+		if len(op.Value) == 0 {
+			return errors.New("value は必須です")
+		}
+	case OpRemove, OpWrap, OpUnwrap:
+		if op.Path == "" {
+			return errors.New("path は必須です")
+		}
+	case OpMove, OpCopy:
+		if op.From == "" {
+			return errors.New("from は必須です")
+		}
+	case OpCase:
+		if op.Case != CaseSnake && op.Case != CaseCamel {
+			return fmt.Errorf("case の値が不正です: %q", op.Case)
+		}
+	case OpPlugin:
+		p, ok := lookupPlugin(op.Name)
+		if !ok {
+			return fmt.Errorf("登録されていない plugin です: %q", op.Name)
+		}
+		op.plugin = p
+	default:
+		return fmt.Errorf("op の値が不正です: %q", op.Op)
+	}
+	return nil
+}
+
+// Apply は body に変換を順に適用した値を返す
+func (p Pipeline) Apply(ctx *Context, body any) (any, error) {
+	for i := range p {
+		var err error
+		if body, err = p[i].apply(ctx, body); err != nil {
+			return nil, fmt.Errorf("transform[%d] (%s): %w", i, p[i].Op, err)
+		}
+	}
+	return body, nil
+}
+
+func (op *Op) apply(ctx *Context, body any) (any, error) {
+	switch op.Op {
+	case OpAdd:
+		return set(body, op.path, render(op.value, ctx, body), true)
+	case OpReplace:
+		if _, ok := get(body, op.path); !ok {
+			return body, nil
+		}
+		return set(body, op.path, render(op.value, ctx, body), false)
+	case OpRemove:
+		return remove(body, op.path), nil
+	case OpMove:
+		value, ok := get(body, op.from)
+		if !ok {
+			return body, nil
+		}
+		return set(remove(body, op.from), op.path, value, true)
+	case OpCopy:
+		value, ok := get(body, op.from)
+		if !ok {
+			return body, nil
+		}
+		return set(body, op.path, deepCopy(value), true)
+	case OpTemplate:
+		return render(op.value, ctx, body), nil
+	case OpWrap:
+		return set(nil, op.path, body, true)
+	case OpUnwrap:
+		if value, ok := get(body, op.path); ok {
+			return value, nil
+		}
+		return body, nil
+	case OpCase:
+		convert := toSnake
+		if op.Case == CaseCamel {
+			convert = toCamel
+		}
+		value, ok := get(body, op.path)
+		if !ok {
+			return body, nil
+		}
+		return set(body, op.path, convertKeys(value, convert), false)
+	case OpPlugin:
+		return op.plugin.Transform(ctx, body)
+	}
+	return body, nil
+}
+
+// {sub}、{client_id}、{claims.NAME}、{body:POINTER} のプレースホルダー
+var placeholderPattern = regexp.MustCompile(`\{(sub|client_id|claims\.[^{}]+|body:[^{}]*)\}`)
+
+// render は template の文字列のプレースホルダーを置き換えた値を返す。template は書き換えずに新しい値を作る
+func render(template any, ctx *Context, body any) any {
+	switch t := template.(type) {
+	case string:
+		if m := placeholderPattern.FindStringSubmatch(t); m != nil && m[0] == t {
+			return deepCopy(resolve(m[1], ctx, body))
+		}
+		return placeholderPattern.ReplaceAllStringFunc(t, func(s string) string {
+			v := resolve(s[1:len(s)-1], ctx, body)
+			if v == nil {
+				return ""
+			}
+			return fmt.Sprint(v)
+		})
+	case map[string]any:
+		rendered := make(map[string]any, len(t))
+		for k, v := range t {
+			rendered[k] = render(v, ctx, body)
+		}
+		return rendered
+	case []any:
+		rendered := make([]any, len(t))
+		for i, v := range t {
+			rendered[i] = render(v, ctx, body)
+		}
+		return rendered
+	}
+	return template
+}
+
+func resolve(name string, ctx *Context, body any) any {
+	if pointer, ok := strings.CutPrefix(name, "body:"); ok {
+		tokens, err := parsePointer(pointer)
+		if err != nil {
+			return nil
+		}
+		v, _ := get(body, tokens)
+		return v
+	}
+	if ctx == nil || ctx.Principal == nil {
+		return nil
+	}
+	switch name {
+	case "sub":
+		return ctx.Principal.Subject
+	case "client_id":
+		return ctx.Principal.ClientID
+	}
+	if claim, ok := strings.CutPrefix(name, "claims."); ok {
+		return ctx.Principal.Claims[claim]
+	}
+	return nil
+}
+
+// deepCopy は copy や template で同じ値を複数の場所から参照しないよう、オブジェクトと配列を複製する
+func deepCopy(v any) any {
+	switch c := v.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(c))
+		for k, child := range c {
+			copied[k] = deepCopy(child)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(c))
+		for i, child := range c {
+			copied[i] = deepCopy(child)
+		}
+		return copied
+	}
+	return v
+}
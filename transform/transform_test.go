@@ -0,0 +1,147 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aki80204/go-gateway/auth"
+)
+
+func compile(t *testing.T, ops string) Pipeline {
+	t.Helper()
+	var p Pipeline
+	if err := json.Unmarshal([]byte(ops), &p); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	return p
+}
+
+func TestPipeline_Apply(t *testing.T) {
+	ctx := &Context{Principal: &auth.Principal{
+		Subject: "user-123", ClientID: "mobile-app",
+		Claims: jwt.MapClaims{"tenant_id": "t-1", "roles": []any{"admin"}},
+	}}
+	tests := []struct {
+		name string
+		ops  string
+		body string
+		want string
+	}{
+		{
+			name: "フィールド名の変更と削除",
+			ops:  `[{"op": "move", "from": "/user_id", "path": "/userId"}, {"op": "remove", "path": "/internal"}, {"op": "move", "from": "/missing", "path": "/x"}]`,
+			body: `{"user_id": 1, "internal": true}`,
+			want: `{"userId":1}`,
+		},
+		{
+			name: "sub と claim の追加",
+			ops:  `[{"op": "add", "path": "/requestedBy", "value": "{sub}"}, {"op": "add", "path": "/meta/roles", "value": "{claims.roles}"}, {"op": "add", "path": "/note", "value": "{client_id}/{claims.tenant_id}"}]`,
+			body: `{"amount": 100}`,
+			want: `{"amount":100,"meta":{"roles":["admin"]},"note":"mobile-app/t-1","requestedBy":"user-123"}`,
+		},
+		{
+			name: "置き換えは値がある場合だけ",
+			ops:  `[{"op": "replace", "path": "/status", "value": "ok"}, {"op": "replace", "path": "/missing", "value": 1}]`,
+			body: `{"status": "active"}`,
+			want: `{"status":"ok"}`,
+		},
+		{
+			name: "配列への追加とコピー",
+			ops:  `[{"op": "add", "path": "/items/-", "value": 3}, {"op": "add", "path": "/items/0", "value": 0}, {"op": "copy", "from": "/items", "path": "/backup"}]`,
+			body: `{"items": [1, 2]}`,
+			want: `{"backup":[0,1,2,3],"items":[0,1,2,3]}`,
+		},
+		{
+			name: "エンベロープで包む・取り出す",
+			ops:  `[{"op": "unwrap", "path": "/data"}, {"op": "wrap", "path": "/result/account"}]`,
+			body: `{"data": {"id": 1}, "meta": {}}`,
+			want: `{"result":{"account":{"id":1}}}`,
+		},
+		{
+			name: "snake_case から camelCase",
+			ops:  `[{"op": "case", "case": "camelCase"}]`,
+			body: `{"account_number": "1", "owner_list": [{"first_name": "太郎"}], "_links": {}}`,
+			want: `{"_links":{},"accountNumber":"1","ownerList":[{"firstName":"太郎"}]}`,
+		},
+		{
+			name: "camelCase から snake_case (パスの下だけ)",
+			ops:  `[{"op": "case", "case": "snake_case", "path": "/data"}]`,
+			body: `{"data": {"accountNumber": "1", "userID": "u", "HTTPStatus": 200}, "keepMe": true}`,
+			want: `{"data":{"account_number":"1","http_status":200,"user_id":"u"},"keepMe":true}`,
+		},
+		{
+			name: "テンプレートでボディを組み立てる",
+			ops:  `[{"op": "template", "value": {"account": {"id": "{body:/accountId}", "label": "口座 {body:/accountId}"}, "tenant": "{claims.tenant_id}", "raw": "{body:}"}}]`,
+			body: `{"accountId": 42}`,
+			want: `{"account":{"id":42,"label":"口座 42"},"raw":{"accountId":42},"tenant":"t-1"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body any
+			if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+				t.Fatal(err)
+			}
+			got, err := compile(t, tt.ops).Apply(ctx, body)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if data, _ := json.Marshal(got); string(data) != tt.want {
+				t.Errorf("Apply() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipeline_ApplyError(t *testing.T) {
+	p := compile(t, `[{"op": "add", "path": "/amount/currency", "value": "JPY"}]`)
+	if _, err := p.Apply(&Context{}, map[string]any{"amount": json.Number("100")}); err == nil {
+		t.Error("Apply() 数値の下への追加で error = nil")
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	Register("test-upper", PluginFunc(func(ctx *Context, body any) (any, error) {
+		if ctx.StatusCode >= 500 {
+			return nil, errors.New("バックエンドのエラー")
+		}
+		m := body.(map[string]any)
+		m["plugin"] = ctx.Principal.Subject
+		return m, nil
+	}))
+	p := compile(t, `[{"op": "plugin", "name": "test-upper"}]`)
+
+	got, err := p.Apply(&Context{Principal: &auth.Principal{Subject: "user-123"}, StatusCode: 200}, map[string]any{})
+	if err != nil || got.(map[string]any)["plugin"] != "user-123" {
+		t.Errorf("Apply() = %v, %v", got, err)
+	}
+	if _, err := p.Apply(&Context{StatusCode: 502}, map[string]any{}); err == nil {
+		t.Error("Apply() plugin のエラーで error = nil")
+	}
+}
+
+func TestPipeline_CompileInvalid(t *testing.T) {
+	for _, ops := range []string{
+		`[{"op": "rename"}]`,
+		`[{"op": "add", "path": "/a"}]`,
+		`[{"op": "remove"}]`,
+		`[{"op": "move", "path": "/a"}]`,
+		`[{"op": "add", "path": "a", "value": 1}]`,
+		`[{"op": "case", "case": "kebab-case"}]`,
+		`[{"op": "plugin", "name": "not-registered"}]`,
+	} {
+		var p Pipeline
+		if err := json.Unmarshal([]byte(ops), &p); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Compile(); err == nil {
+			t.Errorf("Compile(%s) error = nil, want error", ops)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem は RFC 9457 の problem details。Errors には違反した箇所の一覧を入れる
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Errors any    `json:"errors,omitempty"`
+}
+
+// WriteProblem は application/problem+json のエラーレスポンスを書き込む
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(body)
+}
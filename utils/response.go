@@ -1,13 +1,29 @@
 package utils
 
 import (
-	"github.com/aws/aws-lambda-go/events"
+	"net/http"
 )
 
-func SuccessResponse(code int, body string) events.APIGatewayProxyResponse {
-	return events.APIGatewayProxyResponse{StatusCode: code, Body: body, Headers: map[string]string{"Content-Type": "application/json"}}
+// WriteJSON は body を JSON のレスポンスとして書き込む
+func WriteJSON(w http.ResponseWriter, code int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write([]byte(body))
 }
 
-func ErrorResponse(code int, msg string) events.APIGatewayProxyResponse {
-	return events.APIGatewayProxyResponse{StatusCode: code, Body: `{"error":"` + msg + `"}`}
+// WriteError は {"error":"msg"} 形式のエラーレスポンスを書き込む
+func WriteError(w http.ResponseWriter, code int, msg string) {
+	WriteJSON(w, code, `{"error":"`+msg+`"}`)
+}
+
+// WriteUnauthorized は RFC 6750 の WWW-Authenticate ヘッダーに errorCode を付けた 401 を書き込む
+func WriteUnauthorized(w http.ResponseWriter, errorCode, description string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="`+errorCode+`", error_description="`+description+`"`)
+	WriteError(w, http.StatusUnauthorized, errorCode)
+}
+
+// WriteInsufficientScope は RFC 6750 の insufficient_scope と必要なスコープ scope を付けた 403 を書き込む
+func WriteInsufficientScope(w http.ResponseWriter, scope string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+	WriteError(w, http.StatusForbidden, "insufficient_scope")
 }